@@ -0,0 +1,35 @@
+package cmd
+
+// GetDashboardReq 统计看板查询条件
+type GetDashboardReq struct {
+	// Period 统计周期, week / month, 为空时按周统计
+	Period string `json:"period,omitempty"`
+	// StartTime / EndTime 对话开始时间的范围, 秒级时间戳
+	StartTime *int64  `json:"start_time,omitempty"`
+	EndTime   *int64  `json:"end_time,omitempty"`
+	Class     *string `json:"class,omitempty"`
+}
+
+type GetDashboardResp struct {
+	Code int64  `json:"code"`
+	Msg  string `json:"msg"`
+	// MinGroupSize 学生数少于该值的分组不展示统计数据
+	MinGroupSize int `json:"min_group_size"`
+	// Unit 单位整体按周期的统计, 班主任只统计负责的班级, 学生数过少而隐藏的班级不计入
+	Unit []*DashboardGroup `json:"unit"`
+	// Classes 各班级按周期的统计
+	Classes []*DashboardGroup `json:"classes"`
+}
+
+// DashboardGroup 一个分组在一个统计周期内的统计
+type DashboardGroup struct {
+	Class  string `json:"class,omitempty"`
+	Period string `json:"period"`
+	// Suppressed 学生数过少, 统计数据不展示
+	Suppressed bool     `json:"suppressed"`
+	Sessions   int64    `json:"sessions"`
+	Students   int64    `json:"students"`
+	Grades     []*Count `json:"grades"`
+	Types      []*Count `json:"types"`
+	Keywords   []*Count `json:"keywords"`
+}
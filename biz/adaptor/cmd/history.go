@@ -1,7 +1,20 @@
 package cmd
 
+import "io"
+
+// ListHistoryReq 对话记录查询条件, 未填写的条件不生效
 type ListHistoryReq struct {
-	Paging Paging `json:"paging"`
+	Paging    Paging  `json:"paging"`
+	StudentId *string `json:"student_id,omitempty"`
+	Class     *string `json:"class,omitempty"`
+	Name      *string `json:"name,omitempty"`
+	// StartTime / EndTime 对话开始时间的范围, 秒级时间戳
+	StartTime *int64  `json:"start_time,omitempty"`
+	EndTime   *int64  `json:"end_time,omitempty"`
+	Grade     *string `json:"grade,omitempty"`
+	Type      *string `json:"type,omitempty"`
+	// Search 在对话内容和报告关键词中搜索
+	Search *string `json:"search,omitempty"`
 }
 
 type ListHistoryResp struct {
@@ -11,9 +24,39 @@ type ListHistoryResp struct {
 	Total   int64      `json:"total"`
 }
 
+// ExportHistoryReq 按列表的查询条件导出对话记录, 每次对话一行
+type ExportHistoryReq struct {
+	ListHistoryReq
+	// Format 导出格式, csv / xlsx
+	Format string `json:"format" vd:"$=='csv'||$=='xlsx'"`
+}
+
+// ExportPDFReq 导出单次对话的记录和报告
+type ExportPDFReq struct {
+	Id string `path:"id"`
+}
+
+// ExportResp 导出的文件
+type ExportResp struct {
+	ContentType string
+	Filename    string
+	Body        io.Reader
+}
+
+type GetHistoryReq struct {
+	Id string `path:"id"`
+}
+
+type GetHistoryResp struct {
+	Code    int64    `json:"code"`
+	Msg     string   `json:"msg"`
+	History *History `json:"history"`
+}
+
 // History 聊天记录与报表
 type History struct {
 	ID        string    `json:"id,omitempty"`
+	UnitId    string    `json:"unit_id"`
 	Name      string    `json:"name"`
 	Class     string    `json:"class"`
 	StudentId string    `json:"student_id"`
@@ -35,3 +78,59 @@ type Report struct {
 	Grade      string   `json:"grade"`
 	Suggestion []string `json:"suggestion"`
 }
+
+// GetTimelineReq 获取单个学生的历次对话趋势
+type GetTimelineReq struct {
+	StudentId string `json:"student_id" vd:"len($)>0"`
+	// Narrative 是否调用大模型生成趋势分析, 耗时较长
+	Narrative bool `json:"narrative,omitempty"`
+	// Recent 趋势分析使用的最近报告数, 为0时使用默认值5
+	Recent int `json:"recent,omitempty"`
+}
+
+type GetTimelineResp struct {
+	Code     int64     `json:"code"`
+	Msg      string    `json:"msg"`
+	Timeline *Timeline `json:"timeline"`
+}
+
+// Timeline 学生的历次对话汇总
+type Timeline struct {
+	StudentId string `json:"student_id"`
+	Name      string `json:"name"`
+	Class     string `json:"class"`
+	// Sessions 对话总次数, 包括超过上限没有返回的对话
+	Sessions int64 `json:"sessions"`
+	// Truncated 对话次数超过上限, Points只包含最近的对话, 时长、分类和关键词也只统计这些对话
+	Truncated bool `json:"truncated,omitempty"`
+	// Duration / AvgDuration 对话总时长和平均时长, 单位秒
+	Duration    int64            `json:"duration"`
+	AvgDuration int64            `json:"avg_duration"`
+	Points      []*TimelinePoint `json:"points"`
+	Types       []*Count         `json:"types"`
+	Keywords    []*Count         `json:"keywords"`
+	Trend       *Trend           `json:"trend,omitempty"`
+}
+
+// TimelinePoint 时间线上的一次对话, 按开始时间升序排列
+type TimelinePoint struct {
+	ID        string   `json:"id"`
+	StartTime int64    `json:"start_time"`
+	Duration  int64    `json:"duration"`
+	Grade     string   `json:"grade"`
+	Type      []string `json:"type"`
+}
+
+// Count 分类或关键词的出现次数
+type Count struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// Trend 大模型对最近几次报告的趋势分析
+type Trend struct {
+	Reports    int      `json:"reports"`
+	Content    string   `json:"content"`
+	Grade      string   `json:"grade"`
+	Suggestion []string `json:"suggestion"`
+}
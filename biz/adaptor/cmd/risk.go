@@ -0,0 +1,53 @@
+package cmd
+
+type ListRiskEventReq struct {
+	Paging  Paging  `json:"paging"`
+	UnitId  *string `json:"unit_id,omitempty"`
+	Class   *string `json:"class,omitempty"`
+	Level   *string `json:"level,omitempty"`
+	Handled *bool   `json:"handled,omitempty"`
+}
+
+type ListRiskEventResp struct {
+	Code   int64        `json:"code"`
+	Msg    string       `json:"msg"`
+	Events []*RiskEvent `json:"events"`
+	Total  int64        `json:"total"`
+}
+
+type AckRiskEventReq struct {
+	Id string `json:"id" vd:"len($)>0"`
+}
+
+type AnnotateRiskEventReq struct {
+	Id      string `json:"id" vd:"len($)>0"`
+	Content string `json:"content" vd:"len($)>0"`
+}
+
+// RiskEvent 风险事件及处理情况
+type RiskEvent struct {
+	ID        string `json:"id"`
+	SessionId string `json:"session_id"`
+	UnitId    string `json:"unit_id"`
+	StudentId string `json:"student_id"`
+	Name      string `json:"name"`
+	Class     string `json:"class"`
+	Level     string `json:"level"`
+	Source    string `json:"source"`
+	Detector  string `json:"detector"`
+	// Evidence 和 Text 是学生的原话, 只对心理老师返回
+	Evidence   []string    `json:"evidence"`
+	Text       string      `json:"text"`
+	Handled    bool        `json:"handled"`
+	HandlerId  string      `json:"handler_id,omitempty"`
+	HandleTime int64       `json:"handle_time,omitempty"`
+	Notes      []*RiskNote `json:"notes"`
+	CreateTime int64       `json:"create_time"`
+}
+
+// RiskNote 跟进记录
+type RiskNote struct {
+	UserId     string `json:"user_id"`
+	Content    string `json:"content"`
+	CreateTime int64  `json:"create_time"`
+}
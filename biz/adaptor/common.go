@@ -6,8 +6,10 @@ import (
 	"github.com/hertz-contrib/websocket"
 	"github.com/xh-polaris/gopkg/util"
 	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/adaptor/cmd"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
 	"net/http"
+	"net/url"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/protocol"
@@ -67,6 +69,19 @@ func (m *headerProvider) Keys() []string {
 	return out
 }
 
+// PostExport 返回导出的文件, 出错时按PostProcess处理
+func PostExport(ctx context.Context, c *app.RequestContext, req any, resp *cmd.ExportResp, err error) {
+	if err != nil {
+		PostProcess(ctx, c, req, nil, err)
+		return
+	}
+	log.CtxInfo(ctx, "[%s] request=%s, export=%s", c.Path(), util.JSONF(req), resp.Filename)
+	b3.New().Inject(ctx, &headerProvider{headers: &c.Response.Header})
+	c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(resp.Filename))
+	c.SetContentType(resp.ContentType)
+	c.SetBodyStream(resp.Body, -1)
+}
+
 func PostProcess(ctx context.Context, c *app.RequestContext, req, resp any, err error) {
 	log.CtxInfo(ctx, "[%s] request=%s, resp=%s, err=%v", c.Path(), util.JSONF(req), util.JSONF(resp), err)
 	b3.New().Inject(ctx, &headerProvider{headers: &c.Response.Header})
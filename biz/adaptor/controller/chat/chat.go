@@ -17,3 +17,12 @@ func LongChat(ctx context.Context, c *app.RequestContext) {
 		log.Error(err.Error())
 	}
 }
+
+// VoiceChat 开启一轮语音对话, 在同一个连接中完成语音识别、对话和语音合成
+// @router /chat/voice [GET]
+func VoiceChat(ctx context.Context, c *app.RequestContext) {
+	err := adaptor.UpgradeWs(ctx, c, service.VoiceChatHandler)
+	if err != nil {
+		log.Error(err.Error())
+	}
+}
@@ -24,3 +24,83 @@ func ListHistory(ctx context.Context, c *app.RequestContext) {
 	resp, err := p.HistoryService.ListHistory(ctx, &req)
 	adaptor.PostProcess(ctx, c, &req, resp, err)
 }
+
+// GetHistory .
+// @router /chat/history/:id [GET]
+func GetHistory(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.GetHistoryReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.HistoryService.GetHistory(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
+
+// GetTimeline .
+// @router /chat/history/timeline [GET]
+func GetTimeline(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.GetTimelineReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.HistoryService.GetTimeline(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
+
+// GetDashboard .
+// @router /chat/history/dashboard [GET]
+func GetDashboard(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.GetDashboardReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.HistoryService.GetDashboard(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
+
+// ExportHistory .
+// @router /chat/history/export [GET]
+func ExportHistory(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.ExportHistoryReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.HistoryService.ExportHistory(ctx, &req)
+	adaptor.PostExport(ctx, c, &req, resp, err)
+}
+
+// ExportPDF .
+// @router /chat/history/:id/pdf [GET]
+func ExportPDF(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.ExportPDFReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.HistoryService.ExportPDF(ctx, &req)
+	adaptor.PostExport(ctx, c, &req, resp, err)
+}
@@ -0,0 +1,58 @@
+package risk
+
+import (
+	"context"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/xh-polaris/psych-digital/biz/adaptor"
+	"github.com/xh-polaris/psych-digital/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-digital/provider"
+)
+
+// ListRiskEvent .
+// @router /risk/list [GET]
+func ListRiskEvent(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.ListRiskEventReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.RiskService.ListRiskEvent(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
+
+// AckRiskEvent .
+// @router /risk/ack [POST]
+func AckRiskEvent(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.AckRiskEventReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.RiskService.AckRiskEvent(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
+
+// AnnotateRiskEvent .
+// @router /risk/note [POST]
+func AnnotateRiskEvent(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.AnnotateRiskEventReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.RiskService.AnnotateRiskEvent(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
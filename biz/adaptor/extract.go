@@ -45,18 +45,7 @@ func ExtractUserMeta(ctx context.Context) (user *basic.UserMeta) {
 	if err != nil {
 		return
 	}
-	tokenString := c.GetHeader("Authorization")
-	token, err := jwt.Parse(string(tokenString), func(_ *jwt.Token) (interface{}, error) {
-		return jwt.ParseECPublicKeyFromPEM([]byte(config.GetConfig().Auth.PublicKey))
-	})
-	if err != nil {
-		return
-	}
-	if !token.Valid {
-		err = errors.New("token is not valid")
-		return
-	}
-	data, err := json.Marshal(token.Claims)
+	data, err := parseToken(c)
 	if err != nil {
 		return
 	}
@@ -77,6 +66,24 @@ func ExtractUserMeta(ctx context.Context) (user *basic.UserMeta) {
 	return
 }
 
+// parseToken 校验请求头中的token, 返回json格式的claims
+func parseToken(c *app.RequestContext) ([]byte, error) {
+	tokenString := c.GetHeader("Authorization")
+	if len(tokenString) == 0 {
+		return nil, errors.New("token not found")
+	}
+	token, err := jwt.Parse(string(tokenString), func(_ *jwt.Token) (interface{}, error) {
+		return jwt.ParseECPublicKeyFromPEM([]byte(config.GetConfig().Auth.PublicKey))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("token is not valid")
+	}
+	return json.Marshal(token.Claims)
+}
+
 func ExtractExtra(ctx context.Context) (extra *basic.Extra) {
 	extra = new(basic.Extra)
 	var err error
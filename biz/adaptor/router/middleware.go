@@ -1,15 +1,56 @@
 package router
 
-import "github.com/cloudwego/hertz/pkg/app"
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	hertz "github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/xh-polaris/psych-digital/biz/adaptor"
+	"github.com/xh-polaris/psych-digital/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
 
 // 定义各类中间件
 
 func _rootMw() []app.HandlerFunc {
-	return nil
+	return []app.HandlerFunc{authMw}
 }
 
 func _longchatMw() []app.HandlerFunc {
 	return nil
 }
 
+func _voicechatMw() []app.HandlerFunc {
+	return nil
+}
+
 func _asrMw() []app.HandlerFunc { return nil }
+
+func _historyMw() []app.HandlerFunc {
+	return []app.HandlerFunc{staffMw}
+}
+
+func _riskMw() []app.HandlerFunc {
+	return []app.HandlerFunc{staffMw}
+}
+
+// authMw 解析token中的教职工信息并注入上下文
+// 学生通过ws连接后再登录, 不携带token, 所以这里不拦截, 由需要鉴权的路由自行校验
+func authMw(ctx context.Context, c *app.RequestContext) {
+	if meta, err := adaptor.ParseStaffMeta(c); err == nil {
+		ctx = adaptor.InjectStaffMeta(ctx, meta)
+	}
+	c.Next(ctx)
+}
+
+// staffMw 要求请求携带有效的教职工token, 失败时与其他接口相同返回code和msg
+func staffMw(ctx context.Context, c *app.RequestContext) {
+	if _, ok := adaptor.ExtractStaffMeta(ctx); !ok {
+		c.AbortWithStatusJSON(hertz.StatusUnauthorized, &cmd.Response{
+			Code: consts.ErrUnauthorized.Code(),
+			Msg:  consts.ErrUnauthorized.Error(),
+		})
+		return
+	}
+	c.Next(ctx)
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/cloudwego/hertz/pkg/app/server"
 	"github.com/xh-polaris/psych-digital/biz/adaptor/controller/chat"
+	"github.com/xh-polaris/psych-digital/biz/adaptor/controller/risk"
 	"github.com/xh-polaris/psych-digital/biz/adaptor/controller/voice"
 )
 
@@ -11,10 +12,22 @@ func Register(r *server.Hertz) {
 	{
 		_chat := root.Group("/chat")
 		_chat.GET("/", append(_longchatMw(), chat.LongChat)...)
-		_chat.GET("/history/list", chat.ListHistory)
+		_chat.GET("/voice", append(_voicechatMw(), chat.VoiceChat)...)
+		_chat.GET("/history/list", append(_historyMw(), chat.ListHistory)...)
+		_chat.GET("/history/timeline", append(_historyMw(), chat.GetTimeline)...)
+		_chat.GET("/history/dashboard", append(_historyMw(), chat.GetDashboard)...)
+		_chat.GET("/history/export", append(_historyMw(), chat.ExportHistory)...)
+		_chat.GET("/history/:id", append(_historyMw(), chat.GetHistory)...)
+		_chat.GET("/history/:id/pdf", append(_historyMw(), chat.ExportPDF)...)
 	}
 	{
 		_voice := root.Group("/voice")
 		_voice.GET("/asr", append(_asrMw(), voice.Asr)...)
 	}
+	{
+		_risk := root.Group("/risk", _riskMw()...)
+		_risk.GET("/list", risk.ListRiskEvent)
+		_risk.POST("/ack", risk.AckRiskEvent)
+		_risk.POST("/note", risk.AnnotateRiskEvent)
+	}
 }
@@ -0,0 +1,48 @@
+package adaptor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+const staffMeta = "staff_meta"
+
+// StaffMeta 教职工的身份信息, 来自token中的自定义字段
+type StaffMeta struct {
+	UserId string `json:"userId"`
+	UnitId string `json:"unitId"`
+	// Role 角色, admin / counsellor / teacher
+	Role string `json:"role"`
+	// Classes 班主任负责的班级, 仅teacher使用
+	Classes []string `json:"classes"`
+}
+
+// ParseStaffMeta 从请求的token中解析教职工信息
+func ParseStaffMeta(c *app.RequestContext) (*StaffMeta, error) {
+	data, err := parseToken(c)
+	if err != nil {
+		return nil, err
+	}
+	meta := new(StaffMeta)
+	if err = json.Unmarshal(data, meta); err != nil {
+		return nil, err
+	}
+	if meta.UserId == "" || meta.UnitId == "" {
+		return nil, errors.New("staff meta not found in token")
+	}
+	return meta, nil
+}
+
+// InjectStaffMeta 将教职工信息注入上下文
+func InjectStaffMeta(ctx context.Context, meta *StaffMeta) context.Context {
+	return context.WithValue(ctx, staffMeta, meta)
+}
+
+// ExtractStaffMeta 获取中间件注入的教职工信息, 请求未携带有效token时返回false
+func ExtractStaffMeta(ctx context.Context) (*StaffMeta, bool) {
+	meta, ok := ctx.Value(staffMeta).(*StaffMeta)
+	return meta, ok
+}
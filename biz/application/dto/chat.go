@@ -13,11 +13,50 @@ type (
 		StudentId string `json:"studentId"`
 		// 密码
 		Password string `json:"password"`
+		// 语音对话中前端发送的音频格式, 可选
+		Audio *AudioFormat `json:"audio,omitempty"`
+		// 前端需要的合成语音格式, 可选, 默认为24000采样频率的pcm
+		Output *TtsFormat `json:"output,omitempty"`
+		// 断线重连时携带开始响应中的resume_token, 恢复之前的对话, 此时只校验学号, 学号必填且需与开始对话时一致
+		ResumeToken string `json:"resume_token,omitempty"`
+		// 断线重连时前端已经收到的消息数, 不包括开始响应, 之后的消息会被重放; v1协议中为收到的最大序号
+		Received uint64 `json:"received,omitempty"`
+		// 前端支持的协议版本, 0为旧协议, 服务端使用两者中较低的版本
+		Version int `json:"version,omitempty"`
 	}
 
-	// ChatReq 对话请求
+	// ChatStartResp 开始对话响应, 断线重连成功时同样返回, 之后的消息从1开始编号
+	ChatStartResp struct {
+		Name   string `json:"name"`
+		Class  string `json:"class"`
+		Gender int32  `json:"gender"`
+		// 对话id
+		SessionId string `json:"session_id"`
+		// 断线重连的凭证
+		ResumeToken string `json:"resume_token"`
+		// 是否为断线重连
+		Resumed bool `json:"resumed,omitempty"`
+		// 断线重连时接下来重放的消息数
+		Replay int `json:"replay,omitempty"`
+		// 断线重连时因缓存超过上限无法重放的消息数
+		Missed int `json:"missed,omitempty"`
+		// 协商后的协议版本, 断线重连时沿用开始时的版本
+		Version int `json:"version,omitempty"`
+	}
+
+	// TtsFormat 前端需要的合成语音格式, 小程序和网页支持的格式不同
+	TtsFormat struct {
+		// 音频格式, pcm / mp3 / ogg_opus, pcm为16位单声道
+		Format string `json:"format"`
+		// 采样频率, 8000 / 16000 / 22050 / 24000 / 32000 / 44100 / 48000
+		SampleRate int `json:"sample_rate,omitempty"`
+		// 音色, 只能选择配置中允许的音色
+		Speaker string `json:"speaker,omitempty"`
+	}
+
+	// ChatReq 对话请求, v1协议中命令由信封的type决定, input的data只需要msg
 	ChatReq struct {
-		// 命令, 0对话, -1结束
+		// 命令, 0对话, -1结束, 1心跳, 2打断AI输出
 		Cmd int64  `json:"cmd"`
 		Msg string `json:"msg"`
 	}
@@ -37,10 +76,71 @@ type (
 		Finish    string `json:"finish"`
 	}
 
+	// ChatEvent 对话模型输出中的表情、动作等非文本事件, 用于驱动数字人; 语音合成不可用时也通过该事件通知前端
+	// 与ChatData区分: ChatEvent总是带有type字段
+	ChatEvent struct {
+		// 事件类型, emotion、action或audio_unavailable
+		Type      string `json:"type"`
+		Content   string `json:"content"`
+		SessionId string `json:"session_id"`
+		Timestamp int64  `json:"timestamp"`
+	}
+
+	// ChatSubtitle 合成语音的字幕
+	// 开始事件在这句话的音频之前发送, 结束事件在音频之后发送, 两者之间的音频都属于这句话
+	ChatSubtitle struct {
+		// 事件类型, subtitle_start或subtitle_end
+		Type string `json:"type"`
+		// 句子id, 同一次对话中递增
+		SegmentId uint64 `json:"segment_id"`
+		Text      string `json:"text"`
+		// 句子在音频流中的开始和结束时间, 单位毫秒, 打断后从0开始
+		Start int64 `json:"start"`
+		End   int64 `json:"end,omitempty"`
+		// 逐字时间戳, 相对句子开始, 只在结束事件中携带
+		Words     []*ChatSubtitleWord `json:"words,omitempty"`
+		SessionId string              `json:"session_id"`
+		Timestamp int64               `json:"timestamp"`
+	}
+
+	// ChatSubtitleWord 字幕中一个字或词的时间, 单位毫秒
+	ChatSubtitleWord struct {
+		Word  string `json:"word"`
+		Start int64  `json:"start"`
+		End   int64  `json:"end"`
+	}
+
+	// ChatViseme 一句话的口型关键帧, 在这句话的字幕结束事件之后发送
+	ChatViseme struct {
+		// 事件类型, 固定为viseme
+		Type string `json:"type"`
+		// 句子id, 与字幕相同
+		SegmentId uint64             `json:"segment_id"`
+		Frames    []*ChatVisemeFrame `json:"frames"`
+		SessionId string             `json:"session_id"`
+		Timestamp int64              `json:"timestamp"`
+	}
+
+	// ChatVisemeFrame 一个口型关键帧, 从time开始保持到下一帧
+	ChatVisemeFrame struct {
+		// 口型, 如sil、PP、aa
+		Viseme string `json:"viseme"`
+		// 在音频流中的时间, 单位毫秒, 与字幕使用相同的时间轴
+		Time int64 `json:"time"`
+	}
+
 	// ChatHistory 对话记录
 	ChatHistory struct {
 		Role    string `json:"role"`
 		Content string `json:"content"`
+		// Interrupted AI输出被用户打断, Content为截断后的内容
+		Interrupted bool `json:"interrupted,omitempty"`
+	}
+
+	// ChatMessage 发送给对话模型的一条上下文, Role 为 system / user / assistant
+	ChatMessage struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
 	}
 
 	// ChatReport 对话分析报告
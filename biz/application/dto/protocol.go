@@ -0,0 +1,96 @@
+package dto
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+// AudioHeaderSize v1协议二进制音频帧的头部长度
+const AudioHeaderSize = 14
+
+// ErrAudioHeader 音频帧过短或版本不匹配
+var ErrAudioHeader = errors.New("invalid audio frame header")
+
+type (
+	// Envelope v1协议中所有文本消息的外层结构, type决定data的结构
+	// 服务端: start ChatStartResp, text ChatData, event ChatEvent, subtitle ChatSubtitle, viseme ChatViseme,
+	// asr AsrResp, end ChatEndResp, error Response, pong 无data
+	// 前端: start ChatStartReq, input ChatReq中的msg, ping / interrupt / end 无data
+	Envelope struct {
+		// 协议版本
+		Version int `json:"v"`
+		// 消息类型
+		Type string `json:"type"`
+		// 服务端消息的序号, 与音频帧共用, 从1开始; 断线重连时作为received, 开始响应和前端消息为0
+		Seq  uint64          `json:"seq,omitempty"`
+		Data json.RawMessage `json:"data,omitempty"`
+	}
+
+	// AudioHeader v1协议二进制音频帧的头部, 大端序
+	// | 版本 1B | 类型 1B | 句子id 4B | 序号 8B | 音频 |
+	AudioHeader struct {
+		Version uint8
+		// 类型, 1为合成语音, 2为麦克风音频
+		Kind uint8
+		// 合成语音所属的句子, 与字幕的segment_id相同; 麦克风音频为0
+		SegmentId uint32
+		// 合成语音为与文本消息共用的序号; 麦克风音频为前端自行递增的序号
+		Seq uint64
+	}
+)
+
+// MarshalEnvelope 编码一条v1协议的文本消息, data为nil时不携带data
+func MarshalEnvelope(version int, typ string, seq uint64, data any) ([]byte, error) {
+	env := &Envelope{Version: version, Type: typ, Seq: seq}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		env.Data = raw
+	}
+	return json.Marshal(env)
+}
+
+// UnmarshalStart 解析开始请求, 兼容旧协议的json和v1协议的信封
+// 信封中的版本作为请求的协议版本
+func UnmarshalStart(data []byte, req *ChatStartReq) error {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return err
+	}
+	if env.Type == "" || env.Data == nil {
+		return json.Unmarshal(data, req)
+	}
+	if err := json.Unmarshal(env.Data, req); err != nil {
+		return err
+	}
+	if req.Version == 0 {
+		req.Version = env.Version
+	}
+	return nil
+}
+
+// Append 在音频前加上头部
+func (h *AudioHeader) Append(audio []byte) []byte {
+	frame := make([]byte, AudioHeaderSize, AudioHeaderSize+len(audio))
+	frame[0], frame[1] = h.Version, h.Kind
+	binary.BigEndian.PutUint32(frame[2:6], h.SegmentId)
+	binary.BigEndian.PutUint64(frame[6:14], h.Seq)
+	return append(frame, audio...)
+}
+
+// UnmarshalAudio 拆分音频帧的头部和音频
+func UnmarshalAudio(frame []byte) (*AudioHeader, []byte, error) {
+	if len(frame) < AudioHeaderSize || frame[0] == 0 {
+		return nil, nil, ErrAudioHeader
+	}
+	h := &AudioHeader{
+		Version:   frame[0],
+		Kind:      frame[1],
+		SegmentId: binary.BigEndian.Uint32(frame[2:6]),
+		Seq:       binary.BigEndian.Uint64(frame[6:14]),
+	}
+	return h, frame[AudioHeaderSize:], nil
+}
@@ -1,8 +1,27 @@
 package dto
 
 type (
+	// AudioFormat 前端发送的音频格式, 语音识别连接的第一条文本消息, 或语音对话开始请求中的audio
+	// 未声明时为16000采样频率的16位单声道PCM
+	AudioFormat struct {
+		// 封装格式, pcm / wav / ogg / webm, ogg和webm只支持opus编码
+		// opus不在服务端解码, 只能用于火山引擎识别, 其他识别服务返回1012错误, 请改用pcm或wav
+		Format string `json:"format"`
+		// 以下只对pcm有效, wav从文件头读取
+		SampleRate int  `json:"sample_rate,omitempty"`
+		Channels   int  `json:"channels,omitempty"`
+		Bits       int  `json:"bits,omitempty"`
+		Float      bool `json:"float,omitempty"`
+	}
+
 	AsrResp struct {
-		Text      string `json:"text"`
-		Timestamp int64  `json:"timestamp"`
+		// 事件类型, speech_start / speech_end / asr_partial / asr_final
+		Type string `json:"type"`
+		// 一句话的id, 同一句话的所有事件相同
+		UtteranceId uint64 `json:"utterance_id"`
+		Text        string `json:"text"`
+		// 一句话是否已经结束, 只有asr_final为true
+		Final     bool  `json:"final"`
+		Timestamp int64 `json:"timestamp"`
 	}
 )
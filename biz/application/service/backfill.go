@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/rpc/psych_user"
+	"github.com/xh-polaris/psych-idl/kitex_gen/basic"
+	"github.com/xh-polaris/psych-idl/kitex_gen/user"
+	"github.com/zeromicro/go-zero/core/syncx"
+)
+
+// backfillPage 分页获取单位用户时每页的数量
+const backfillPage = 100
+
+var (
+	// backfilled 已补充过unit_id的单位, 每个进程对每个单位只补充一次
+	backfilled sync.Map
+	// legacyDone 所有记录都有unit_id后不再检查
+	legacyDone atomic.Bool
+	// backfillFlight 同一单位的并发请求只执行一次补充
+	backfillFlight = syncx.NewSingleFlight()
+)
+
+// backfill 升级前的对话记录没有unit_id, 按单位查询前根据psych-user中的学生名单补充, 避免旧记录在按单位筛选时不可见
+// 补充失败时只记录日志, 下次查询时重试
+func (s *HistoryService) backfill(ctx context.Context, unitId string) {
+	if legacyDone.Load() || s.PsychUser == nil {
+		return
+	}
+	if _, ok := backfilled.Load(unitId); ok {
+		return
+	}
+	_, _ = backfillFlight.Do(unitId, func() (any, error) {
+		has, err := s.HistoryMapper.HasLegacy(ctx)
+		if err != nil {
+			log.Error("check legacy history err:", err)
+			return nil, err
+		}
+		if !has {
+			legacyDone.Store(true)
+			return nil, nil
+		}
+		ids, err := studentIds(ctx, s.PsychUser, unitId)
+		if err != nil {
+			log.Error("list unit students err:", err)
+			return nil, err
+		}
+		n, err := s.HistoryMapper.Backfill(ctx, unitId, ids)
+		if err != nil {
+			log.Error("backfill history unit err:", err)
+			return nil, err
+		}
+		log.Info("补充对话记录的单位, unit: %s, 记录数: %d", unitId, n)
+		backfilled.Store(unitId, struct{}{})
+		return nil, nil
+	})
+}
+
+// studentIds 获取单位下所有学生的学号
+func studentIds(ctx context.Context, psychU psych_user.IPsychUser, unitId string) ([]string, error) {
+	var ids []string
+	limit := int64(backfillPage)
+	for offset := int64(0); ; offset += limit {
+		res, err := psychU.UnitPageQueryUser(ctx, &user.UnitPageQueryUserReq{
+			Id:                unitId,
+			PaginationOptions: &basic.PaginationOptions{Limit: &limit, Offset: &offset},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range res.User {
+			info, err := psychU.UserGetInfo(ctx, &user.UserGetInfoReq{UserId: u.Id, UnitId: &unitId})
+			if err != nil {
+				return nil, err
+			}
+			if info.StudentId != nil && *info.StudentId != "" {
+				ids = append(ids, *info.StudentId)
+			}
+		}
+		if int64(len(res.User)) < limit {
+			return ids, nil
+		}
+	}
+}
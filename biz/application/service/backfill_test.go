@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/cloudwego/kitex/client/callopt"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/rpc/psych_user"
+	"github.com/xh-polaris/psych-idl/kitex_gen/user"
+)
+
+// fakePsychUser 只实现补充单位用到的接口
+type fakePsychUser struct {
+	psych_user.IPsychUser
+	users int
+}
+
+func (f *fakePsychUser) UnitPageQueryUser(_ context.Context, req *user.UnitPageQueryUserReq, _ ...callopt.Option) (*user.UnitPageQueryUserResp, error) {
+	resp := &user.UnitPageQueryUserResp{}
+	for i := *req.PaginationOptions.Offset; i < min(*req.PaginationOptions.Offset+*req.PaginationOptions.Limit, int64(f.users)); i++ {
+		resp.User = append(resp.User, &user.User{Id: fmt.Sprint(i)})
+	}
+	return resp, nil
+}
+
+func (f *fakePsychUser) UserGetInfo(_ context.Context, req *user.UserGetInfoReq, _ ...callopt.Option) (*user.UserGetInfoResp, error) {
+	// 教职工等没有学号的用户不参与匹配
+	if req.UserId == "0" {
+		return &user.UserGetInfoResp{}, nil
+	}
+	id := "s" + req.UserId
+	return &user.UserGetInfoResp{StudentId: &id}, nil
+}
+
+func TestStudentIds(t *testing.T) {
+	ids, err := studentIds(context.Background(), &fakePsychUser{users: 2*backfillPage + 1}, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2*backfillPage || ids[0] != "s1" || !slices.Contains(ids, fmt.Sprint("s", 2*backfillPage)) {
+		t.Errorf("ids = %d, first %v", len(ids), ids[:1])
+	}
+}
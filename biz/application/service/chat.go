@@ -3,22 +3,72 @@ package service
 import (
 	"context"
 	"github.com/hertz-contrib/websocket"
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
 	"github.com/xh-polaris/psych-digital/biz/domain/chat"
 )
 
-// ChatHandler 处理长对话 TODO: 应该需要加上超时处理，避免连接空置太长时间
+// ChatHandler 处理长对话
+// 连接断开或心跳超时时保留对话, 前端携带resume_token重连后继续; 空闲或对话时长超时时告别并结束对话
 func ChatHandler(ctx context.Context, conn *websocket.Conn) {
-	var err error
+	engine, err := openEngine(ctx, conn, chat.ModeChat)
+	if err != nil {
+		return
+	}
 
-	// 初始化本轮对话的engine
-	engine := chat.NewEngine(ctx, conn)
-	defer func() { engine.Close() }()
+	if engine.Chat() {
+		engine.Suspend()
+		return
+	}
+	engine.Close()
+}
 
-	// 执行初始化操作
-	err = engine.Start()
+// VoiceChatHandler 处理语音对话, 鉴权和响应与长对话相同, 用户输入为音频流
+func VoiceChatHandler(ctx context.Context, conn *websocket.Conn) {
+	engine, err := openEngine(ctx, conn, chat.ModeVoice)
 	if err != nil {
 		return
 	}
 
-	engine.Chat()
+	if engine.Voice() {
+		engine.Suspend()
+		return
+	}
+	engine.Close()
+}
+
+// openEngine 读取开始请求, 携带resume_token时恢复断开的对话, 否则初始化新一轮对话
+// 开始请求可以是旧协议的json, 也可以是v1协议的信封; mode为当前接口的对话模式, 只能恢复相同模式的对话
+func openEngine(ctx context.Context, conn *websocket.Conn, mode string) (*chat.Engine, error) {
+	var req dto.ChatStartReq
+	_, data, err := conn.ReadMessage()
+	if err == nil {
+		err = dto.UnmarshalStart(data, &req)
+	}
+	if err != nil {
+		log.Error("read start req err:", err)
+		_ = conn.Close()
+		return nil, err
+	}
+	if req.ResumeToken != "" {
+		engine, err := chat.Resume(conn, &req, mode)
+		if err != nil {
+			log.Error("resume chat err:", err)
+		}
+		return engine, err
+	}
+
+	// 初始化本轮对话的engine
+	engine, err := chat.NewEngine(ctx, conn, mode)
+	if err != nil {
+		log.Error("new chat engine err:", err)
+		_ = conn.Close()
+		return nil, err
+	}
+	// 执行初始化操作
+	if err = engine.Start(&req); err != nil {
+		engine.Close()
+		return nil, err
+	}
+	return engine, nil
 }
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/history"
+)
+
+const (
+	defaultMinGroupSize = 5
+	defaultTimezone     = "Asia/Shanghai"
+	// topTypes / topKeywords 每组返回的分类数和关键词数
+	topTypes    = 10
+	topKeywords = 50
+)
+
+// GetDashboard 按班级和周期统计对话数、报告等级分布、常见分类和关键词
+// 学生数少于阈值的分组不返回统计数据
+func (s *HistoryService) GetDashboard(ctx context.Context, req *cmd.GetDashboardReq) (*cmd.GetDashboardResp, error) {
+	_, sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.backfill(ctx, sc.unitId)
+	period := req.Period
+	if period == "" {
+		period = consts.PeriodWeek
+	}
+	if period != consts.PeriodWeek && period != consts.PeriodMonth {
+		return nil, consts.ErrInvalidParam
+	}
+
+	c := config.GetConfig().Dashboard
+	minSize, timezone := c.MinGroupSize, c.Timezone
+	if minSize <= 0 {
+		minSize = defaultMinGroupSize
+	}
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+
+	fopts := &history.FilterOptions{
+		UnitId:  &sc.unitId,
+		Classes: sc.restrict(req.Class),
+	}
+	if req.StartTime != nil {
+		start := time.Unix(*req.StartTime, 0)
+		fopts.StartTime = &start
+	}
+	if req.EndTime != nil {
+		end := time.Unix(*req.EndTime, 0)
+		fopts.EndTime = &end
+	}
+	groups, err := s.HistoryMapper.Aggregate(ctx, fopts, period, timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	unit, classes := summarize(groups, minSize)
+	return &cmd.GetDashboardResp{
+		Code:         0,
+		Msg:          "success",
+		MinGroupSize: minSize,
+		Unit:         unit,
+		Classes:      classes,
+	}, nil
+}
+
+// tally 一个分组的计数
+type tally struct {
+	class    string
+	period   string
+	sessions int64
+	students map[string]struct{}
+	grades   map[string]int64
+	types    map[string]int64
+	keywords map[string]int64
+}
+
+func newTally(class, period string) *tally {
+	return &tally{
+		class:    class,
+		period:   period,
+		students: map[string]struct{}{},
+		grades:   map[string]int64{},
+		types:    map[string]int64{},
+		keywords: map[string]int64{},
+	}
+}
+
+// add 累加一个班级分组, 没有报告的对话只计入对话数
+func (t *tally) add(g *history.Group) {
+	t.sessions += g.Sessions
+	for _, st := range g.Students {
+		t.students[st] = struct{}{}
+	}
+	for _, grade := range g.Grades {
+		if grade != "" {
+			t.grades[grade]++
+		}
+	}
+	for _, types := range g.Types {
+		for _, typ := range types {
+			t.types[typ]++
+		}
+	}
+	for _, keywords := range g.Keywords {
+		for _, kw := range keywords {
+			t.keywords[kw]++
+		}
+	}
+}
+
+// group 输出统计结果, 学生数少于minSize时只保留分组信息
+func (t *tally) group(minSize int) *cmd.DashboardGroup {
+	g := &cmd.DashboardGroup{
+		Class:  t.class,
+		Period: t.period,
+	}
+	if len(t.students) < minSize {
+		g.Suppressed = true
+		return g
+	}
+	g.Sessions = t.sessions
+	g.Students = int64(len(t.students))
+	g.Grades = rank(t.grades)
+	g.Types = top(rank(t.types), topTypes)
+	g.Keywords = top(rank(t.keywords), topKeywords)
+	return g
+}
+
+// summarize 生成各班级的统计, 并按周期合并为单位整体的统计, groups需按周期排序
+// 被隐藏的班级不计入单位整体, 否则用单位减去其他班级即可还原出隐藏班级的数据
+func summarize(groups []*history.Group, minSize int) (unit, classes []*cmd.DashboardGroup) {
+	var cur *tally
+	for _, g := range groups {
+		c := newTally(g.Class, g.Period)
+		c.add(g)
+		cg := c.group(minSize)
+		classes = append(classes, cg)
+
+		if cur == nil || cur.period != g.Period {
+			if cur != nil {
+				unit = append(unit, cur.group(minSize))
+			}
+			cur = newTally("", g.Period)
+		}
+		if !cg.Suppressed {
+			cur.add(g)
+		}
+	}
+	if cur != nil {
+		unit = append(unit, cur.group(minSize))
+	}
+	return unit, classes
+}
+
+func top(counts []*cmd.Count, n int) []*cmd.Count {
+	if len(counts) > n {
+		return counts[:n]
+	}
+	return counts
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/history"
+)
+
+func TestSummarize(t *testing.T) {
+	groups := []*history.Group{
+		{Class: "一班", Period: "2025-W10", Sessions: 4, Students: []string{"a", "b", "c"},
+			Grades: []string{"低", "低", "中"}, Types: [][]string{{"学业"}, {"学业", "睡眠"}}, Keywords: [][]string{{"考试"}}},
+		{Class: "二班", Period: "2025-W10", Sessions: 1, Students: []string{"d"}, Grades: []string{"高"}},
+		{Class: "一班", Period: "2025-W11", Sessions: 1, Students: []string{"a"}},
+	}
+
+	unit, classes := summarize(groups, 3)
+	if len(classes) != 3 || len(unit) != 2 {
+		t.Fatalf("unit = %d, classes = %d", len(unit), len(classes))
+	}
+
+	one := classes[0]
+	if one.Suppressed || one.Sessions != 4 || one.Students != 3 || one.Grades[0].Name != "低" || one.Grades[0].Count != 2 {
+		t.Errorf("一班 = %+v", one)
+	}
+	if one.Types[0].Name != "学业" || one.Types[0].Count != 2 {
+		t.Errorf("一班 types = %+v", one.Types)
+	}
+
+	// 单个学生的班级不能展示任何数据
+	two := classes[1]
+	if !two.Suppressed || two.Sessions != 0 || two.Grades != nil || two.Class != "二班" {
+		t.Errorf("二班 = %+v", two)
+	}
+
+	// 单位整体不包括隐藏的班级, 减去可见班级后无法还原出二班的数据
+	w10 := unit[0]
+	if w10.Period != "2025-W10" || w10.Suppressed || w10.Sessions != one.Sessions || w10.Students != one.Students {
+		t.Errorf("unit W10 = %+v", w10)
+	}
+	if len(w10.Grades) != len(one.Grades) {
+		t.Errorf("unit W10 grades = %+v, 一班 grades = %+v", w10.Grades, one.Grades)
+	}
+	for _, g := range w10.Grades {
+		if g.Name == "高" {
+			t.Errorf("unit W10 leaks the suppressed class: %+v", w10.Grades)
+		}
+	}
+	if !unit[1].Suppressed {
+		t.Errorf("unit W11 = %+v", unit[1])
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/export"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/history"
+)
+
+const (
+	defaultMaxRows = 10000
+	// exportBatch 导出时每次从数据库读取的记录数
+	exportBatch = 200
+	timeLayout  = "2006-01-02 15:04:05"
+)
+
+var exportHeader = []string{"记录ID", "姓名", "班级", "学号", "开始时间", "结束时间", "时长(秒)", "等级", "类型", "关键词", "报告内容", "建议"}
+
+// ExportHistory 按列表的查询条件导出对话记录, 边查询边写出
+func (s *HistoryService) ExportHistory(ctx context.Context, req *cmd.ExportHistoryReq) (*cmd.ExportResp, error) {
+	_, sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.backfill(ctx, sc.unitId)
+	maxRows := config.GetConfig().Export.MaxRows
+	if maxRows <= 0 {
+		maxRows = defaultMaxRows
+	}
+
+	next := s.rows(ctx, listFilter(sc, &req.ListHistoryReq), maxRows)
+	resp := &cmd.ExportResp{Filename: "history-" + time.Now().Format("20060102150405") + "." + req.Format}
+	var write func(w io.Writer) error
+	switch req.Format {
+	case "csv":
+		resp.ContentType = "text/csv; charset=utf-8"
+		write = func(w io.Writer) error { return export.WriteCSV(w, exportHeader, next) }
+	case "xlsx":
+		resp.ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		write = func(w io.Writer) error { return export.WriteXLSX(w, "对话记录", exportHeader, next) }
+	default:
+		return nil, consts.ErrInvalidParam
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		err := write(pw)
+		if err != nil {
+			log.Error("export history err:", err)
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	resp.Body = pr
+	return resp, nil
+}
+
+// rows 分批读取对话记录并转换为表格行, 最多返回maxRows行
+// 以上一批最后一条记录为游标读取下一批, 导出期间新增的记录不会导致重复或遗漏
+func (s *HistoryService) rows(ctx context.Context, fopts *history.FilterOptions, maxRows int) export.RowIter {
+	var batch []*history.History
+	var cursor *history.Cursor
+	count, done := 0, false
+	return func() ([]string, error) {
+		if len(batch) == 0 {
+			if done || count >= maxRows {
+				return nil, io.EOF
+			}
+			data, err := s.HistoryMapper.FindAfter(ctx, fopts, cursor, exportBatch)
+			if err != nil {
+				return nil, err
+			}
+			done = len(data) < exportBatch
+			if batch = data; len(batch) == 0 {
+				return nil, io.EOF
+			}
+			last := batch[len(batch)-1]
+			cursor = &history.Cursor{StartTime: last.StartTime, ID: last.ID}
+		}
+		h := batch[0]
+		batch = batch[1:]
+		count++
+		return toRow(h), nil
+	}
+}
+
+// toRow 将一次对话转换为表格的一行
+func toRow(h *history.History) []string {
+	row := []string{
+		h.ID.Hex(), h.Name, h.Class, h.StudentId,
+		h.StartTime.Format(timeLayout), h.EndTime.Format(timeLayout),
+		strconv.FormatInt(int64(h.EndTime.Sub(h.StartTime).Seconds()), 10),
+	}
+	if h.Report == nil {
+		row = append(row, "", "", "", "", "")
+	} else {
+		row = append(row, h.Report.Grade, strings.Join(h.Report.Type, "、"), strings.Join(h.Report.Keywords, "、"),
+			h.Report.Content, strings.Join(h.Report.Suggestion, "\n"))
+	}
+	for i, cell := range row {
+		row[i] = escapeFormula(cell)
+	}
+	return row
+}
+
+// escapeFormula 姓名和报告内容可能以公式字符开头, 表格软件打开时会被当作公式执行, 加上单引号作为纯文本
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// ExportPDF 将单次对话的记录和报告导出为可打印的PDF
+func (s *HistoryService) ExportPDF(ctx context.Context, req *cmd.ExportPDFReq) (*cmd.ExportResp, error) {
+	_, sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.backfill(ctx, sc.unitId)
+	h, err := s.HistoryMapper.FindOne(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !sc.allow(h.UnitId, h.Class) {
+		return nil, consts.ErrForbidden
+	}
+
+	c := config.GetConfig().Export
+	if c.Font == "" {
+		return nil, consts.ErrNoExportFont
+	}
+	doc := toDocument(h, brandOf(c.Brands, h.UnitId), sc.dialogs)
+	pr, pw := io.Pipe()
+	go func() {
+		err := export.WritePDF(pw, c.Font, doc)
+		if err != nil {
+			log.Error("export pdf err:", err)
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	return &cmd.ExportResp{
+		ContentType: "application/pdf",
+		Filename:    fmt.Sprintf("%s-%s.pdf", h.StudentId, h.StartTime.Format("20060102")),
+		Body:        pr,
+	}, nil
+}
+
+// brandOf 获取单位的PDF抬头, 没有单独配置时使用默认抬头
+func brandOf(brands []config.ExportBrand, unitId string) export.Brand {
+	var brand export.Brand
+	for _, b := range brands {
+		if b.UnitId == unitId {
+			return export.Brand{Name: b.Name, Logo: b.Logo}
+		}
+		if b.UnitId == "" {
+			brand = export.Brand{Name: b.Name, Logo: b.Logo}
+		}
+	}
+	return brand
+}
+
+// toDocument 将对话记录转换为PDF文档, dialogs为false时不包含对话原文
+func toDocument(h *history.History, brand export.Brand, dialogs bool) *export.Document {
+	doc := &export.Document{
+		Brand: brand,
+		Title: "心理对话记录",
+		Sections: []*export.Section{{
+			Heading: "基本信息",
+			Fields: [][2]string{
+				{"姓名", h.Name},
+				{"班级", h.Class},
+				{"学号", h.StudentId},
+				{"对话时间", h.StartTime.Format(timeLayout) + " - " + h.EndTime.Format(timeLayout)},
+			},
+		}},
+	}
+	if h.Report != nil {
+		doc.Sections = append(doc.Sections, &export.Section{
+			Heading: "分析报告",
+			Fields: [][2]string{
+				{"等级", h.Report.Grade},
+				{"类型", strings.Join(h.Report.Type, "、")},
+				{"关键词", strings.Join(h.Report.Keywords, "、")},
+				{"内容", h.Report.Content},
+			},
+		})
+		suggestion := &export.Section{Heading: "建议"}
+		for i, sg := range h.Report.Suggestion {
+			suggestion.Lines = append(suggestion.Lines, fmt.Sprintf("%d. %s", i+1, sg))
+		}
+		doc.Sections = append(doc.Sections, suggestion)
+	}
+	if dialogs {
+		transcript := &export.Section{Heading: "对话记录"}
+		for _, d := range h.Dialogs {
+			if d == nil {
+				continue
+			}
+			transcript.Lines = append(transcript.Lines, roleLabel(d.Role)+": "+d.Content)
+		}
+		doc.Sections = append(doc.Sections, transcript)
+	}
+	return doc
+}
+
+// roleLabel 对话角色的中文名称
+func roleLabel(role string) string {
+	switch role {
+	case "ai":
+		return "AI"
+	case "user":
+		return "学生"
+	case "system":
+		return "开场"
+	default:
+		return role
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/history"
+)
+
+func TestToRow_EscapeFormula(t *testing.T) {
+	start := time.Date(2025, 3, 1, 8, 0, 0, 0, time.Local)
+	row := toRow(&history.History{
+		Name: "=HYPERLINK(\"http://x\")", Class: "一班", StartTime: start, EndTime: start.Add(time.Minute),
+		Report: &history.Report{Grade: "低", Content: "@SUM(A1)", Suggestion: []string{"-1+1", "多休息"}},
+	})
+	if row[1] != "'=HYPERLINK(\"http://x\")" || row[2] != "一班" || row[6] != "60" {
+		t.Errorf("row = %q", row)
+	}
+	if row[10] != "'@SUM(A1)" || row[11] != "'-1+1\n多休息" {
+		t.Errorf("report cells = %q", row[10:])
+	}
+	for _, cell := range []string{"+1", "\tx", "\rx"} {
+		if got := escapeFormula(cell); got != "'"+cell {
+			t.Errorf("escapeFormula(%q) = %q", cell, got)
+		}
+	}
+}
@@ -2,17 +2,28 @@ package service
 
 import (
 	"context"
+	"time"
+
 	"github.com/google/wire"
 	"github.com/xh-polaris/psych-digital/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/history"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/rpc/psych_user"
 )
 
 type IHistoryService interface {
 	ListHistory(ctx context.Context, req *cmd.ListHistoryReq) (*cmd.ListHistoryResp, error)
+	GetHistory(ctx context.Context, req *cmd.GetHistoryReq) (*cmd.GetHistoryResp, error)
+	GetTimeline(ctx context.Context, req *cmd.GetTimelineReq) (*cmd.GetTimelineResp, error)
+	GetDashboard(ctx context.Context, req *cmd.GetDashboardReq) (*cmd.GetDashboardResp, error)
+	ExportHistory(ctx context.Context, req *cmd.ExportHistoryReq) (*cmd.ExportResp, error)
+	ExportPDF(ctx context.Context, req *cmd.ExportPDFReq) (*cmd.ExportResp, error)
 }
 
 type HistoryService struct {
 	HistoryMapper *history.MongoMapper
+	// PsychUser 用于为升级前的记录补充单位
+	PsychUser psych_user.IPsychUser
 }
 
 var HistoryServiceSet = wire.NewSet(
@@ -20,44 +31,21 @@ var HistoryServiceSet = wire.NewSet(
 	wire.Bind(new(IHistoryService), new(*HistoryService)),
 )
 
+// ListHistory 分页获取对话记录, 只返回调用者所在单位和权限范围内的记录
 func (s *HistoryService) ListHistory(ctx context.Context, req *cmd.ListHistoryReq) (*cmd.ListHistoryResp, error) {
-	data, total, err := s.HistoryMapper.FindMany(ctx, &req.Paging)
+	_, sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.backfill(ctx, sc.unitId)
+	data, total, err := s.HistoryMapper.FindMany(ctx, listFilter(sc, req), &req.Paging)
 	if err != nil {
 		return nil, err
 	}
 
 	his := make([]*cmd.History, 0, len(data))
 	for _, h := range data {
-		dia := make([]*cmd.Dialog, 0, len(h.Dialogs))
-		for _, d := range h.Dialogs {
-			if d == nil {
-				continue
-			}
-			dia = append(dia, &cmd.Dialog{
-				Role:    d.Role,
-				Content: d.Content,
-			})
-		}
-		ch := &cmd.History{
-			ID:        h.ID.Hex(),
-			Name:      h.Name,
-			Class:     h.Class,
-			StudentId: h.StudentId,
-			Dialogs:   dia,
-			StartTime: h.StartTime.Unix(),
-			EndTime:   h.EndTime.Unix(),
-		}
-		if h.Report != nil {
-			ch.Report = &cmd.Report{
-				Keywords:   h.Report.Keywords,
-				Type:       h.Report.Type,
-				Content:    h.Report.Content,
-				Grade:      h.Report.Grade,
-				Suggestion: h.Report.Suggestion,
-			}
-		}
-
-		his = append(his, ch)
+		his = append(his, toHistory(h, sc.dialogs))
 	}
 	return &cmd.ListHistoryResp{
 		Code:    0,
@@ -66,3 +54,82 @@ func (s *HistoryService) ListHistory(ctx context.Context, req *cmd.ListHistoryRe
 		Total:   total,
 	}, nil
 }
+
+// listFilter 将列表查询条件转换为数据库筛选条件, 并限定在调用者的权限范围内
+func listFilter(sc *scope, req *cmd.ListHistoryReq) *history.FilterOptions {
+	fopts := &history.FilterOptions{
+		UnitId:    &sc.unitId,
+		Classes:   sc.restrict(req.Class),
+		StudentId: req.StudentId,
+		Name:      req.Name,
+		Grade:     req.Grade,
+		Type:      req.Type,
+		Search:    req.Search,
+		// 对话原文不可见时, 不允许通过搜索推断对话内容
+		SearchDialogs: sc.dialogs,
+	}
+	if req.StartTime != nil {
+		start := time.Unix(*req.StartTime, 0)
+		fopts.StartTime = &start
+	}
+	if req.EndTime != nil {
+		end := time.Unix(*req.EndTime, 0)
+		fopts.EndTime = &end
+	}
+	return fopts
+}
+
+// GetHistory 获取单条对话记录
+func (s *HistoryService) GetHistory(ctx context.Context, req *cmd.GetHistoryReq) (*cmd.GetHistoryResp, error) {
+	_, sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.backfill(ctx, sc.unitId)
+	h, err := s.HistoryMapper.FindOne(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !sc.allow(h.UnitId, h.Class) {
+		return nil, consts.ErrForbidden
+	}
+	return &cmd.GetHistoryResp{
+		Code:    0,
+		Msg:     "success",
+		History: toHistory(h, sc.dialogs),
+	}, nil
+}
+
+// toHistory 转换为响应格式, dialogs为false时不返回对话原文
+func toHistory(h *history.History, dialogs bool) *cmd.History {
+	dia := make([]*cmd.Dialog, 0, len(h.Dialogs))
+	for _, d := range h.Dialogs {
+		if d == nil || !dialogs {
+			continue
+		}
+		dia = append(dia, &cmd.Dialog{
+			Role:    d.Role,
+			Content: d.Content,
+		})
+	}
+	ch := &cmd.History{
+		ID:        h.ID.Hex(),
+		UnitId:    h.UnitId,
+		Name:      h.Name,
+		Class:     h.Class,
+		StudentId: h.StudentId,
+		Dialogs:   dia,
+		StartTime: h.StartTime.Unix(),
+		EndTime:   h.EndTime.Unix(),
+	}
+	if h.Report != nil {
+		ch.Report = &cmd.Report{
+			Keywords:   h.Report.Keywords,
+			Type:       h.Report.Type,
+			Content:    h.Report.Content,
+			Grade:      h.Report.Grade,
+			Suggestion: h.Report.Suggestion,
+		}
+	}
+	return ch
+}
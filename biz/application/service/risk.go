@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/wire"
+	"github.com/xh-polaris/psych-digital/biz/adaptor"
+	"github.com/xh-polaris/psych-digital/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/risk"
+)
+
+type IRiskService interface {
+	ListRiskEvent(ctx context.Context, req *cmd.ListRiskEventReq) (*cmd.ListRiskEventResp, error)
+	AckRiskEvent(ctx context.Context, req *cmd.AckRiskEventReq) (*cmd.Response, error)
+	AnnotateRiskEvent(ctx context.Context, req *cmd.AnnotateRiskEventReq) (*cmd.Response, error)
+}
+
+type RiskService struct {
+	RiskMapper *risk.MongoMapper
+}
+
+var RiskServiceSet = wire.NewSet(
+	wire.Struct(new(RiskService), "*"),
+	wire.Bind(new(IRiskService), new(*RiskService)),
+)
+
+// ListRiskEvent 分页获取风险事件, 只返回调用者所在单位和权限范围内的事件
+func (s *RiskService) ListRiskEvent(ctx context.Context, req *cmd.ListRiskEventReq) (*cmd.ListRiskEventResp, error) {
+	_, sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.UnitId != nil && *req.UnitId != sc.unitId {
+		return nil, consts.ErrForbidden
+	}
+	data, total, err := s.RiskMapper.FindMany(ctx, &risk.FilterOptions{
+		UnitId:  &sc.unitId,
+		Classes: sc.restrict(req.Class),
+		Level:   req.Level,
+		Handled: req.Handled,
+	}, &req.Paging)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*cmd.RiskEvent, 0, len(data))
+	for _, e := range data {
+		events = append(events, toRiskEvent(e, sc.dialogs))
+	}
+	return &cmd.ListRiskEventResp{
+		Code:   0,
+		Msg:    "success",
+		Events: events,
+		Total:  total,
+	}, nil
+}
+
+// AckRiskEvent 确认处理风险事件, 处理人为当前登录用户
+func (s *RiskService) AckRiskEvent(ctx context.Context, req *cmd.AckRiskEventReq) (*cmd.Response, error) {
+	meta, err := s.check(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.RiskMapper.Ack(ctx, req.Id, meta.UserId); err != nil {
+		return nil, err
+	}
+	return &cmd.Response{Code: 0, Msg: "success"}, nil
+}
+
+// AnnotateRiskEvent 为风险事件追加跟进记录
+func (s *RiskService) AnnotateRiskEvent(ctx context.Context, req *cmd.AnnotateRiskEventReq) (*cmd.Response, error) {
+	meta, err := s.check(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.RiskMapper.AddNote(ctx, req.Id, &risk.Note{
+		UserId:     meta.UserId,
+		Content:    req.Content,
+		CreateTime: time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+	return &cmd.Response{Code: 0, Msg: "success"}, nil
+}
+
+// check 校验风险事件在调用者的权限范围内
+func (s *RiskService) check(ctx context.Context, id string) (*adaptor.StaffMeta, error) {
+	meta, sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	event, err := s.RiskMapper.FindOne(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !sc.allow(event.UnitId, event.Class) {
+		return nil, consts.ErrForbidden
+	}
+	return meta, nil
+}
+
+// toRiskEvent 转换为响应格式, dialogs为false时不返回学生的原话和命中的证据
+func toRiskEvent(e *risk.RiskEvent, dialogs bool) *cmd.RiskEvent {
+	notes := make([]*cmd.RiskNote, 0, len(e.Notes))
+	for _, n := range e.Notes {
+		if n == nil {
+			continue
+		}
+		notes = append(notes, &cmd.RiskNote{
+			UserId:     n.UserId,
+			Content:    n.Content,
+			CreateTime: n.CreateTime.Unix(),
+		})
+	}
+	re := &cmd.RiskEvent{
+		ID:         e.ID.Hex(),
+		SessionId:  e.SessionId,
+		UnitId:     e.UnitId,
+		StudentId:  e.StudentId,
+		Name:       e.Name,
+		Class:      e.Class,
+		Level:      e.Level,
+		Source:     e.Source,
+		Detector:   e.Detector,
+		Handled:    e.Handled,
+		HandlerId:  e.HandlerId,
+		Notes:      notes,
+		CreateTime: e.CreateTime.Unix(),
+	}
+	if dialogs {
+		re.Evidence, re.Text = e.Evidence, e.Text
+	}
+	if !e.HandleTime.IsZero() {
+		re.HandleTime = e.HandleTime.Unix()
+	}
+	return re
+}
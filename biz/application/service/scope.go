@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"github.com/xh-polaris/psych-digital/biz/adaptor"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// scope 教职工可以查看的数据范围
+// 管理员和心理老师可以查看本单位所有学生, 班主任只能查看自己负责的班级
+// 对话原文只对心理老师开放, 其余角色只能查看报告
+type scope struct {
+	unitId string
+	// classes 为nil时不限制班级
+	classes []string
+	dialogs bool
+}
+
+// newScope 根据教职工角色计算数据范围, 未知角色没有任何权限
+func newScope(meta *adaptor.StaffMeta) (*scope, error) {
+	s := &scope{unitId: meta.UnitId}
+	switch meta.Role {
+	case consts.StaffAdmin:
+	case consts.StaffCounsellor:
+		s.dialogs = true
+	case consts.StaffTeacher:
+		s.classes = meta.Classes
+		if s.classes == nil {
+			s.classes = []string{}
+		}
+	default:
+		return nil, consts.ErrForbidden
+	}
+	return s, nil
+}
+
+// scopeOf 获取当前请求的数据范围
+func scopeOf(ctx context.Context) (*adaptor.StaffMeta, *scope, error) {
+	meta, ok := adaptor.ExtractStaffMeta(ctx)
+	if !ok {
+		return nil, nil, consts.ErrUnauthorized
+	}
+	s, err := newScope(meta)
+	return meta, s, err
+}
+
+// allow 判断一条记录是否在范围内
+func (s *scope) allow(unitId, class string) bool {
+	return unitId == s.unitId && (s.classes == nil || slices.Contains(s.classes, class))
+}
+
+// restrict 将请求中的班级筛选与可见班级取交集, 返回nil表示不限制
+func (s *scope) restrict(class *string) []string {
+	switch {
+	case class == nil:
+		return s.classes
+	case s.classes == nil || slices.Contains(s.classes, *class):
+		return []string{*class}
+	default:
+		return []string{}
+	}
+}
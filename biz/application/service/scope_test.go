@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/adaptor"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/risk"
+)
+
+func TestScope(t *testing.T) {
+	class := func(c string) *string { return &c }
+
+	admin, err := newScope(&adaptor.StaffMeta{UserId: "a", UnitId: "u1", Role: consts.StaffAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admin.dialogs || admin.classes != nil || !admin.allow("u1", "一班") || admin.allow("u2", "一班") {
+		t.Errorf("admin scope = %+v", admin)
+	}
+
+	counsellor, _ := newScope(&adaptor.StaffMeta{UserId: "c", UnitId: "u1", Role: consts.StaffCounsellor})
+	if !counsellor.dialogs || counsellor.classes != nil {
+		t.Errorf("counsellor scope = %+v", counsellor)
+	}
+
+	teacher, _ := newScope(&adaptor.StaffMeta{UserId: "t", UnitId: "u1", Role: consts.StaffTeacher, Classes: []string{"一班"}})
+	if teacher.dialogs || !teacher.allow("u1", "一班") || teacher.allow("u1", "二班") {
+		t.Errorf("teacher scope = %+v", teacher)
+	}
+	if got := teacher.restrict(nil); len(got) != 1 || got[0] != "一班" {
+		t.Errorf("restrict(nil) = %v", got)
+	}
+	if got := teacher.restrict(class("二班")); got == nil || len(got) != 0 {
+		t.Errorf("restrict(二班) = %v, want empty", got)
+	}
+	if got := admin.restrict(class("二班")); len(got) != 1 || got[0] != "二班" {
+		t.Errorf("admin restrict(二班) = %v", got)
+	}
+
+	// 没有负责班级的班主任看不到任何记录
+	empty, _ := newScope(&adaptor.StaffMeta{UserId: "t", UnitId: "u1", Role: consts.StaffTeacher})
+	if empty.classes == nil || empty.allow("u1", "一班") {
+		t.Errorf("empty teacher scope = %+v", empty)
+	}
+
+	if _, err = newScope(&adaptor.StaffMeta{UserId: "s", UnitId: "u1", Role: "student"}); !errors.Is(err, consts.ErrForbidden) {
+		t.Errorf("unknown role err = %v", err)
+	}
+	if _, _, err = scopeOf(context.Background()); !errors.Is(err, consts.ErrUnauthorized) {
+		t.Errorf("scopeOf without meta err = %v", err)
+	}
+}
+
+func TestScope_RiskEvent(t *testing.T) {
+	e := &risk.RiskEvent{UnitId: "u1", Class: "一班", Evidence: []string{"不想活了"}, Text: "我真的不想活了"}
+	for _, role := range []string{consts.StaffAdmin, consts.StaffTeacher, consts.StaffCounsellor} {
+		sc, _ := newScope(&adaptor.StaffMeta{UserId: "x", UnitId: "u1", Role: role, Classes: []string{"一班"}})
+		re := toRiskEvent(e, sc.dialogs)
+		// 只有心理老师可以看到学生的原话
+		if visible := re.Text != "" || len(re.Evidence) > 0; visible != (role == consts.StaffCounsellor) {
+			t.Errorf("%s: text = %q, evidence = %v", role, re.Text, re.Evidence)
+		}
+	}
+}
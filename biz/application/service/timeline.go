@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/xh-polaris/psych-digital/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-digital/biz/domain/model/bailian"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/history"
+)
+
+const (
+	// timelineLimit 时间线最多包含的对话数
+	timelineLimit = 500
+	// defaultRecent 趋势分析默认使用的报告数
+	defaultRecent = 5
+	// maxRecent 趋势分析最多使用的报告数, 避免提示词过长
+	maxRecent = 20
+)
+
+// GetTimeline 汇总一个学生的历次对话, 可选调用大模型分析最近几次报告的变化趋势
+func (s *HistoryService) GetTimeline(ctx context.Context, req *cmd.GetTimelineReq) (*cmd.GetTimelineResp, error) {
+	_, sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.backfill(ctx, sc.unitId)
+	data, total, err := s.HistoryMapper.FindMany(ctx, &history.FilterOptions{
+		UnitId:    &sc.unitId,
+		Classes:   sc.classes,
+		StudentId: &req.StudentId,
+	}, &cmd.Paging{Page: 1, Limit: timelineLimit})
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, consts.ErrNotFound
+	}
+	// 查询结果按开始时间倒序, 时间线使用升序
+	slices.Reverse(data)
+
+	timeline := buildTimeline(data, total)
+	if req.Narrative {
+		recent := req.Recent
+		if recent <= 0 {
+			recent = defaultRecent
+		}
+		if timeline.Trend, err = narrate(data, min(recent, maxRecent)); err != nil {
+			return nil, err
+		}
+	}
+	return &cmd.GetTimelineResp{
+		Code:     0,
+		Msg:      "success",
+		Timeline: timeline,
+	}, nil
+}
+
+// buildTimeline 统计对话次数、时长、报告分类和关键词, data需按开始时间升序
+// total为对话总次数, 超过data的数量时标记为截断, 时长等统计只包含data中的对话
+func buildTimeline(data []*history.History, total int64) *cmd.Timeline {
+	last := data[len(data)-1]
+	t := &cmd.Timeline{
+		StudentId: last.StudentId,
+		Name:      last.Name,
+		Class:     last.Class,
+		Sessions:  max(total, int64(len(data))),
+		Truncated: total > int64(len(data)),
+		Points:    make([]*cmd.TimelinePoint, 0, len(data)),
+	}
+	types, keywords := map[string]int64{}, map[string]int64{}
+	for _, h := range data {
+		duration := int64(h.EndTime.Sub(h.StartTime).Seconds())
+		t.Duration += duration
+		p := &cmd.TimelinePoint{
+			ID:        h.ID.Hex(),
+			StartTime: h.StartTime.Unix(),
+			Duration:  duration,
+		}
+		if h.Report != nil {
+			p.Grade, p.Type = h.Report.Grade, h.Report.Type
+			for _, typ := range h.Report.Type {
+				types[typ]++
+			}
+			for _, kw := range h.Report.Keywords {
+				keywords[kw]++
+			}
+		}
+		t.Points = append(t.Points, p)
+	}
+	t.AvgDuration = t.Duration / int64(len(data))
+	t.Types, t.Keywords = rank(types), rank(keywords)
+	return t
+}
+
+// rank 按出现次数降序排列, 次数相同时按名称排序保证结果稳定
+func rank(counts map[string]int64) []*cmd.Count {
+	res := make([]*cmd.Count, 0, len(counts))
+	for name, count := range counts {
+		res = append(res, &cmd.Count{Name: name, Count: count})
+	}
+	slices.SortFunc(res, func(a, b *cmd.Count) int {
+		if a.Count != b.Count {
+			return int(b.Count - a.Count)
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return res
+}
+
+// narrate 将最近recent份报告交给趋势分析模型, data需按开始时间升序
+func narrate(data []*history.History, recent int) (*cmd.Trend, error) {
+	reports := make([]*history.History, 0, recent)
+	for i := len(data) - 1; i >= 0 && len(reports) < recent; i-- {
+		if data[i].Report != nil {
+			reports = append(reports, data[i])
+		}
+	}
+	if len(reports) == 0 {
+		return nil, nil
+	}
+	slices.Reverse(reports)
+
+	res, err := bailian.GetBLTrendApp().Call(buildTrendPrompt(reports))
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, nil
+	}
+	return &cmd.Trend{
+		Reports:    len(reports),
+		Content:    res.Report.Content,
+		Grade:      res.Report.Grade,
+		Suggestion: res.Report.Suggestion,
+	}, nil
+}
+
+// buildTrendPrompt 按时间顺序拼接历次报告
+func buildTrendPrompt(reports []*history.History) string {
+	var sb strings.Builder
+	last := reports[len(reports)-1]
+	_, _ = fmt.Fprintf(&sb, "以下是%s%s最近%d次心理对话的分析报告, 按时间先后排列。", last.Class, last.Name, len(reports))
+	sb.WriteString("请对比各次报告, 判断该学生的心理状态是在好转、保持稳定还是在恶化, ")
+	sb.WriteString("在report.content中给出趋势分析, 在report.grade中给出目前的风险等级, 在report.suggestion中给出后续跟进建议。\n")
+	for i, h := range reports {
+		_, _ = fmt.Fprintf(&sb, "第%d次(%s): 等级: %s; 类型: %s; 关键词: %s; 内容: %s\n",
+			i+1, h.StartTime.Format("2006-01-02 15:04"), h.Report.Grade,
+			strings.Join(h.Report.Type, "、"), strings.Join(h.Report.Keywords, "、"), h.Report.Content)
+	}
+	return sb.String()
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/history"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	start := time.Date(2025, 3, 1, 8, 0, 0, 0, time.Local)
+	data := []*history.History{
+		{Name: "小明", Class: "一班", StudentId: "s1", StartTime: start, EndTime: start.Add(10 * time.Minute),
+			Report: &history.Report{Grade: "中", Type: []string{"学业", "睡眠"}, Keywords: []string{"考试", "失眠"}}},
+		{Name: "小明", Class: "一班", StudentId: "s1", StartTime: start.Add(24 * time.Hour), EndTime: start.Add(24*time.Hour + 20*time.Minute)},
+		{Name: "小明", Class: "二班", StudentId: "s1", StartTime: start.Add(48 * time.Hour), EndTime: start.Add(48*time.Hour + 30*time.Minute),
+			Report: &history.Report{Grade: "低", Type: []string{"学业"}, Keywords: []string{"考试"}}},
+	}
+
+	tl := buildTimeline(data, 3)
+	if tl.Sessions != 3 || tl.Truncated || tl.Duration != 3600 || tl.AvgDuration != 1200 || tl.Class != "二班" {
+		t.Errorf("timeline = %+v", tl)
+	}
+	// 超过上限时次数使用总数, 平均时长只按返回的对话计算
+	if tl := buildTimeline(data, 800); tl.Sessions != 800 || !tl.Truncated || tl.AvgDuration != 1200 {
+		t.Errorf("truncated timeline = %+v", tl)
+	}
+	if len(tl.Points) != 3 || tl.Points[0].Grade != "中" || tl.Points[1].Grade != "" || tl.Points[2].Duration != 1800 {
+		t.Errorf("points = %+v", tl.Points)
+	}
+	if tl.Types[0].Name != "学业" || tl.Types[0].Count != 2 || tl.Types[1].Name != "睡眠" {
+		t.Errorf("types = %+v", tl.Types)
+	}
+	if tl.Keywords[0].Name != "考试" || tl.Keywords[0].Count != 2 {
+		t.Errorf("keywords = %+v", tl.Keywords)
+	}
+
+	prompt := buildTrendPrompt([]*history.History{data[0], data[2]})
+	for _, want := range []string{"二班小明最近2次", "第1次(2025-03-01 08:00): 等级: 中; 类型: 学业、睡眠", "第2次(2025-03-03 08:00): 等级: 低"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+}
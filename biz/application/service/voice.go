@@ -6,7 +6,7 @@ import (
 	"golang.org/x/net/context"
 )
 
-// AsrHandler 通用音频识别 TODO: 应该需要加上超时处理，避免连接空置太长时间
+// AsrHandler 通用音频识别, 心跳、空闲或连接时长超时后自动断开
 func AsrHandler(ctx context.Context, conn *websocket.Conn) {
 	engine := voice.NewEngine(ctx, conn)
 	defer func() { _ = engine.Close() }()
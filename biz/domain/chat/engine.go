@@ -5,15 +5,23 @@ import (
 	"errors"
 	"io"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hertz-contrib/websocket"
 	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-digital/biz/application/dto"
 	"github.com/xh-polaris/psych-digital/biz/domain"
 	"github.com/xh-polaris/psych-digital/biz/domain/model"
-	"github.com/xh-polaris/psych-digital/biz/domain/model/bailian"
-	"github.com/xh-polaris/psych-digital/biz/domain/model/volc"
+	_ "github.com/xh-polaris/psych-digital/biz/domain/model/bailian"
+	_ "github.com/xh-polaris/psych-digital/biz/domain/model/fake"
+	_ "github.com/xh-polaris/psych-digital/biz/domain/model/openai"
+	_ "github.com/xh-polaris/psych-digital/biz/domain/model/volc"
+	"github.com/xh-polaris/psych-digital/biz/domain/risk"
+	"github.com/xh-polaris/psych-digital/biz/domain/voice"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/audio"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/mq"
@@ -22,8 +30,14 @@ import (
 	"github.com/xh-polaris/psych-idl/kitex_gen/user"
 )
 
+// 对话模式, 断线重连时只能在开始对话时的接口上恢复
+const (
+	ModeChat  = "chat"
+	ModeVoice = "voice"
+)
+
 // Engine 是处理一轮对话的核心对象
-// 只读取文字对话, 语言识别由另一个ws连接处理
+// 文字对话由Chat处理; 语音对话由Voice处理, 在同一个连接中完成识别、对话和合成
 type Engine struct {
 	// ctx 上下文
 	ctx context.Context
@@ -41,42 +55,90 @@ type Engine struct {
 	// chatApp 是调用的对话大模型
 	chatApp model.ChatApp
 
-	// ttsApp 是调用的语音合成大模型
+	// ttsApp 是调用的语音合成大模型, 确定合成语音格式后创建
 	ttsApp model.TtsApp
 
-	// sessionId 是本轮对话的唯一标记, 只有第一次调用时会写入, 应该不需要互斥锁
-	// 目前使用的是BaiLian提供的sessionId管理, 如果有更好的方式, 可以考虑自己实现
+	// degraded 语音合成不可用, 对话降级为纯文字
+	degraded atomic.Bool
+
+	// phrases 常用语句的合成音频缓存, 未配置时为nil
+	phrases *phraseCache
+
+	// playlist 保证缓存的音频和实时合成的音频按顺序下发
+	playlist *playlist
+
+	// output 前端需要的合成语音格式, 开始请求中未指定时为nil
+	output *dto.TtsFormat
+
+	// asrApp 是语音对话中调用的语音识别大模型, 文字对话中为nil, 每次连接时重新创建
+	asrApp model.AsrApp
+
+	// endpointer 语音对话中判断学生什么时候说完一句话
+	endpointer *voice.Endpointer
+
+	// audioFormat 语音对话中前端声明的音频格式, 开始请求中未声明时为nil
+	audioFormat *dto.AudioFormat
+
+	// transcoder 语音对话中将前端的音频转换为语音识别支持的格式
+	transcoder audio.Transcoder
+
+	// sessionId 是本轮对话的唯一标记, 创建engine时生成, 之后只读
 	sessionId string
 
-	// aiHistory 记录AI输出历史
-	aiHistory chan string
+	// resumeToken 断线重连的凭证, 创建engine时生成, 之后只读
+	resumeToken string
 
-	// userHistory 记录用户输入历史
-	userHistory chan string
+	// mode 对话模式, 文字对话为ModeChat, 语音对话为ModeVoice, 创建engine时确定, 之后只读
+	mode string
+
+	// version 协商后的对话协议版本, 开始时确定, 之后只读
+	version int
+
+	// window 根据redis中的对话记录构造发送给模型的上下文
+	window *window
 
 	// outw ai的流式文本, 用于语音合成
-	outw chan string
+	outw chan *utterance
+
+	// styles 情感标记对应的语音风格
+	styles styles
 
 	// outv 合成的流式语音
 	outv chan []byte
 
-	// stop 用于打断AI输出
-	stop chan bool
+	// subtitles 根据合成的句子边界生成字幕
+	subtitles *subtitler
+
+	// replyCtx 当前所有进行中的AI回复共用的上下文, 打断时取消并在下一轮重新创建
+	replyMu     sync.Mutex
+	replyCtx    context.Context
+	replyCancel context.CancelFunc
+	// lastDone 最近一轮回复的完成信号, 用于保证对话记录的顺序
+	lastDone chan struct{}
 
 	// startTime 开始对话时间
 	startTime time.Time
 
+	// watchdog 心跳、空闲和对话时长的超时检查
+	watchdog *domain.Watchdog
+
+	// offline 连接已断开, 等待重连
+	offline atomic.Bool
+
+	// closeOnce 保证对话只结束一次, 超时和重连超时都可能结束对话
+	closeOnce sync.Once
+
 	// provider 消息生产者
 	provider *mq.HistoryProducer
 
 	// psychU 下游用户服务
 	psychU psych_user.IPsychUser
 
-	// parenthesis 是否在括号内
-	parenthesis int
+	// risk 本次对话的风险检测, 鉴权后创建
+	risk *risk.Session
 
-	// 对话轮数
-	round     int
+	// 对话轮数, 超时结束对话时在其他协程读取
+	round     atomic.Int32
 	userId    string
 	unitId    string
 	studentId string
@@ -85,77 +147,80 @@ type Engine struct {
 	gender    int32
 }
 
-// NewEngine 初始化一个ChatEngine
-// 暂时先固定为BaiLian之后类型多再换成工厂方法
-func NewEngine(ctx context.Context, conn *websocket.Conn) *Engine {
-	ctx, cancel := context.WithCancel(context.Background())
+// NewEngine 初始化一个ChatEngine, mode为对话模式
+// 对话模型由配置中的Chat.Provider决定
+func NewEngine(ctx context.Context, conn *websocket.Conn, mode string) (*Engine, error) {
 	c := config.GetConfig()
+	chatApp, err := model.NewChatApp(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	e := &Engine{
 		ctx:    ctx,
 		cancel: cancel,
 		ws:     domain.NewWsHelper(conn),
 		rs:     domain.GetRedisHelper(),
 		//rs:          domain.NewMemoryRedisHelper(),
-		chatApp:     bailian.NewBLChatApp(c.BaiLianChat.AppId, c.BaiLianChat.ApiKey),
-		ttsApp:      volc.NewVcTtsApp(c.VolcTts.AppKey, c.VolcTts.AccessKey, c.VolcTts.Speaker, c.VolcTts.ResourceId, c.VolcTts.Url),
-		aiHistory:   make(chan string, 10),
-		userHistory: make(chan string, 10),
-		outw:        make(chan string, 50),
+		chatApp:     chatApp,
+		sessionId:   uuid.New().String(),
+		resumeToken: uuid.New().String(),
+		mode:        mode,
+		window:      newWindow(&c.Chat),
+		outw:        make(chan *utterance, 50),
+		styles:      newStyles(&c.Chat),
+		subtitles:   &subtitler{},
 		outv:        make(chan []byte, 50),
-		stop:        make(chan bool),
 		startTime:   time.Now(),
+		watchdog:    domain.NewWatchdog(&c.Timeout),
 		provider:    mq.GetHistoryProducer(),
-		parenthesis: 0,
 		psychU:      psych_user.NewPsychUser(c),
-		round:       0,
 		name:        "",
 	}
-	return e
+	return e, nil
 }
 
-// Start 开始一轮对话, 执行相关初始化
-func (e *Engine) Start() error {
+// Start 开始一轮对话, 执行相关初始化, req为前端的开始请求
+func (e *Engine) Start(req *dto.ChatStartReq) error {
 	var err error
 
+	// 开始响应和之后的消息都按协商的协议版本编码
+	e.version = negotiate(req.Version)
+	e.ws.Protocol(e.version)
+
 	// 鉴权
-	if !e.validate() {
+	if !e.validate(req) {
 		_ = e.ws.Error(consts.ErrInvalidUser)
 		return consts.ErrInvalidUser
 	}
 
 	msg := "你好呀, 我是" + e.class + "的" + e.name
 
-	// 音频生成
+	// 音频生成, 合成服务不可用时降级为纯文字对话
 	if err = e.tts(); err != nil {
 		return err
 	}
 
-	// chat模型调用
-	go e.streamCall(msg)
-
-	// 由于sessionId由第三方给出, 所以这里需要手动管理聊天记录的顺序
-	// 等待获取sessionId, 初始化redis
-	his := <-e.aiHistory
+	// 开场白作为第一条记录, 之后由模型根据上下文生成回复
 	if err = e.rs.AddSystem(e.sessionId, msg); err != nil {
 		return err
 	}
-	if err = e.rs.AddAi(e.sessionId, his); err != nil {
-		return err
-	}
-	return err
+
+	// chat模型调用
+	go e.streamCall(e.newReply(), "")
+	go e.watch()
+	return nil
 }
 
 // validate 校验使用者信息, 目前没有鉴权，只做一下日志
-func (e *Engine) validate() bool {
-	var startReq dto.ChatStartReq
+// 校验通过后返回开始响应, 并开始记录之后发送的消息用于断线重连
+func (e *Engine) validate(startReq *dto.ChatStartReq) bool {
 	var err error
 
-	err = e.ws.ReadJSON(&startReq)
-	if err != nil {
-		log.Error("read json err:", err)
-		return false
-	}
 	log.Info("调用方: %s, 调用时间: %s", startReq.From, time.Unix(startReq.Timestamp, 0).String())
+	e.audioFormat = startReq.Audio
+	e.output = startReq.Output
 	// 登录逻辑
 	unitId, studentId, password := startReq.UnitId, startReq.StudentId, startReq.Password
 	var res *user.UserSignInResp
@@ -186,19 +251,37 @@ func (e *Engine) validate() bool {
 	}
 	e.class = form["class"].(string)
 	e.gender = resp.User.Gender
-	if err = e.ws.WriteJSON(map[string]any{
-		"name":   e.name,
-		"class":  e.class,
-		"gender": e.gender,
-	}); err != nil {
+	e.risk = risk.GetRiskDetector().NewSession(risk.Meta{
+		SessionId: e.sessionId,
+		UserId:    e.userId,
+		UnitId:    e.unitId,
+		StudentId: e.studentId,
+		Name:      e.name,
+		Class:     e.class,
+	})
+	if err = e.ws.Send(consts.MsgStart, e.startResp()); err != nil {
 		return false
 	}
+	e.ws.Journal(resumeBuffer(&config.GetConfig().Chat))
 	return true
 }
 
+// startResp 开始对话响应
+func (e *Engine) startResp() *dto.ChatStartResp {
+	return &dto.ChatStartResp{
+		Name:        e.name,
+		Class:       e.class,
+		Gender:      e.gender,
+		SessionId:   e.sessionId,
+		ResumeToken: e.resumeToken,
+		Version:     e.version,
+	}
+}
+
 // Chat 长对话的主体部分 #生产者
-func (e *Engine) Chat() {
-	var req dto.ChatReq
+// 连接断开时返回true, 前端结束对话时返回false
+func (e *Engine) Chat() (dropped bool) {
+	var data []byte
 	var err error
 	defer func() {
 		if err != nil {
@@ -206,92 +289,391 @@ func (e *Engine) Chat() {
 		}
 	}()
 
-	// 启动聊天记录处理
-	go e.history(e.aiHistory, e.userHistory)
-
 	for {
 		// 获取前端对话内容
-		err = e.ws.ReadJSON(&req)
+		_, data, err = e.ws.Read()
 		if err != nil {
-			return
+			return true
 		}
-		// 判断是否结束
-		switch req.Cmd {
-		case consts.EndCmd:
-			return
-		case consts.Ping:
-			err := e.ws.WriteBytes([]byte{})
+		e.watchdog.Seen()
+		req, derr := decodeReq(e.version, data)
+		if derr != nil {
+			log.Error("decode chat req err:", derr)
+			continue
+		}
+		if !e.command(req) {
+			return false
+		}
+	}
+}
+
+// Voice 语音对话的主体部分 #生产者
+// 前端在同一个连接中发送麦克风的音频流和文字命令, 识别出的完整语句直接作为用户输入
+// 语音识别只在连接期间进行, 断线重连后重新创建; 连接断开时返回true, 前端结束对话或识别出错时返回false
+func (e *Engine) Voice() (dropped bool) {
+	var err error
+	if e.transcoder, err = voice.NewTranscoder(e.audioFormat); err != nil {
+		log.Error("audio format err:", err)
+		_ = e.ws.Error(consts.ErrAudioFormat)
+		return false
+	}
+	c := config.GetConfig()
+	if e.asrApp, err = model.NewAsrApp(c, &model.AsrOptions{
+		Format:    e.transcoder.Output(),
+		EndWindow: c.Vad.Silence,
+	}); err != nil {
+		log.Error("new asr err:", err)
+		_ = e.ws.Error(voice.AsrError(err))
+		return false
+	}
+	e.endpointer = voice.NewEndpointer(&c.Vad, e.utterance)
+	defer e.closeAsr()
+	if err = e.asrApp.Dial(); err != nil {
+		log.Error("dial asr err:", err)
+		return false
+	}
+	if err = e.asrApp.Start(); err != nil {
+		log.Error("start asr err:", err)
+		return false
+	}
+	go e.recognise(e.asrApp, e.endpointer)
+
+	for {
+		mt, data, err := e.ws.Read()
+		if err != nil {
+			return true
+		}
+		e.watchdog.Seen()
+		switch mt {
+		case websocket.BinaryMessage:
+			if len(data) == 0 {
+				continue
+			}
+			if data, err = decodeAudio(e.version, data); err != nil {
+				log.Error("decode audio err:", err)
+				continue
+			}
+			if err = e.audio(data); err != nil {
+				log.Error("send asr err:", err)
+				return false
+			}
+		case websocket.TextMessage:
+			req, err := decodeReq(e.version, data)
 			if err != nil {
-				return
+				log.Error("decode voice req err:", err)
+				continue
 			}
-			continue
+			if !e.command(req) {
+				return false
+			}
+		}
+	}
+}
+
+// closeAsr 结束语音识别
+func (e *Engine) closeAsr() {
+	e.endpointer.Close()
+	if err := e.asrApp.Close(); err != nil {
+		log.Error("close asr err:", err)
+	}
+}
+
+// audio 转换音频格式后发送给asr, 同时检测说话的开始和结束
+func (e *Engine) audio(data []byte) error {
+	out, err := e.transcoder.Write(data)
+	if err != nil || len(out) == 0 {
+		return err
+	}
+	e.endpointer.Detect(e.transcoder, out)
+	return e.asrApp.Send(out)
+}
 
+// recognise 获取识别结果并断句 #生产者
+// 断线重连后会重新创建识别, 所以使用启动时的app和endpointer
+func (e *Engine) recognise(app model.AsrApp, endpointer *voice.Endpointer) {
+	for {
+		res, err := app.Receive()
+		if err != nil {
+			if e.ctx.Err() == nil && !errors.Is(err, io.EOF) {
+				log.Error("receive asr err:", err)
+			}
+			return
 		}
-		// 写入用户消息
-		e.userHistory <- req.Msg
-		e.round++
-		// 调用ai, 流式响应
-		go e.streamCall(req.Msg)
+		endpointer.Result(res)
+	}
+}
+
+// utterance 写入识别和断句事件, 一句话结束后调用ai
+func (e *Engine) utterance(resp *dto.AsrResp) {
+	e.watchdog.Active()
+	if err := e.ws.Send(consts.MsgAsr, resp); err != nil {
+		log.Error("ws write asr err:", err)
+		return
+	}
+	if resp.Final {
+		e.command(&dto.ChatReq{Msg: resp.Text})
+	}
+}
+
+// command 处理前端的一条命令, 返回false表示对话结束
+func (e *Engine) command(req *dto.ChatReq) bool {
+	switch req.Cmd {
+	case consts.EndCmd:
+		return false
+	case consts.Ping:
+		return e.ws.Pong() == nil
+	case consts.InterruptCmd:
+		e.watchdog.Active()
+		e.interrupt()
+		return true
 	}
+	e.watchdog.Active()
+	e.round.Add(1)
+	// 调用ai, 流式响应
+	go e.streamCall(e.newReply(), req.Msg)
+	return true
+}
+
+// reply 是一轮AI回复
+type reply struct {
+	// ctx 在被打断或对话结束时取消
+	ctx context.Context
+	// done 在本轮回复的记录写入后关闭
+	done chan struct{}
+	// prev 上一轮回复的完成信号, 需要等待其记录写入后才能写入本轮
+	prev <-chan struct{}
 }
 
-// streamCall 调用chatApp并流式写入响应 #生产者
-func (e *Engine) streamCall(msg string) {
-	var record string
+// newReply 开启新一轮AI回复
+func (e *Engine) newReply() *reply {
+	e.replyMu.Lock()
+	defer e.replyMu.Unlock()
+	if e.replyCtx == nil {
+		e.replyCtx, e.replyCancel = context.WithCancel(e.ctx)
+	}
+	r := &reply{
+		ctx:  e.replyCtx,
+		done: make(chan struct{}),
+		prev: e.lastDone,
+	}
+	e.lastDone = r.done
+	return r
+}
+
+// interrupt 打断AI输出
+// 取消所有进行中的流式调用, 清空尚未合成的文本, 并通知tts丢弃已排队的合成
+func (e *Engine) interrupt() {
+	e.replyMu.Lock()
+	if e.replyCancel != nil {
+		e.replyCancel()
+	}
+	e.replyCtx, e.replyCancel = nil, nil
+	e.replyMu.Unlock()
+
+	// 即使文本已经输出完毕, 音频可能仍在合成和播放, 所以总是需要清空
+	e.drain()
+	if e.ttsApp != nil && !e.degraded.Load() {
+		if err := e.ttsApp.Cancel(); err != nil {
+			log.Error("cancel tts err:", err)
+		}
+	}
+	e.subtitles.reset()
+	e.playlist.reset()
+
+	// 通知前端本轮输出已结束
+	if err := e.ws.Send(consts.MsgText, &dto.ChatData{
+		SessionId: e.sessionId,
+		Timestamp: time.Now().Unix(),
+		Finish:    consts.FinishInterrupted,
+	}); err != nil {
+		log.Error("write interrupted err:", err)
+	}
+}
+
+// drain 清空待合成的文本和待发送的音频
+func (e *Engine) drain() {
+	for {
+		select {
+		case <-e.outw:
+		case <-e.outv:
+		default:
+			return
+		}
+	}
+}
+
+// streamCall 将用户输入写入记录, 以完整上下文调用chatApp并流式写入响应 #生产者
+// msg 为空时表示开场, 开场白已由Start写入
+// r.ctx 被取消时表示本轮输出被打断, 已输出的部分会带上打断标记写入记录
+func (e *Engine) streamCall(r *reply, msg string) {
+	// record 是展示给学生的文本, raw 是模型原始输出, 用于风险检测
+	var record, raw string
 	var data *dto.ChatData
+	var err error
+	ctx := r.ctx
+	defer close(r.done)
+
+	// 等待上一轮回复写入记录, 即使本轮已被打断, 用户输入仍需按顺序记录
+	if r.prev != nil {
+		select {
+		case <-r.prev:
+		case <-e.ctx.Done():
+			return
+		}
+	}
+	if msg != "" {
+		if err = e.rs.AddUser(e.sessionId, msg); err != nil {
+			log.Error("user history err:", err)
+		}
+		e.risk.Inspect(risk.SourceUser, msg)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	// 根据对话记录构造上下文
+	history, err := e.rs.Load(e.sessionId)
+	if err != nil {
+		log.Error("load history err:", err)
+		return
+	}
 
 	// 流式响应的scanner
-	scanner, err := e.chatApp.StreamCall(msg, e.sessionId)
+	scanner, err := e.chatApp.StreamCall(e.window.build(history))
+	if err != nil {
+		log.Error("stream call err:", err)
+		return
+	}
+	// 被打断时关闭scanner, 让阻塞中的Next立即返回
+	stop := context.AfterFunc(ctx, func() { _ = scanner.Close() })
 	defer func() {
+		stop()
 		_ = scanner.Close()
+		// 合成本轮回复中剩余的文本
+		e.speak(&utterance{ctx: ctx, end: true})
+		e.risk.Inspect(risk.SourceAI, raw)
+		var herr error
 		switch {
+		case e.ctx.Err() != nil:
+			// 对话已结束, 不再写入
+		case ctx.Err() != nil:
+			herr = e.rs.AddInterruptedAi(e.sessionId, record)
 		case errors.Is(err, io.EOF):
-			e.aiHistory <- record
+			herr = e.rs.AddAi(e.sessionId, record)
 		default:
-			// 错误时写入异常值, 避免主协程无限等待
-			e.aiHistory <- "stop:" + err.Error()
+			log.Error("stream next err:", err)
+			if record != "" {
+				herr = e.rs.AddAi(e.sessionId, record)
+			}
+		}
+		if herr != nil {
+			log.Error("ai history err:", herr)
 		}
 	}()
 
 	// 将模型结果响应给前端
+	p := newParser()
+	// 学生处于中高风险时使用温和的语音
+	tn := e.styles.newTone(e.risk.Level() >= risk.LevelMedium)
+	var last uint64
 	for {
 		select {
-		case <-e.ctx.Done():
+		case <-ctx.Done():
 			return
 		default:
 			// 获取下一次响应
 			data, err = scanner.Next()
-			if err != nil {
+			if errors.Is(err, io.EOF) && ctx.Err() == nil {
+				// 模型没有在最后一个片段给出结束原因时, 输出解析器中剩余的内容
+				if rest := p.flush(); len(rest) > 0 {
+					tail := &dto.ChatData{Id: last + 1, SessionId: e.sessionId, Timestamp: time.Now().Unix()}
+					if werr := e.dispatch(ctx, tail, rest, tn, &record); werr != nil {
+						log.Error("write tail err:", werr)
+					}
+				}
+			}
+			if err != nil || ctx.Err() != nil {
 				return
 			}
-			// 第一次调用, 写入sessionId
-			if e.sessionId == "" {
-				e.sessionId = data.SessionId
+			data.SessionId = e.sessionId
+			last = data.Id
+			raw += data.Content
+			segments := p.feed(data.Content)
+			if data.Finish != "" {
+				segments = append(segments, p.flush()...)
 			}
-			// 风险分析
-			analyse(&data.Content)
-			data.Content = e.strip(data.Content)
-			// 写入文本, 用于音频合成
-			e.outw <- data.Content
-			// 写入响应 TODO: test待删除
-			log.Info("data: ", data)
-			err = e.ws.WriteJSON(data)
-			if err != nil {
+			if err = e.dispatch(ctx, data, segments, tn, &record); err != nil {
 				return
 			}
-			// 拼接聊天记录
-			record += data.Content
 		}
 	}
 }
 
+// dispatch 分发一次流式响应解析出的片段
+// 文本按情感合成语音并写入响应和聊天记录, 表情和动作作为单独的事件发送, 风险标记交给风险检测
+func (e *Engine) dispatch(ctx context.Context, data *dto.ChatData, segments []*segment, tn *tone, record *string) error {
+	e.watchdog.Active()
+	var speech strings.Builder
+	for _, seg := range segments {
+		switch seg.kind {
+		case segSpeech:
+			// 兼容旧提示词中的风险标记, 风险检测在本轮结束后对完整输出进行
+			text := e.risk.Strip(seg.content)
+			speech.WriteString(text)
+			// 写入文本, 按句切分后用于音频合成
+			e.speak(&utterance{ctx: ctx, text: text, style: tn.style(text)})
+			continue
+		case segRisk:
+			e.risk.Flag(seg.content)
+			continue
+		case segEmotion:
+			tn.tag(seg.content)
+		}
+		if err := e.ws.Send(consts.MsgEvent, &dto.ChatEvent{
+			Type:      seg.kind,
+			Content:   seg.content,
+			SessionId: e.sessionId,
+			Timestamp: time.Now().Unix(),
+		}); err != nil {
+			return err
+		}
+	}
+	data.Content = speech.String()
+	// 只有表情、动作或风险标记的片段不需要发送空文本, 结束标记仍需发送
+	if data.Content == "" && data.Finish == "" {
+		return nil
+	}
+	if err := e.ws.Send(consts.MsgText, data); err != nil {
+		return err
+	}
+	// 拼接聊天记录
+	*record += data.Content
+	return nil
+}
+
 // tts 初始化tts app 并启动发送和接受goroutine
+// 前端请求的语音格式不合法时结束对话; 合成服务不可用时降级为纯文字对话, 只消费待合成的文本
 func (e *Engine) tts() error {
-	err := e.ttsInit()
-	if err != nil {
-		return err
+	c := config.GetConfig()
+	tc, errno := newTtsConfig(&c.Tts, e.output)
+	if errno != nil {
+		_ = e.ws.Error(errno)
+		return errno
 	}
+	e.phrases = newPhraseCache(c, tc)
+	e.playlist = newPlaylist(e.play)
 	go e.ttsUp(e.outw)
+
+	app, err := model.NewTtsApp(c, tc)
+	if err != nil {
+		e.degrade(err)
+		return nil
+	}
+	e.ttsApp = app
+	if err = e.ttsInit(); err != nil {
+		e.degrade(err)
+		return nil
+	}
 	go e.ttsDown()
 	return nil
 }
@@ -307,66 +689,143 @@ func (e *Engine) ttsInit() (err error) {
 	return
 }
 
-// ttsUp 上传合成音频用文字 #消费者
-func (e *Engine) ttsUp(texts chan string) {
-	var err error
+// degrade 语音合成不可用时降级为纯文字对话, 只通知前端一次
+func (e *Engine) degrade(err error) {
+	if !e.degraded.CompareAndSwap(false, true) {
+		return
+	}
+	log.Error("tts unavailable, degrade to text:", err)
+	if werr := e.ws.Send(consts.MsgEvent, &dto.ChatEvent{
+		Type:      audioUnavailable,
+		Content:   "语音暂时不可用, 对话将以文字继续",
+		SessionId: e.sessionId,
+		Timestamp: time.Now().Unix(),
+	}); werr != nil {
+		log.Error("ws write audio unavailable err:", werr)
+	}
+}
 
-	for text := range texts {
-		if err = e.ttsApp.Send(text); err != nil {
-			log.Error("send tts err:", err)
+// ttsUp 将文字按句切分后上传合成 #消费者
+// 缓冲的文字超过最长等待时间时即使没有完整的句子也会上传, 保证首句音频的延迟
+// 命中缓存的句子直接下发, 不再上传; 降级后继续消费文本, 避免阻塞对话
+func (e *Engine) ttsUp(texts chan *utterance) {
+	seg := newSegmenter()
+	for {
+		var wait <-chan time.Time
+		if deadline, ok := seg.deadline(); ok {
+			wait = time.After(time.Until(deadline))
+		}
+
+		var out []*utterance
+		var flushed chan struct{}
+		select {
+		case <-e.ctx.Done():
 			return
+		case u := <-texts:
+			out, flushed = seg.push(u), u.flushed
+		case now := <-wait:
+			out = seg.expire(now)
+		}
+
+		for _, u := range out {
+			// 已被打断的回复或降级后不再合成
+			if (u.ctx != nil && u.ctx.Err() != nil) || e.degraded.Load() {
+				continue
+			}
+			key := e.phrases.key(u.text, u.style)
+			if frames := e.phrases.get(key); frames != nil {
+				e.playlist.replay(frames, u.caption)
+				continue
+			}
+			e.playlist.synthesize(u.text, u.caption, key)
+			if err := e.ttsApp.Send(u.text, u.style); err != nil {
+				e.degrade(err)
+			}
+		}
+		if flushed != nil {
+			close(flushed)
 		}
 	}
 }
 
-// ttsDown 获取生成的音频 #生产者
+// speak 写入待合成的文本, 对话结束后直接丢弃
+// 合成服务阻塞时缓冲可能写满, 不能无条件写入
+func (e *Engine) speak(u *utterance) {
+	select {
+	case e.outw <- u:
+	case <-e.ctx.Done():
+	}
+}
+
+// ttsDown 获取生成的音频和字幕 #生产者
 func (e *Engine) ttsDown() {
 	for {
 		select {
 		case <-e.ctx.Done():
 			return
 		default:
-			audio := e.ttsApp.Receive()
-			if audio != nil {
-				err := e.ws.WriteBytes(audio)
-				if err != nil {
-					log.Error("ws write audio err:", err)
+			frame := e.ttsApp.Receive()
+			if frame == nil {
+				// 对话仍在进行时合成服务断开, 降级为纯文字
+				if e.ctx.Err() == nil {
+					e.degrade(errTtsClosed)
 				}
+				return
+			}
+			if key, frames := e.playlist.receive(frame); key != "" {
+				e.phrases.put(key, frames)
 			}
 		}
 	}
 }
 
-// history 处理聊天记录 #消费者
-func (e *Engine) history(ai, user chan string) {
-	for {
-		select {
-		case his, ok := <-ai:
-			if !ok {
-				ai = nil
-			}
-			if his != "" {
-				if err := e.rs.AddAi(e.sessionId, his); err != nil {
-					log.Error("ai history err:", err)
-				}
-			}
-		case his, ok := <-user:
-			if !ok {
-				user = nil
-			}
-			if his != "" {
-				if err := e.rs.AddUser(e.sessionId, his); err != nil {
-					log.Error("user history err:", err)
-				}
-			}
+// play 下发一次合成响应, 生成字幕和口型并发送音频, caption为字幕显示的原文
+func (e *Engine) play(frame *model.TtsFrame, caption string) {
+	if sub := e.subtitles.next(frame, caption); sub != nil {
+		sub.SessionId = e.sessionId
+		sub.Timestamp = time.Now().Unix()
+		if err := e.ws.Send(consts.MsgSubtitle, sub); err != nil {
+			log.Error("ws write subtitle err:", err)
+		}
+		// 一句话的时间确定后生成口型, 合成比播放快, 前端收到时这句话通常还没有播放完
+		if sub.Type == subtitleEnd {
+			e.lipSync(sub)
+		}
+	}
+	if frame.Type == model.TtsAudio && len(frame.Audio) > 0 {
+		if err := e.ws.WriteAudio(e.subtitles.segment(), frame.Audio); err != nil {
+			log.Error("ws write audio err:", err)
 		}
 	}
 }
 
-// Close 结束本轮对话
+// lipSync 发送一句话的口型关键帧
+func (e *Engine) lipSync(sub *dto.ChatSubtitle) {
+	frames := getLipSync().keyframes(sub)
+	if len(frames) == 0 {
+		return
+	}
+	if err := e.ws.Send(consts.MsgViseme, &dto.ChatViseme{
+		Type:      visemeEvent,
+		SegmentId: sub.SegmentId,
+		Frames:    frames,
+		SessionId: e.sessionId,
+		Timestamp: time.Now().Unix(),
+	}); err != nil {
+		log.Error("ws write viseme err:", err)
+	}
+}
+
+// Close 结束本轮对话, 可以重复调用
 func (e *Engine) Close() {
+	e.closeOnce.Do(e.finish)
+}
+
+// finish 发送结束标识, 释放资源并生成报告
+func (e *Engine) finish() {
+	unsuspend(e)
 	// 发送结束标识
-	err := e.ws.WriteJSON(&dto.ChatEndResp{
+	err := e.ws.Send(consts.MsgEnd, &dto.ChatEndResp{
 		Code: 0,
 		Msg:  "对话结束",
 	})
@@ -377,7 +836,7 @@ func (e *Engine) Close() {
 	e.cancel()
 	_ = e.close()
 	// 发送对话历史记录消息, 需要用户对话轮数大于2
-	if e.round >= 2 {
+	if e.round.Load() >= 2 {
 		if err = e.provider.Produce(e.ctx, e.sessionId, e.userId, e.unitId, e.studentId, e.startTime, time.Now()); err != nil {
 			log.Error("消息发送失败, sessionId: ", e.sessionId)
 		}
@@ -388,48 +847,18 @@ func (e *Engine) Close() {
 }
 
 // close 释放相关资源
-// 所有的通道由close统一关闭, 生产者不负责关闭, 生成者由ctx.Done()关闭
-// 消费者需要因为所有的通道关闭结束
+// 通道不关闭, 超时和重连超时可能在其他协程结束对话, 生产者和消费者都由ctx.Done()结束
 func (e *Engine) close() (err error) {
-	close(e.aiHistory)
-	close(e.userHistory)
-	close(e.outw)
-	close(e.outv)
-	close(e.stop)
-
 	if err = e.ws.Close(); err != nil {
 		log.Error("close ws err:", err)
 	}
 	if err = e.chatApp.Close(); err != nil {
 		log.Error("close chat err:", err)
 	}
-	if err = e.ttsApp.Close(); err != nil {
-		log.Error("close tts err:", err)
-	}
-	return
-}
-
-// analyse 风险分析
-func analyse(text *string) {
-	if strings.Contains(*text, "&") {
-		if err := util.AlertEMail(); err != nil {
-			log.Error("邮件发送失败", err)
-		}
-		*text = strings.Replace(*text, "&", " ", -1)
-	}
-}
-
-// strip 去除括号内的内容
-func (e *Engine) strip(content string) string {
-	var sb strings.Builder
-	for _, s := range content {
-		if s == '(' || s == '（' || s == '[' {
-			e.parenthesis++
-		} else if s == ')' || s == '）' || s == ']' {
-			e.parenthesis--
-		} else if e.parenthesis == 0 {
-			sb.WriteRune(s)
+	if e.ttsApp != nil {
+		if err = e.ttsApp.Close(); err != nil {
+			log.Error("close tts err:", err)
 		}
 	}
-	return sb.String()
+	return
 }
@@ -0,0 +1,45 @@
+package chat
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// audioUnavailable 语音合成不可用的事件类型, 之后的对话只有文字
+const audioUnavailable = "audio_unavailable"
+
+// errTtsClosed 对话进行中合成服务断开
+var errTtsClosed = errors.New("tts closed")
+
+// ttsFormats 前端可以选择的合成语音格式
+var ttsFormats = []string{model.TtsFormatPCM, model.TtsFormatMP3, model.TtsFormatOggOpus}
+
+// ttsSampleRates 前端可以选择的采样频率
+var ttsSampleRates = []int{8000, 16000, 22050, 24000, 32000, 44100, 48000}
+
+// newTtsConfig 校验前端需要的合成语音格式, 未指定的字段使用供应商的默认值
+// 音色只能是配置中允许的音色
+func newTtsConfig(c *config.Tts, req *dto.TtsFormat) (*model.TtsConfig, *consts.Errno) {
+	if req == nil {
+		return nil, nil
+	}
+	if req.Format != "" && !slices.Contains(ttsFormats, req.Format) {
+		return nil, consts.ErrTtsFormat
+	}
+	if req.SampleRate != 0 && !slices.Contains(ttsSampleRates, req.SampleRate) {
+		return nil, consts.ErrTtsFormat
+	}
+	if req.Speaker != "" && !slices.Contains(c.Speakers, req.Speaker) {
+		return nil, consts.ErrTtsSpeaker
+	}
+	return &model.TtsConfig{
+		Format:     req.Format,
+		SampleRate: req.SampleRate,
+		Speaker:    req.Speaker,
+	}, nil
+}
@@ -0,0 +1,38 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+func TestNewTtsConfig(t *testing.T) {
+	c := &config.Tts{Speakers: []string{"default", "gentle"}}
+
+	if tc, errno := newTtsConfig(c, nil); tc != nil || errno != nil {
+		t.Errorf("nil request: %+v, %v", tc, errno)
+	}
+	tc, errno := newTtsConfig(c, &dto.TtsFormat{Format: "mp3", SampleRate: 16000, Speaker: "gentle"})
+	if errno != nil || tc.Format != "mp3" || tc.SampleRate != 16000 || tc.Speaker != "gentle" {
+		t.Errorf("valid request: %+v, %v", tc, errno)
+	}
+	if _, errno = newTtsConfig(c, &dto.TtsFormat{Speaker: "default"}); errno != nil {
+		t.Errorf("default speaker: %v", errno)
+	}
+
+	cases := []struct {
+		req  *dto.TtsFormat
+		want *consts.Errno
+	}{
+		{&dto.TtsFormat{Format: "wav"}, consts.ErrTtsFormat},
+		{&dto.TtsFormat{Format: "pcm", SampleRate: 12345}, consts.ErrTtsFormat},
+		{&dto.TtsFormat{Speaker: "unknown"}, consts.ErrTtsSpeaker},
+	}
+	for _, tt := range cases {
+		if _, errno = newTtsConfig(c, tt.req); errno != tt.want {
+			t.Errorf("%+v: got %v, want %v", tt.req, errno, tt.want)
+		}
+	}
+}
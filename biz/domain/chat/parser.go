@@ -0,0 +1,179 @@
+package chat
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// 模型输出中的片段类型
+const (
+	segSpeech  = "speech"
+	segEmotion = "emotion"
+	segAction  = "action"
+	segRisk    = "risk"
+)
+
+// maxTagLen 标记或括号内容的最大长度, 超出时认为标记未闭合, 按普通文本输出
+const maxTagLen = 32
+
+// segment 模型输出解析后的一个片段
+type segment struct {
+	kind    string
+	content string
+}
+
+// 旧格式中用括号表示的动作, 如（微笑）
+var brackets = map[rune]rune{'(': ')', '（': '）', '[': ']', '【': '】'}
+
+// parser 流式解析模型输出
+// 模型使用<emotion>/<action>/<risk>标记表情、动作和风险, 标记外的文本是需要说出的内容
+// 兼容旧格式中用括号包裹的动作; 标记和括号在maxTagLen内没有闭合时按普通文本输出, 避免吞掉后续内容
+type parser struct {
+	// pending 尚未确定含义的内容, 如可能是标记开头的"<emo"
+	pending strings.Builder
+	// tag 当前所在的标记, 为空时在标记外
+	tag string
+	// closer 当前所在括号的右括号, 为0时在括号外
+	closer rune
+	// content 标记或括号内已读取的内容
+	content strings.Builder
+	// speech 本次feed中产生的文本, 连续的文本合并为一个片段
+	speech strings.Builder
+	out    []*segment
+}
+
+func newParser() *parser {
+	return &parser{}
+}
+
+// feed 解析一段增量输出, 返回其中可以确定的片段
+func (p *parser) feed(chunk string) []*segment {
+	for _, r := range chunk {
+		p.next(r)
+	}
+	return p.take()
+}
+
+// flush 在输出结束时调用, 返回所有尚未确定的内容
+// 未闭合的标记按标记内容输出, 未闭合的括号和不完整的标记按普通文本输出
+func (p *parser) flush() []*segment {
+	switch {
+	case p.tag != "":
+		p.emit(p.tag, p.content.String())
+	case p.closer != 0:
+		p.text(p.content.String())
+	}
+	p.text(p.pending.String())
+	p.reset()
+	return p.take()
+}
+
+// next 处理一个字符
+func (p *parser) next(r rune) {
+	switch {
+	case p.tag != "":
+		p.inTag(r)
+	case p.closer != 0:
+		p.inBracket(r)
+	case p.pending.Len() > 0:
+		p.inOpen(r)
+	case r == '<':
+		p.pending.WriteRune(r)
+	default:
+		if closer, ok := brackets[r]; ok {
+			p.closer = closer
+			p.content.WriteRune(r)
+			return
+		}
+		p.speech.WriteRune(r)
+	}
+}
+
+// inOpen 读取可能的开始标记, 不是已知标记时作为普通文本
+func (p *parser) inOpen(r rune) {
+	p.pending.WriteRune(r)
+	open := p.pending.String()
+	for _, tag := range []string{segEmotion, segAction, segRisk} {
+		full := "<" + tag + ">"
+		if open == full {
+			p.pending.Reset()
+			p.tag = tag
+			return
+		}
+		if strings.HasPrefix(full, open) {
+			return
+		}
+	}
+	// 不是已知标记, 之前的内容作为文本, 当前字符可能是新标记或括号的开始, 重新处理
+	p.pending.Reset()
+	p.text(strings.TrimSuffix(open, string(r)))
+	p.next(r)
+}
+
+// inTag 读取标记内容, 直到结束标记
+func (p *parser) inTag(r rune) {
+	p.content.WriteRune(r)
+	content := p.content.String()
+	if end := "</" + p.tag + ">"; strings.HasSuffix(content, end) {
+		p.emit(p.tag, strings.TrimSuffix(content, end))
+		p.reset()
+		return
+	}
+	if utf8.RuneCountInString(content) > maxTagLen+len(p.tag)+3 {
+		// 标记没有闭合, 其中的内容仍需要说出
+		p.text(content)
+		p.reset()
+	}
+}
+
+// inBracket 读取括号内的动作, 直到右括号
+func (p *parser) inBracket(r rune) {
+	if r == p.closer {
+		content := p.content.String()
+		_, size := utf8.DecodeRuneInString(content)
+		p.emit(segAction, content[size:])
+		p.reset()
+		return
+	}
+	p.content.WriteRune(r)
+	if utf8.RuneCountInString(p.content.String()) > maxTagLen {
+		// 括号没有闭合, 按普通文本输出
+		p.text(p.content.String())
+		p.reset()
+	}
+}
+
+// text 追加需要说出的文本
+func (p *parser) text(s string) {
+	p.speech.WriteString(s)
+}
+
+// emit 输出一个标记片段, 之前的文本先输出以保持顺序
+func (p *parser) emit(kind, content string) {
+	p.cut()
+	if content = strings.TrimSpace(content); content != "" {
+		p.out = append(p.out, &segment{kind: kind, content: content})
+	}
+}
+
+// cut 将累积的文本作为一个片段输出
+func (p *parser) cut() {
+	if p.speech.Len() > 0 {
+		p.out = append(p.out, &segment{kind: segSpeech, content: p.speech.String()})
+		p.speech.Reset()
+	}
+}
+
+// take 返回并清空已确定的片段
+func (p *parser) take() []*segment {
+	p.cut()
+	out := p.out
+	p.out = nil
+	return out
+}
+
+func (p *parser) reset() {
+	p.tag = ""
+	p.closer = 0
+	p.content.Reset()
+}
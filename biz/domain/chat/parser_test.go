@@ -0,0 +1,77 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+)
+
+// parse 将输出按给定的片段依次输入解析器, 返回合并后的结果
+func parse(chunks ...string) []*segment {
+	p := newParser()
+	var out []*segment
+	for _, c := range chunks {
+		out = append(out, p.feed(c)...)
+	}
+	out = append(out, p.flush()...)
+
+	// 合并相邻的文本片段, 便于比较
+	var merged []*segment
+	for _, seg := range out {
+		if n := len(merged); n > 0 && seg.kind == segSpeech && merged[n-1].kind == segSpeech {
+			merged[n-1] = &segment{kind: segSpeech, content: merged[n-1].content + seg.content}
+			continue
+		}
+		merged = append(merged, seg)
+	}
+	return merged
+}
+
+func format(segments []*segment) string {
+	parts := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		parts = append(parts, seg.kind+":"+seg.content)
+	}
+	return strings.Join(parts, "|")
+}
+
+func TestParser(t *testing.T) {
+	cases := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"plain", []string{"你好呀, ", "今天过得怎么样?"}, "speech:你好呀, 今天过得怎么样?"},
+		{"tags", []string{"<emotion>happy</emotion>太好了<action>点头</action>!"}, "emotion:happy|speech:太好了|action:点头|speech:!"},
+		{"split tag", []string{"<emo", "tion>sa", "d</emot", "ion>别难过"}, "emotion:sad|speech:别难过"},
+		{"risk", []string{"我在这里陪你<risk>high: 提到自伤</risk>"}, "speech:我在这里陪你|risk:high: 提到自伤"},
+		{"legacy bracket", []string{"（微笑", "）你好"}, "action:微笑|speech:你好"},
+		{"unknown tag", []string{"1<2, a<b>c"}, "speech:1<2, a<b>c"},
+		{"double open", []string{"<<action>挥手</action>"}, "speech:<|action:挥手"},
+		{"unclosed bracket", []string{"(注意", strings.Repeat("很长的内容", 10)}, "speech:(注意" + strings.Repeat("很长的内容", 10)},
+		{"unclosed bracket at end", []string{"好的(其实"}, "speech:好的(其实"},
+		{"unclosed tag at end", []string{"<emotion>calm"}, "emotion:calm"},
+		{"partial tag at end", []string{"再见<emo"}, "speech:再见<emo"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := format(parse(c.chunks...)); got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParser_FeedIsIncremental(t *testing.T) {
+	p := newParser()
+	// 普通文本不需要等待后续输出
+	if got := format(p.feed("你好")); got != "speech:你好" {
+		t.Errorf("feed = %q", got)
+	}
+	// 可能是标记开头的内容需要等待
+	if got := p.feed("<act"); len(got) != 0 {
+		t.Errorf("feed = %q, want nothing", format(got))
+	}
+	if got := format(p.feed("ion>笑</action>")); got != "action:笑" {
+		t.Errorf("feed = %q", got)
+	}
+}
@@ -0,0 +1,328 @@
+package chat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/cache"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+	rs "github.com/xh-polaris/psych-digital/biz/infrastructure/redis"
+)
+
+// 常用语句缓存的存储位置
+const (
+	phraseStoreRedis = "redis"
+	phraseStoreDisk  = "disk"
+)
+
+// 常用语句缓存的默认参数
+const (
+	phraseMaxBytes   = 64 << 20
+	phraseMaxEntries = 2000
+	phraseMaxChars   = 20
+	phrasePrefix     = "tts:phrase:"
+)
+
+var (
+	phraseStore cache.Store
+	phraseOnce  sync.Once
+)
+
+// getPhraseStore 返回全局的常用语句缓存存储, 未配置或初始化失败时返回nil
+func getPhraseStore() cache.Store {
+	phraseOnce.Do(func() {
+		c := config.GetConfig()
+		switch c.TtsCache.Store {
+		case phraseStoreRedis:
+			maxEntries := c.TtsCache.MaxEntries
+			if maxEntries <= 0 {
+				maxEntries = phraseMaxEntries
+			}
+			phraseStore = cache.NewRedisStore(rs.NewRedis(c), phrasePrefix, maxEntries)
+		case phraseStoreDisk:
+			maxBytes := c.TtsCache.MaxBytes
+			if maxBytes <= 0 {
+				maxBytes = phraseMaxBytes
+			}
+			store, err := cache.NewDiskStore(c.TtsCache.Dir, maxBytes)
+			if err != nil {
+				log.Error("open tts cache err:", err)
+				return
+			}
+			phraseStore = store
+		}
+	})
+	return phraseStore
+}
+
+// phraseCache 一次对话使用的常用语句缓存, 按音色、音频格式、语音风格和归一化的文本寻址
+// 为nil时不缓存
+type phraseCache struct {
+	store cache.Store
+	// voice 音色和音频格式, 同一次对话中不变
+	voice    string
+	maxChars int
+}
+
+// newPhraseCache 创建本次对话的缓存, 未配置缓存时返回nil
+func newPhraseCache(c *config.Config, tc *model.TtsConfig) *phraseCache {
+	store := getPhraseStore()
+	if store == nil {
+		return nil
+	}
+	maxChars := c.TtsCache.MaxChars
+	if maxChars <= 0 {
+		maxChars = phraseMaxChars
+	}
+	return &phraseCache{store: store, voice: phraseVoice(c, tc), maxChars: maxChars}
+}
+
+// phraseVoice 缓存区分的音色和音频格式, 未指定音色时使用供应商配置的默认音色
+func phraseVoice(c *config.Config, tc *model.TtsConfig) string {
+	if tc == nil {
+		tc = &model.TtsConfig{}
+	}
+	provider, speaker := c.Tts.Provider, tc.Speaker
+	if provider == "" {
+		provider = consts.ProviderVolc
+	}
+	if speaker == "" {
+		switch provider {
+		case consts.ProviderVolc:
+			speaker = c.VolcTts.Speaker
+		case consts.ProviderOpenAI:
+			speaker = c.OpenAITts.Voice
+		}
+	}
+	return fmt.Sprintf("%s/%s/%s/%d", provider, speaker, tc.Format, tc.SampleRate)
+}
+
+// key 计算一句话的缓存key, 太长或没有文字的句子不缓存, 返回空
+func (p *phraseCache) key(text string, style *model.VoiceStyle) string {
+	if p == nil {
+		return ""
+	}
+	norm := normalizePhrase(text)
+	if norm == "" || utf8.RuneCountInString(norm) > p.maxChars {
+		return ""
+	}
+	if style == nil {
+		style = &model.VoiceStyle{}
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%s", p.voice, style.Emotion, style.Rate, style.Volume, norm)))
+	return hex.EncodeToString(sum[:])
+}
+
+// get 读取缓存的一句话, 依次为句子开始、音频和句子结束, 未命中时返回nil
+func (p *phraseCache) get(key string) []*model.TtsFrame {
+	if p == nil || key == "" {
+		return nil
+	}
+	data, err := p.store.Get(key)
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			log.Error("get tts cache err:", err)
+		}
+		return nil
+	}
+	var frames []*model.TtsFrame
+	if err = json.Unmarshal(data, &frames); err != nil {
+		log.Error("unmarshal tts cache err:", err)
+		return nil
+	}
+	return frames
+}
+
+// put 在后台写入合成的一句话, 避免阻塞音频下发
+func (p *phraseCache) put(key string, frames []*model.TtsFrame) {
+	if p == nil || key == "" {
+		return
+	}
+	data, err := json.Marshal(frames)
+	if err != nil {
+		log.Error("marshal tts cache err:", err)
+		return
+	}
+	go func() {
+		if err := p.store.Set(key, data); err != nil {
+			log.Error("set tts cache err:", err)
+		}
+	}()
+}
+
+// normalizePhrase 归一化文本, 只保留字母和数字, 标点和空白不影响缓存
+func normalizePhrase(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// phrase 已提交的一句话
+type phrase struct {
+	// text 归一化的文本
+	text string
+	// caption 字幕显示的原文
+	caption string
+	// key 缓存key, 不缓存时为空
+	key string
+	// frames 命中缓存的响应, 实时合成时为nil
+	frames []*model.TtsFrame
+}
+
+// playlist 保证命中缓存的句子和实时合成的句子按提交顺序下发
+// 实时合成的句子在收到对应的句子结束后出队, 排在其后的缓存句子随即下发; 没有正在合成的句子时缓存句子直接下发
+type playlist struct {
+	mu sync.Mutex
+	// play 下发一次合成响应及其所属句子的字幕原文, 在锁内调用以保证顺序
+	// 句子与提交的文本对不上时字幕原文为空, 以供应商返回的文本为准
+	play func(frame *model.TtsFrame, caption string)
+	// queue 已提交但尚未下发完的句子, 队首总是实时合成的句子
+	queue []*phrase
+	// spoken 已合成结束但还不足以对应队首句子的文本, 供应商可能将一次提交拆成多句返回
+	spoken string
+	// recording 正在合成的句子收到的响应, 用于写入缓存
+	recording []*model.TtsFrame
+}
+
+// newPlaylist 创建下发队列
+func newPlaylist(play func(frame *model.TtsFrame, caption string)) *playlist {
+	return &playlist{play: play}
+}
+
+// synthesize 记录一句提交实时合成的话, text为提交合成的文本, caption为字幕显示的原文
+func (p *playlist) synthesize(text, caption, key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = append(p.queue, &phrase{text: normalizePhrase(text), caption: caption, key: key})
+}
+
+// replay 下发命中缓存的一句话, 前面还有正在合成的句子时排队等待
+// 缓存的响应中是合成时的文本, 字幕使用本次的原文
+func (p *playlist) replay(frames []*model.TtsFrame, caption string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.queue) > 0 {
+		p.queue = append(p.queue, &phrase{frames: frames, caption: caption})
+		return
+	}
+	p.playAll(frames, caption)
+}
+
+// playAll 下发一句话的全部响应, 调用方需持有锁
+func (p *playlist) playAll(frames []*model.TtsFrame, caption string) {
+	for _, f := range frames {
+		p.play(f, caption)
+	}
+}
+
+// caption 实时合成的句子恰好对应队首提交的一句话时返回其原文
+// text为供应商返回的句子文本, 为空时只要求是队首句子的开头
+func (p *playlist) caption(text string) string {
+	if len(p.queue) == 0 || p.queue[0].frames != nil || p.spoken != "" {
+		return ""
+	}
+	if head := p.queue[0]; text == "" || normalizePhrase(text) == head.text {
+		return head.caption
+	}
+	return ""
+}
+
+// receive 下发一次实时合成的响应, 句子结束时出队并下发排在其后的缓存句子
+// 一次提交恰好合成为一句完整的话时返回其缓存key和全部响应, 否则key为空
+func (p *playlist) receive(frame *model.TtsFrame) (string, []*model.TtsFrame) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch frame.Type {
+	case model.TtsSentenceStart:
+		p.play(frame, p.caption(frame.Text))
+		p.recording = []*model.TtsFrame{frame}
+		return "", nil
+	case model.TtsAudio:
+		p.play(frame, "")
+		if p.recording != nil {
+			p.recording = append(p.recording, frame)
+		}
+		return "", nil
+	}
+	// 结束事件的文本为空时无法确认对应关系, 字幕沿用开始事件
+	caption := ""
+	if frame.Text != "" {
+		caption = p.caption(frame.Text)
+	}
+	p.play(frame, caption)
+
+	recorded := p.recording
+	if recorded != nil {
+		recorded = append(recorded, frame)
+	}
+	p.recording = nil
+	ended := normalizePhrase(frame.Text)
+	p.spoken += ended
+
+	var done []*phrase
+	for len(p.queue) > 0 && p.queue[0].frames == nil {
+		head := p.queue[0]
+		if head.text == "" && ended != "" {
+			// 没有文字的句子供应商可能不合成, 直接出队
+			p.queue = p.queue[1:]
+			continue
+		}
+		if strings.HasPrefix(p.spoken, head.text) {
+			p.spoken = p.spoken[len(head.text):]
+			p.queue = p.queue[1:]
+			done = append(done, head)
+			if p.spoken == "" {
+				break
+			}
+			continue
+		}
+		if !strings.HasPrefix(head.text, p.spoken) {
+			// 文本对不上时以供应商为准, 丢弃队首避免后面的缓存句子一直等待
+			log.Info("tts sentence mismatch: %s, %s", head.text, p.spoken)
+			p.queue = p.queue[1:]
+			p.spoken = ""
+		}
+		break
+	}
+
+	for len(p.queue) > 0 && p.queue[0].frames != nil {
+		p.playAll(p.queue[0].frames, p.queue[0].caption)
+		p.queue = p.queue[1:]
+	}
+
+	if len(done) == 1 && p.spoken == "" && done[0].key != "" && done[0].text == ended && recorded != nil {
+		return done[0].key, recorded
+	}
+	return "", nil
+}
+
+// pending 是否还有提交后尚未下发完的句子
+func (p *playlist) pending() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue) > 0
+}
+
+// reset 打断后丢弃所有未下发的句子
+func (p *playlist) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = nil
+	p.spoken = ""
+	p.recording = nil
+}
@@ -0,0 +1,125 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+)
+
+func TestPhraseCache_Key(t *testing.T) {
+	p := &phraseCache{voice: "volc/a/pcm/24000", maxChars: 10}
+	k := p.key("你好呀, 很高兴见到你！", nil)
+	if k == "" || k != p.key(" 你好呀 很高兴见到你。", &model.VoiceStyle{}) {
+		t.Error("punctuation and spaces should not change the key")
+	}
+	if k == p.key("你好呀, 很高兴见到你！", &model.VoiceStyle{Emotion: "happy"}) {
+		t.Error("emotion should change the key")
+	}
+	if other := (&phraseCache{voice: "volc/b/pcm/24000", maxChars: 10}).key("你好呀, 很高兴见到你！", nil); other == k {
+		t.Error("speaker should change the key")
+	}
+	if p.key("……", nil) != "" || p.key("这是一句超过了十个字的很长的话", nil) != "" {
+		t.Error("empty and long sentences should not be cached")
+	}
+	var disabled *phraseCache
+	if disabled.key("你好", nil) != "" || disabled.get("x") != nil {
+		t.Error("nil cache should be disabled")
+	}
+}
+
+// sentence 生成一句话的合成响应
+func sentence(text string) []*model.TtsFrame {
+	return []*model.TtsFrame{
+		{Type: model.TtsSentenceStart, Text: text},
+		{Type: model.TtsAudio, Audio: []byte(text), Duration: 100},
+		{Type: model.TtsSentenceEnd, Text: text},
+	}
+}
+
+func TestPlaylist(t *testing.T) {
+	var played []string
+	pl := newPlaylist(func(f *model.TtsFrame, _ string) {
+		if f.Type == model.TtsAudio {
+			played = append(played, string(f.Audio))
+		}
+	})
+
+	// 没有正在合成的句子时缓存直接下发
+	pl.replay(sentence("你好"), "你好")
+	// 缓存句子排在实时合成的句子之后
+	pl.synthesize("今天过得怎么样？", "今天过得怎么样？", "k1")
+	pl.replay(sentence("我在听"), "我在听")
+	if len(played) != 1 {
+		t.Fatalf("cached phrase should wait, played %v", played)
+	}
+	var key string
+	var frames []*model.TtsFrame
+	for _, f := range sentence("今天过得怎么样") {
+		key, frames = pl.receive(f)
+	}
+	if want := []string{"你好", "今天过得怎么样", "我在听"}; len(played) != 3 || played[1] != want[1] || played[2] != want[2] {
+		t.Errorf("played %v, want %v", played, want)
+	}
+	if key != "k1" || len(frames) != 3 {
+		t.Errorf("recorded %q %d frames", key, len(frames))
+	}
+
+	// 供应商将一次提交拆成两句时, 第二句结束后才出队, 且不写入缓存
+	played = nil
+	pl.synthesize("嗯。我明白。", "嗯。我明白。", "k2")
+	pl.replay(sentence("慢慢说"), "慢慢说")
+	for _, f := range sentence("嗯") {
+		key, _ = pl.receive(f)
+	}
+	if len(played) != 1 || key != "" {
+		t.Fatalf("after first half: played %v, key %q", played, key)
+	}
+	for _, f := range sentence("我明白") {
+		key, _ = pl.receive(f)
+	}
+	if len(played) != 3 || played[2] != "慢慢说" || key != "" {
+		t.Errorf("after second half: played %v, key %q", played, key)
+	}
+
+	// 打断后丢弃排队的句子
+	played = nil
+	pl.synthesize("还有吗", "还有吗", "")
+	pl.replay(sentence("好的"), "好的")
+	pl.reset()
+	pl.replay(sentence("再见"), "再见")
+	if len(played) != 1 || played[0] != "再见" {
+		t.Errorf("after reset: played %v", played)
+	}
+}
+
+func TestPlaylist_Caption(t *testing.T) {
+	captions := map[model.TtsFrameType][]string{}
+	pl := newPlaylist(func(f *model.TtsFrame, caption string) {
+		captions[f.Type] = append(captions[f.Type], caption)
+	})
+
+	// 字幕显示原文, 而不是转换后朗读的文本
+	pl.synthesize("完成了百分之五十。", "完成了50%。", "k1")
+	pl.replay(sentence("慢慢说"), "慢慢说")
+	for _, f := range sentence("完成了百分之五十") {
+		pl.receive(f)
+	}
+	// 供应商拆句时对不上原文, 使用供应商的文本
+	pl.synthesize("嗯。我明白。", "嗯。我明白。", "")
+	for _, f := range append(sentence("嗯"), sentence("我明白")...) {
+		pl.receive(f)
+	}
+	want := []string{"完成了50%。", "慢慢说", "", ""}
+	for _, typ := range []model.TtsFrameType{model.TtsSentenceStart, model.TtsSentenceEnd} {
+		got := captions[typ]
+		if len(got) != len(want) {
+			t.Fatalf("%v captions %q, want %q", typ, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%v captions %q, want %q", typ, got, want)
+				break
+			}
+		}
+	}
+}
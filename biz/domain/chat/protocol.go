@@ -0,0 +1,58 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// negotiate 协商协议版本, 使用前端和服务端中较低的版本
+func negotiate(version int) int {
+	return max(consts.ProtocolLegacy, min(version, consts.ProtocolLatest))
+}
+
+// decodeReq 解析前端的文本消息, v1协议中命令由信封的type决定
+func decodeReq(version int, data []byte) (*dto.ChatReq, error) {
+	req := &dto.ChatReq{}
+	if version == consts.ProtocolLegacy {
+		return req, json.Unmarshal(data, req)
+	}
+	var env dto.Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+	switch env.Type {
+	case consts.MsgInput:
+		var in dto.ChatReq
+		if err := json.Unmarshal(env.Data, &in); err != nil {
+			return nil, err
+		}
+		req.Msg = in.Msg
+	case consts.MsgPing:
+		req.Cmd = consts.Ping
+	case consts.MsgInterrupt:
+		req.Cmd = consts.InterruptCmd
+	case consts.MsgEnd:
+		req.Cmd = consts.EndCmd
+	default:
+		return nil, fmt.Errorf("unknown message type %q", env.Type)
+	}
+	return req, nil
+}
+
+// decodeAudio 获取前端上传的音频, v1协议去掉音频帧的头部
+func decodeAudio(version int, frame []byte) ([]byte, error) {
+	if version == consts.ProtocolLegacy {
+		return frame, nil
+	}
+	h, audio, err := dto.UnmarshalAudio(frame)
+	if err != nil {
+		return nil, err
+	}
+	if h.Kind != consts.AudioMic {
+		return nil, dto.ErrAudioHeader
+	}
+	return audio, nil
+}
@@ -0,0 +1,53 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+func TestNegotiate(t *testing.T) {
+	for v, want := range map[int]int{-1: 0, 0: 0, 1: 1, 9: consts.ProtocolLatest} {
+		if got := negotiate(v); got != want {
+			t.Errorf("negotiate(%d) = %d, want %d", v, got, want)
+		}
+	}
+}
+
+func TestDecodeReq(t *testing.T) {
+	cases := []struct {
+		version int
+		data    string
+		want    dto.ChatReq
+	}{
+		{0, `{"cmd":2}`, dto.ChatReq{Cmd: consts.InterruptCmd}},
+		{0, `{"cmd":0,"msg":"你好"}`, dto.ChatReq{Msg: "你好"}},
+		{1, `{"v":1,"type":"input","data":{"cmd":-1,"msg":"你好"}}`, dto.ChatReq{Msg: "你好"}},
+		{1, `{"v":1,"type":"ping"}`, dto.ChatReq{Cmd: consts.Ping}},
+		{1, `{"v":1,"type":"end"}`, dto.ChatReq{Cmd: consts.EndCmd}},
+	}
+	for _, c := range cases {
+		got, err := decodeReq(c.version, []byte(c.data))
+		if err != nil || *got != c.want {
+			t.Errorf("decodeReq(%s) = %+v, %v", c.data, got, err)
+		}
+	}
+	if _, err := decodeReq(1, []byte(`{"cmd":2}`)); err == nil {
+		t.Error("v1 message without type should fail")
+	}
+}
+
+func TestDecodeAudio(t *testing.T) {
+	frame := (&dto.AudioHeader{Version: 1, Kind: consts.AudioMic, Seq: 3}).Append([]byte{1, 2})
+	if audio, err := decodeAudio(1, frame); err != nil || len(audio) != 2 {
+		t.Fatalf("audio = %v, %v", audio, err)
+	}
+	if audio, _ := decodeAudio(0, frame); len(audio) != len(frame) {
+		t.Fatal("legacy audio should be unchanged")
+	}
+	tts := (&dto.AudioHeader{Version: 1, Kind: consts.AudioTts}).Append(nil)
+	if _, err := decodeAudio(1, tts); err == nil {
+		t.Fatal("tts frame from client should fail")
+	}
+}
@@ -0,0 +1,129 @@
+package chat
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hertz-contrib/websocket"
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/domain"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// 断线重连的默认参数
+const (
+	// defaultResumeGrace 连接断开后保留对话的时长, 单位秒
+	defaultResumeGrace = 60
+	// defaultResumeBuffer 等待重连期间缓存的消息大小上限, 单位字节
+	defaultResumeBuffer = 4 << 20
+)
+
+// suspension 一个等待重连的对话
+type suspension struct {
+	engine *Engine
+	timer  *time.Timer
+}
+
+var (
+	// suspended 等待重连的对话, 按resume token索引
+	suspended   = map[string]*suspension{}
+	suspendedMu sync.Mutex
+)
+
+// resumeGrace 连接断开后保留对话的时长
+func resumeGrace(c *config.Chat) time.Duration {
+	if c.ResumeGrace <= 0 {
+		return defaultResumeGrace * time.Second
+	}
+	return time.Duration(c.ResumeGrace) * time.Second
+}
+
+// resumeBuffer 等待重连期间缓存的消息大小上限
+func resumeBuffer(c *config.Chat) int {
+	if c.ResumeBuffer <= 0 {
+		return defaultResumeBuffer
+	}
+	return c.ResumeBuffer
+}
+
+// Suspend 连接断开后保留对话, 宽限期内没有重连时结束对话
+// 进行中的回复和语音合成继续进行, 发送的消息被记录下来, 重连后重放
+func (e *Engine) Suspend() {
+	// 已因超时结束的对话不再等待重连
+	if e.ctx.Err() != nil {
+		return
+	}
+	e.offline.Store(true)
+	e.ws.Detach()
+	s := &suspension{engine: e}
+	suspendedMu.Lock()
+	suspended[e.resumeToken] = s
+	s.timer = time.AfterFunc(resumeGrace(&config.GetConfig().Chat), func() {
+		suspendedMu.Lock()
+		if suspended[e.resumeToken] != s {
+			suspendedMu.Unlock()
+			return
+		}
+		delete(suspended, e.resumeToken)
+		suspendedMu.Unlock()
+		log.Info("对话重连超时, sessionId: %s", e.sessionId)
+		e.Close()
+	})
+	suspendedMu.Unlock()
+	log.Info("连接断开, 等待重连, sessionId: %s", e.sessionId)
+}
+
+// Resume 断线重连, 恢复resume token对应的对话并重放前端没有收到的消息, mode为重连的接口对应的对话模式
+// 对话不存在、已超时、学号不一致或对话模式不一致时返回错误并关闭连接
+func Resume(conn *websocket.Conn, req *dto.ChatStartReq, mode string) (*Engine, error) {
+	e, errno := claim(req, mode)
+	if errno != nil {
+		ws := domain.NewWsHelper(conn)
+		ws.Protocol(negotiate(req.Version))
+		_ = ws.Error(errno)
+		_ = ws.Close()
+		return nil, errno
+	}
+
+	ack := e.startResp()
+	ack.Resumed = true
+	if err := e.ws.Attach(conn, req.Received, ack); err != nil {
+		// 新的连接也已断开, 继续等待重连
+		_ = conn.Close()
+		e.Suspend()
+		return nil, err
+	}
+	e.watchdog.Reset()
+	e.offline.Store(false)
+	log.Info("对话已恢复, sessionId: %s, 重放消息数: %d", e.sessionId, ack.Replay)
+	return e, nil
+}
+
+// claim 取出等待重连的对话, 学号必须与开始对话时一致, 只持有resume token不能恢复对话
+// 对话模式不一致时对话继续等待在正确的接口上重连
+func claim(req *dto.ChatStartReq, mode string) (*Engine, *consts.Errno) {
+	suspendedMu.Lock()
+	defer suspendedMu.Unlock()
+	s := suspended[req.ResumeToken]
+	if s == nil || req.StudentId == "" || req.StudentId != s.engine.studentId {
+		return nil, consts.ErrResumeExpired
+	}
+	if s.engine.mode != mode {
+		return nil, consts.ErrResumeMode
+	}
+	delete(suspended, req.ResumeToken)
+	s.timer.Stop()
+	return s.engine, nil
+}
+
+// unsuspend 对话结束时不再等待重连
+func unsuspend(e *Engine) {
+	suspendedMu.Lock()
+	defer suspendedMu.Unlock()
+	if s := suspended[e.resumeToken]; s != nil && s.engine == e {
+		s.timer.Stop()
+		delete(suspended, e.resumeToken)
+	}
+}
@@ -0,0 +1,44 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+func TestClaim(t *testing.T) {
+	e := &Engine{resumeToken: "token", studentId: "2024001", mode: ModeVoice}
+	s := &suspension{engine: e, timer: time.AfterFunc(time.Hour, func() {})}
+	suspendedMu.Lock()
+	suspended[e.resumeToken] = s
+	suspendedMu.Unlock()
+	defer unsuspend(e)
+
+	// 失败的重连不影响对话继续等待
+	cases := []struct {
+		req  *dto.ChatStartReq
+		mode string
+		want *consts.Errno
+	}{
+		{&dto.ChatStartReq{ResumeToken: "unknown", StudentId: "2024001"}, ModeVoice, consts.ErrResumeExpired},
+		{&dto.ChatStartReq{ResumeToken: "token"}, ModeVoice, consts.ErrResumeExpired},
+		{&dto.ChatStartReq{ResumeToken: "token", StudentId: "2024002"}, ModeVoice, consts.ErrResumeExpired},
+		{&dto.ChatStartReq{ResumeToken: "token", StudentId: "2024001"}, ModeChat, consts.ErrResumeMode},
+	}
+	for _, tt := range cases {
+		if got, errno := claim(tt.req, tt.mode); got != nil || errno != tt.want {
+			t.Errorf("%+v on %s: got %v, want %v", tt.req, tt.mode, errno, tt.want)
+		}
+	}
+
+	req := &dto.ChatStartReq{ResumeToken: "token", StudentId: "2024001"}
+	if got, errno := claim(req, ModeVoice); got != e || errno != nil {
+		t.Fatalf("claim = %v, %v", got, errno)
+	}
+	// 同一个对话只能恢复一次
+	if _, errno := claim(req, ModeVoice); errno != consts.ErrResumeExpired {
+		t.Errorf("second claim: %v", errno)
+	}
+}
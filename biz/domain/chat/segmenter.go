@@ -0,0 +1,186 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+)
+
+// 合成文本的切分参数
+const (
+	// minClause 在逗号等短停顿处切分时的最短长度, 过短的分句会让合成的语调不连贯
+	minClause = 8
+	// maxSentence 没有标点时强制切分的长度
+	maxSentence = 60
+	// maxWait 文本在缓冲区中的最长等待时间, 保证首句音频的延迟
+	maxWait = 600 * time.Millisecond
+)
+
+// 句末和分句处的标点
+const (
+	sentenceEnds = "。！？!?；;…\n"
+	clauseEnds   = "，,、：:"
+	closers      = "”’」』）)"
+)
+
+// segmenter 在对话模型的流式输出和语音合成之间按句切分文本
+// 模型的增量输出通常只有几个字, 逐个合成会让语音断断续续
+type segmenter struct {
+	buf   []rune
+	style *model.VoiceStyle
+	// ctx 缓冲区中文本所属回复的上下文, 回复被打断后丢弃
+	ctx context.Context
+	// since 缓冲区中最早的文本写入的时间
+	since time.Time
+}
+
+func newSegmenter() *segmenter {
+	return &segmenter{}
+}
+
+// push 写入一段文本, 返回其中已经完整的句子
+// 语音风格变化或回复结束时, 缓冲区中的文本会作为一句输出
+func (s *segmenter) push(u *utterance) []*utterance {
+	var out []*utterance
+	if s.ctx != nil && s.ctx.Err() != nil {
+		s.reset()
+	}
+	if u.end {
+		return s.appendFlush(out)
+	}
+	if u.ctx != nil && u.ctx.Err() != nil {
+		return out
+	}
+	if len(s.buf) > 0 && (u.ctx != s.ctx || !sameStyle(u.style, s.style)) {
+		out = s.appendFlush(out)
+	}
+	if len(s.buf) == 0 {
+		s.since = time.Now()
+	}
+	s.buf = append(s.buf, []rune(u.text)...)
+	s.style, s.ctx = u.style, u.ctx
+
+	for {
+		n := s.cut()
+		if n == 0 {
+			return out
+		}
+		out = s.appendCut(out, n)
+	}
+}
+
+// expire 缓冲区超过最长等待时间时输出其中的文本
+func (s *segmenter) expire(now time.Time) []*utterance {
+	if len(s.buf) == 0 || now.Sub(s.since) < maxWait {
+		return nil
+	}
+	return s.appendFlush(nil)
+}
+
+// deadline 缓冲区需要输出的时间, 缓冲区为空时返回false
+func (s *segmenter) deadline() (time.Time, bool) {
+	if len(s.buf) == 0 {
+		return time.Time{}, false
+	}
+	return s.since.Add(maxWait), true
+}
+
+// cut 返回缓冲区中第一句的长度, 没有完整的句子时返回0
+func (s *segmenter) cut() int {
+	for i, r := range s.buf {
+		switch {
+		case strings.ContainsRune(sentenceEnds, r):
+			return s.extend(i + 1)
+		case r == '.':
+			// 英文句号后需要有空白, 避免切开小数和缩写; 有序列表的序号如"1. "不是句末
+			if i+1 < len(s.buf) && unicode.IsSpace(s.buf[i+1]) && !s.listMarker(i) {
+				return s.extend(i + 1)
+			}
+		case strings.ContainsRune(clauseEnds, r) && i+1 >= minClause:
+			// 数字中的逗号和冒号, 如1,000和10:30
+			if i+1 < len(s.buf) && unicode.IsDigit(s.buf[i+1]) && i > 0 && unicode.IsDigit(s.buf[i-1]) {
+				continue
+			}
+			if i+1 == len(s.buf) && i > 0 && unicode.IsDigit(s.buf[i-1]) {
+				return 0
+			}
+			return i + 1
+		}
+	}
+	if len(s.buf) <= maxSentence {
+		return 0
+	}
+	// 强制切分时不切开数字和英文单词
+	n := maxSentence
+	for n > 1 && isWord(s.buf[n-1]) && isWord(s.buf[n]) {
+		n--
+	}
+	if n == 1 {
+		n = maxSentence
+	}
+	return n
+}
+
+// listMarker 位置i的句号之前是否只有行首的数字, 即有序列表的序号
+func (s *segmenter) listMarker(i int) bool {
+	j := i - 1
+	for j >= 0 && unicode.IsDigit(s.buf[j]) {
+		j--
+	}
+	if j == i-1 {
+		return false
+	}
+	for j >= 0 && s.buf[j] != '\n' && unicode.IsSpace(s.buf[j]) {
+		j--
+	}
+	return j < 0 || s.buf[j] == '\n'
+}
+
+// extend 句末连续的标点和右引号归入同一句, 如"……"和"。」"
+func (s *segmenter) extend(n int) int {
+	for n < len(s.buf) && (strings.ContainsRune(sentenceEnds, s.buf[n]) || strings.ContainsRune(closers, s.buf[n])) {
+		n++
+	}
+	return n
+}
+
+// appendCut 将缓冲区的前n个字符作为一句输出
+func (s *segmenter) appendCut(out []*utterance, n int) []*utterance {
+	raw := string(s.buf[:n])
+	text := speakable(raw)
+	s.buf = s.buf[n:]
+	s.since = time.Now()
+	// 只有标点的片段不需要合成
+	if strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return out
+	}
+	return append(out, &utterance{ctx: s.ctx, text: text, caption: strings.TrimSpace(raw), style: s.style})
+}
+
+// appendFlush 将缓冲区中的所有文本作为一句输出
+func (s *segmenter) appendFlush(out []*utterance) []*utterance {
+	if len(s.buf) > 0 {
+		out = s.appendCut(out, len(s.buf))
+	}
+	s.reset()
+	return out
+}
+
+func (s *segmenter) reset() {
+	s.buf = s.buf[:0]
+	s.style, s.ctx = nil, nil
+}
+
+func isWord(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func sameStyle(a, b *model.VoiceStyle) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
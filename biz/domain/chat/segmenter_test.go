@@ -0,0 +1,108 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+)
+
+func texts(us []*utterance) []string {
+	out := make([]string, 0, len(us))
+	for _, u := range us {
+		out = append(out, u.text)
+	}
+	return out
+}
+
+func TestSegmenter_Push(t *testing.T) {
+	ctx := context.Background()
+	s := newSegmenter()
+	var out []*utterance
+	for _, chunk := range []string{"你好", "呀, 我", "是小", "助手。今天", "过得怎么样", "？", "我们可以慢慢", "聊, 不着急……", "好吗"} {
+		out = append(out, s.push(&utterance{ctx: ctx, text: chunk})...)
+	}
+	out = append(out, s.push(&utterance{ctx: ctx, end: true})...)
+
+	want := []string{"你好呀, 我是小助手。", "今天过得怎么样？", "我们可以慢慢聊,", " 不着急……", "好吗"}
+	if got := texts(out); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSegmenter_Numbers(t *testing.T) {
+	ctx := context.Background()
+	s := newSegmenter()
+	// 小数点和数字中的逗号不切分, 输出时转换为读法
+	out := s.push(&utterance{ctx: ctx, text: "体温是37."})
+	out = append(out, s.push(&utterance{ctx: ctx, text: "5度, 有1,000人参加. Great"})...)
+	out = append(out, s.push(&utterance{ctx: ctx, end: true})...)
+	want := []string{"体温是三十七点五度,", " 有一千人参加.", " Great"}
+	if got := texts(out); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSegmenter_StyleAndInterrupt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newSegmenter()
+	happy := &model.VoiceStyle{Emotion: "happy"}
+
+	// 风格变化时输出之前的文本
+	out := s.push(&utterance{ctx: ctx, text: "太棒了"})
+	out = append(out, s.push(&utterance{ctx: ctx, text: "你做到了", style: happy})...)
+	if len(out) != 1 || out[0].text != "太棒了" || out[0].style != nil {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+
+	// 打断后丢弃缓冲的文本
+	cancel()
+	if out = s.push(&utterance{ctx: context.Background(), end: true}); len(out) != 0 {
+		t.Errorf("interrupted text should be dropped, got %q", texts(out))
+	}
+}
+
+func TestSegmenter_Expire(t *testing.T) {
+	s := newSegmenter()
+	if out := s.push(&utterance{ctx: context.Background(), text: "嗯嗯让我想一想"}); len(out) != 0 {
+		t.Fatalf("unexpected output: %q", texts(out))
+	}
+	deadline, ok := s.deadline()
+	if !ok {
+		t.Fatal("expected deadline")
+	}
+	if out := s.expire(deadline.Add(-time.Millisecond)); len(out) != 0 {
+		t.Errorf("expired too early: %q", texts(out))
+	}
+	if out := s.expire(deadline); len(out) != 1 || out[0].text != "嗯嗯让我想一想" {
+		t.Errorf("expire = %q", texts(out))
+	}
+	if _, ok = s.deadline(); ok {
+		t.Error("deadline after expire")
+	}
+
+	// 超长的文本强制切分, 不切开英文单词
+	long := strings.Repeat("好", maxSentence-2) + " hello world"
+	out := s.push(&utterance{ctx: context.Background(), text: long})
+	if len(out) != 1 || !strings.HasSuffix(out[0].text, " ") {
+		t.Errorf("force cut = %q", texts(out))
+	}
+}
+
+func TestSegmenter_NumberedList(t *testing.T) {
+	ctx := context.Background()
+	s := newSegmenter()
+	var out []*utterance
+	for _, chunk := range []string{"可以试试这些方法：\n1", ". 先做几次深呼吸", "。\n2. 出去走", "走。\n 3. 和朋友聊聊"} {
+		out = append(out, s.push(&utterance{ctx: ctx, text: chunk})...)
+	}
+	out = append(out, s.push(&utterance{ctx: ctx, end: true})...)
+
+	// 序号不单独成句, 由speakable去除, 不会读成"一."
+	want := []string{"可以试试这些方法：", "先做几次深呼吸。\n", "出去走走。\n", "和朋友聊聊"}
+	if got := texts(out); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+package chat
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 需要从合成文本中去除的markdown标记
+var (
+	mdLink   = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdPrefix = regexp.MustCompile(`(?m)^\s*(#{1,6}|>|[-*+]|\d+[.)、])\s+`)
+	mdInline = regexp.MustCompile("\\*+|_{2,}|~~|`+")
+)
+
+// 需要转换为中文读法的数字
+var (
+	reGrouped = regexp.MustCompile(`\d{1,3}(,\d{3})+`)
+	reDate    = regexp.MustCompile(`(\d{4})[-/年](\d{1,2})[-/月](\d{1,2})日?`)
+	reYear    = regexp.MustCompile(`(\d{4})年`)
+	reTime    = regexp.MustCompile(`(\d{1,2})[:：](\d{2})`)
+	rePercent = regexp.MustCompile(`(\d+(?:\.\d+)?)[%％]`)
+	reRange   = regexp.MustCompile(`(\d)\s*[~～-]\s*(\d)`)
+	reDecimal = regexp.MustCompile(`(\d+)\.(\d+)`)
+	reInteger = regexp.MustCompile(`\d+`)
+)
+
+// symbols 常见符号的读法
+var symbols = strings.NewReplacer(
+	"℃", "摄氏度", "°C", "摄氏度", "°", "度",
+	"+", "加", "=", "等于", "×", "乘", "÷", "除以",
+	"&", "和", "@", "艾特", "——", "，", "~", "", "～", "",
+)
+
+var chineseDigits = []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+
+// speakable 将一句回复转换为适合语音合成的文本
+// 去除markdown和emoji, 将日期、时间、百分数、小数和整数转换为中文读法, 将常见符号转换为文字
+func speakable(text string) string {
+	text = mdLink.ReplaceAllString(text, "$1")
+	text = mdPrefix.ReplaceAllString(text, "")
+	text = mdInline.ReplaceAllString(text, "")
+
+	text = reGrouped.ReplaceAllStringFunc(text, func(s string) string {
+		return strings.ReplaceAll(s, ",", "")
+	})
+	text = reDate.ReplaceAllStringFunc(text, func(s string) string {
+		m := reDate.FindStringSubmatch(s)
+		return readDigits(m[1]) + "年" + readValue(m[2]) + "月" + readValue(m[3]) + "日"
+	})
+	text = reYear.ReplaceAllStringFunc(text, func(s string) string {
+		return readDigits(reYear.FindStringSubmatch(s)[1]) + "年"
+	})
+	text = reTime.ReplaceAllStringFunc(text, func(s string) string {
+		m := reTime.FindStringSubmatch(s)
+		switch {
+		case m[2] == "00":
+			return readValue(m[1]) + "点"
+		case m[2][0] == '0':
+			return readValue(m[1]) + "点" + readDigits(m[2]) + "分"
+		default:
+			return readValue(m[1]) + "点" + readNumber(m[2]) + "分"
+		}
+	})
+	text = rePercent.ReplaceAllString(text, "百分之$1")
+	text = reRange.ReplaceAllString(text, "${1}到${2}")
+	text = reDecimal.ReplaceAllStringFunc(text, func(s string) string {
+		m := reDecimal.FindStringSubmatch(s)
+		return readNumber(m[1]) + "点" + readDigits(m[2])
+	})
+	text = reInteger.ReplaceAllStringFunc(text, readNumber)
+	text = symbols.Replace(text)
+
+	return strings.Map(func(r rune) rune {
+		// emoji及其修饰符、变体选择符和连接符
+		if unicode.Is(unicode.So, r) || unicode.Is(unicode.Sk, r) || unicode.Is(unicode.Variation_Selector, r) || r == '‍' {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// readDigits 逐位读出数字, 用于年份、电话号码等
+func readDigits(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		sb.WriteString(chineseDigits[r-'0'])
+	}
+	return sb.String()
+}
+
+// readNumber 按数值读出整数, 超过八位或以0开头的数字逐位读出
+func readNumber(s string) string {
+	if len(s) > 8 || (len(s) > 1 && s[0] == '0') {
+		return readDigits(s)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n == 0 {
+		return chineseDigits[0]
+	}
+
+	var out string
+	high, low := n/10000, n%10000
+	if high > 0 {
+		out = readSection(high) + "万"
+		if low > 0 && low < 1000 {
+			out += chineseDigits[0]
+		}
+	}
+	if low > 0 {
+		out += readSection(low)
+	}
+	return trimLeadingOne(out)
+}
+
+// readValue 读出日期、时间中的数值, 忽略开头的0
+func readValue(s string) string {
+	return readNumber(strings.TrimLeft(s, "0"))
+}
+
+// sectionUnits 一万以内各位的单位
+var sectionUnits = []struct {
+	pos  int
+	name string
+}{{1000, "千"}, {100, "百"}, {10, "十"}, {1, ""}}
+
+// readSection 读出一万以内的数字
+func readSection(n int) string {
+	var sb strings.Builder
+	zero := false
+	for _, unit := range sectionUnits {
+		d := n / unit.pos % 10
+		if d == 0 {
+			zero = sb.Len() > 0
+			continue
+		}
+		if zero {
+			sb.WriteString(chineseDigits[0])
+			zero = false
+		}
+		sb.WriteString(chineseDigits[d] + unit.name)
+	}
+	return sb.String()
+}
+
+// trimLeadingOne 十到十九读作"十几"而不是"一十几"
+func trimLeadingOne(s string) string {
+	if strings.HasPrefix(s, "一十") {
+		return strings.TrimPrefix(s, "一")
+	}
+	return s
+}
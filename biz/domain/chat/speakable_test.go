@@ -0,0 +1,38 @@
+package chat
+
+import "testing"
+
+func TestSpeakable(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"**别担心**, 我在这里😊", "别担心, 我在这里"},
+		{"- 深呼吸\n- 喝点水", "深呼吸\n喝点水"},
+		{"看看[这篇文章](https://example.com)吧", "看看这篇文章吧"},
+		{"考试在2025-06-07, 早上9:05开始", "考试在二零二五年六月七日, 早上九点零五分开始"},
+		{"2024年你考了95分", "二零二四年你考了九十五分"},
+		{"晚上10:00睡觉, 睡够8~9个小时", "晚上十点睡觉, 睡够八到九个小时"},
+		{"有30%的同学", "有百分之三十的同学"},
+		{"体温37.5℃", "体温三十七点五摄氏度"},
+		{"一共10010人, 热线12355", "一共一万零一十人, 热线一万二千三百五十五"},
+		{"电话13800138000", "电话一三八零零一三八零零零"},
+		{"1+1=2", "一加一等于二"},
+	}
+	for _, c := range cases {
+		if got := speakable(c.in); got != c.want {
+			t.Errorf("speakable(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestReadNumber(t *testing.T) {
+	cases := map[string]string{
+		"0": "零", "10": "十", "15": "十五", "101": "一百零一", "1010": "一千零一十",
+		"20000": "二万", "100000": "十万", "305001": "三十万五千零一", "007": "零零七",
+	}
+	for in, want := range cases {
+		if got := readNumber(in); got != want {
+			t.Errorf("readNumber(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
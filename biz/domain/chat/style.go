@@ -0,0 +1,115 @@
+package chat
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+// 语音风格对应的情感标记
+const (
+	styleCalm      = "calm"
+	styleGentle    = "gentle"
+	styleComfort   = "comfort"
+	styleHappy     = "happy"
+	styleSad       = "sad"
+	styleSurprised = "surprised"
+)
+
+// defaultStyles 默认的情感标记与语音风格的对应关系, 情感取值参考火山引擎多情感音色
+var defaultStyles = map[string]*model.VoiceStyle{
+	styleCalm:      {Emotion: "neutral"},
+	styleGentle:    {Emotion: "tender", Rate: -10, Volume: -10},
+	styleComfort:   {Emotion: "comfort", Rate: -15, Volume: -10},
+	styleHappy:     {Emotion: "happy", Rate: 10},
+	styleSad:       {Emotion: "sad", Rate: -15, Volume: -10},
+	styleSurprised: {Emotion: "surprised", Rate: 5},
+}
+
+// 没有情感标记时根据回复内容粗略估计情感
+var (
+	comfortWords = regexp.MustCompile(`别担心|没关系|不要紧|抱抱|陪着你|陪你|辛苦|难过|委屈|害怕|理解你|慢慢来`)
+	happyWords   = regexp.MustCompile(`太棒|真棒|好棒|开心|厉害|恭喜|哈哈|真好|太好了`)
+)
+
+// utterance 待合成的一段文本及其语音风格
+type utterance struct {
+	// ctx 所属回复的上下文, 回复被打断后不再合成
+	ctx context.Context
+	// text 提交合成的文本, 经过分句后转换为适合朗读的形式
+	text string
+	// caption 分句后的原文, 用于字幕
+	caption string
+	style   *model.VoiceStyle
+	// end 表示一轮回复结束, 不携带文本
+	end bool
+	// flushed 结束标记之前的文本全部提交合成后关闭, 可以为nil
+	flushed chan struct{}
+}
+
+// styles 情感标记与语音风格的对应关系
+type styles map[string]*model.VoiceStyle
+
+// newStyles 在默认风格上应用配置中的风格
+func newStyles(c *config.Chat) styles {
+	s := make(styles, len(defaultStyles)+len(c.Styles))
+	for tag, style := range defaultStyles {
+		s[tag] = style
+	}
+	for _, style := range c.Styles {
+		s[strings.ToLower(style.Tag)] = &model.VoiceStyle{Emotion: style.Emotion, Rate: style.Rate, Volume: style.Volume}
+	}
+	return s
+}
+
+// tone 一轮回复的语音风格
+// 模型通过<emotion>标记指定的情感对之后的文本生效, 没有标记时根据文本估计
+// 学生处于风险中时, 轻快的风格会被替换为安抚的风格
+type tone struct {
+	styles styles
+	// tagged 最近一次<emotion>标记的内容
+	tagged string
+	// estimated 根据已输出文本估计的情感, 流式输出的片段很短, 估计结果在本轮回复内保持
+	estimated string
+	// distressed 学生是否处于风险中
+	distressed bool
+}
+
+func (s styles) newTone(distressed bool) *tone {
+	return &tone{styles: s, distressed: distressed}
+}
+
+// tag 记录模型输出的情感标记
+func (t *tone) tag(emotion string) {
+	t.tagged = strings.ToLower(strings.TrimSpace(emotion))
+}
+
+// style 返回一段文本的语音风格, 无法确定时返回nil, 使用默认风格
+func (t *tone) style(text string) *model.VoiceStyle {
+	emotion := t.tagged
+	if emotion == "" {
+		if e := estimate(text); e != "" {
+			t.estimated = e
+		}
+		emotion = t.estimated
+	}
+	if t.distressed && emotion != styleComfort && emotion != styleGentle && emotion != styleSad {
+		emotion = styleComfort
+	}
+	return t.styles[emotion]
+}
+
+// estimate 根据关键词粗略估计回复的情感, 安抚优先
+func estimate(text string) string {
+	switch {
+	case comfortWords.MatchString(text):
+		return styleComfort
+	case happyWords.MatchString(text):
+		return styleHappy
+	default:
+		return ""
+	}
+}
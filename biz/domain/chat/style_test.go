@@ -0,0 +1,45 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+func TestTone_Style(t *testing.T) {
+	s := newStyles(&config.Chat{Styles: []config.VoiceStyle{{Tag: "Happy", Emotion: "excited", Rate: 20}}})
+
+	// 没有标记时根据文本估计
+	tn := s.newTone(false)
+	if got := tn.style("今天天气不错"); got != nil {
+		t.Errorf("neutral text style = %+v, want nil", got)
+	}
+	if got := tn.style("别担心, 我会一直陪着你"); got == nil || got.Emotion != "comfort" {
+		t.Errorf("comfort text style = %+v", got)
+	}
+	// 估计结果在本轮回复内保持
+	if got := tn.style("我们聊聊"); got == nil || got.Emotion != "comfort" {
+		t.Errorf("kept style = %+v", got)
+	}
+	// 配置覆盖默认风格
+	if got := s.newTone(false).style("太棒了!"); got == nil || got.Emotion != "excited" || got.Rate != 20 {
+		t.Errorf("happy text style = %+v", got)
+	}
+
+	// 标记优先于估计
+	tn.tag(" Surprised ")
+	if got := tn.style("别担心"); got == nil || got.Emotion != "surprised" {
+		t.Errorf("tagged style = %+v", got)
+	}
+
+	// 学生处于风险中时总是使用温和的风格
+	tn = s.newTone(true)
+	tn.tag("happy")
+	if got := tn.style("太棒了"); got == nil || got.Emotion != "comfort" {
+		t.Errorf("distressed style = %+v", got)
+	}
+	tn.tag("gentle")
+	if got := tn.style("我们慢慢说"); got == nil || got.Emotion != "tender" {
+		t.Errorf("distressed gentle style = %+v", got)
+	}
+}
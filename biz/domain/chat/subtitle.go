@@ -0,0 +1,97 @@
+package chat
+
+import (
+	"sync"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+)
+
+// 字幕事件类型
+const (
+	subtitleStart = "subtitle_start"
+	subtitleEnd   = "subtitle_end"
+)
+
+// subtitler 根据语音合成的句子边界和音频时长生成字幕
+// mp3等压缩格式无法计算音频时长, 此时以逐字时间戳中最后一个字的结束时间作为句子时长
+type subtitler struct {
+	mu sync.Mutex
+	// id 最近一句的id
+	id uint64
+	// offset 已输出音频的总时长, 单位毫秒
+	offset int64
+	// current 正在合成的句子
+	current *dto.ChatSubtitle
+}
+
+// next 处理一次合成响应, 返回需要发送的字幕事件, 音频响应只累计时长, 返回nil
+// caption为这句话的原文, 不为空时代替供应商返回的文本, 供应商的文本经过了朗读转换, 如数字转为中文读法
+func (s *subtitler) next(frame *model.TtsFrame, caption string) *dto.ChatSubtitle {
+	text := frame.Text
+	if caption != "" {
+		text = caption
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch frame.Type {
+	case model.TtsAudio:
+		s.offset += frame.Duration
+		return nil
+	case model.TtsSentenceStart:
+		s.id++
+		s.current = &dto.ChatSubtitle{Type: subtitleStart, SegmentId: s.id, Text: text, Start: s.offset}
+		return s.current
+	default:
+		// 没有收到开始事件时以结束事件为准, 开始时间未知
+		start := s.current
+		if start == nil {
+			s.id++
+			start = &dto.ChatSubtitle{SegmentId: s.id, Start: s.offset}
+		}
+		end := &dto.ChatSubtitle{
+			Type:      subtitleEnd,
+			SegmentId: start.SegmentId,
+			Text:      text,
+			Start:     start.Start,
+			End:       s.offset,
+		}
+		if end.Text == "" {
+			end.Text = start.Text
+		}
+		// 逐字时间戳是朗读的文本, 用于计算时长和口型
+		for _, w := range frame.Words {
+			end.Words = append(end.Words, &dto.ChatSubtitleWord{Word: w.Word, Start: w.Start, End: w.End})
+		}
+		if end.End == end.Start && len(frame.Words) > 0 {
+			end.End = end.Start + frame.Words[len(frame.Words)-1].End
+			s.offset = end.End
+		}
+		s.current = nil
+		return end
+	}
+}
+
+// segment 正在合成或最近一句的id, 用于标记音频所属的句子
+func (s *subtitler) segment() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+// elapsed 已输出音频的总时长, 单位毫秒
+func (s *subtitler) elapsed() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset
+}
+
+// reset 打断后前端清空了待播放的音频, 时间从0开始
+func (s *subtitler) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = 0
+	s.current = nil
+}
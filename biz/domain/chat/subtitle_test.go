@@ -0,0 +1,58 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+)
+
+func TestSubtitler(t *testing.T) {
+	s := &subtitler{}
+	if sub := s.next(&model.TtsFrame{Type: model.TtsAudio, Duration: 100}, ""); sub != nil {
+		t.Fatalf("audio should not produce subtitle: %+v", sub)
+	}
+
+	start := s.next(&model.TtsFrame{Type: model.TtsSentenceStart, Text: "你好呀"}, "")
+	if start.Type != subtitleStart || start.SegmentId != 1 || start.Start != 100 || start.Text != "你好呀" {
+		t.Errorf("unexpected start: %+v", start)
+	}
+	s.next(&model.TtsFrame{Type: model.TtsAudio, Duration: 400}, "")
+	s.next(&model.TtsFrame{Type: model.TtsAudio, Duration: 200}, "")
+	end := s.next(&model.TtsFrame{Type: model.TtsSentenceEnd, Words: []*model.TtsWord{{Word: "你", Start: 0, End: 150}}}, "")
+	if end.Type != subtitleEnd || end.SegmentId != 1 || end.Start != 100 || end.End != 700 || end.Text != "你好呀" || len(end.Words) != 1 {
+		t.Errorf("unexpected end: %+v", end)
+	}
+
+	// 打断后时间从0开始, id继续递增
+	s.next(&model.TtsFrame{Type: model.TtsSentenceStart, Text: "被打断"}, "")
+	s.reset()
+	end = s.next(&model.TtsFrame{Type: model.TtsSentenceEnd, Text: "新的一句"}, "")
+	if end.SegmentId != 3 || end.Start != 0 || end.End != 0 || end.Text != "新的一句" {
+		t.Errorf("unexpected end after reset: %+v", end)
+	}
+}
+
+func TestSubtitler_WordTiming(t *testing.T) {
+	// mp3等压缩格式的音频时长为0, 以最后一个字的结束时间作为句子时长
+	s := &subtitler{}
+	s.next(&model.TtsFrame{Type: model.TtsSentenceStart, Text: "你好"}, "")
+	s.next(&model.TtsFrame{Type: model.TtsAudio, Audio: []byte{1, 2, 3}}, "")
+	end := s.next(&model.TtsFrame{Type: model.TtsSentenceEnd, Words: []*model.TtsWord{{Word: "你", End: 200}, {Word: "好", Start: 200, End: 450}}}, "")
+	if end.Start != 0 || end.End != 450 {
+		t.Errorf("unexpected end: %+v", end)
+	}
+	start := s.next(&model.TtsFrame{Type: model.TtsSentenceStart, Text: "再见"}, "")
+	if start.Start != 450 {
+		t.Errorf("next sentence should start at 450, got %d", start.Start)
+	}
+}
+
+func TestSubtitler_Caption(t *testing.T) {
+	// 字幕使用原文, 逐字时间戳保留朗读的文本
+	s := &subtitler{}
+	start := s.next(&model.TtsFrame{Type: model.TtsSentenceStart, Text: "百分之五十"}, "50%")
+	end := s.next(&model.TtsFrame{Type: model.TtsSentenceEnd, Text: "百分之五十", Words: []*model.TtsWord{{Word: "百", End: 100}}}, "50%")
+	if start.Text != "50%" || end.Text != "50%" || end.Words[0].Word != "百" {
+		t.Errorf("unexpected caption: %+v %+v", start, end)
+	}
+}
@@ -0,0 +1,149 @@
+package chat
+
+import (
+	"context"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/domain"
+	"github.com/xh-polaris/psych-digital/biz/domain/risk"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// sessionTimeout 对话超时的事件类型, content为超时原因
+const sessionTimeout = "session_timeout"
+
+// 默认告别语
+const (
+	idleGoodbye = "我们今天先聊到这里吧, 如果还有想说的, 随时回来找我, 再见。"
+	maxGoodbye  = "今天我们已经聊了很久了, 先好好休息一下吧, 下次再继续聊, 再见。"
+)
+
+// farewellWait 等待告别语合成和播放的最长时间
+const farewellWait = 15 * time.Second
+
+// goodbyeLine 超时原因对应的告别语
+func goodbyeLine(c *config.Timeout, reason string) string {
+	if reason == domain.TimeoutMaxDuration {
+		if c.MaxGoodbye != "" {
+			return c.MaxGoodbye
+		}
+		return maxGoodbye
+	}
+	if c.IdleGoodbye != "" {
+		return c.IdleGoodbye
+	}
+	return idleGoodbye
+}
+
+// watch 检查对话超时 #生产者
+// 心跳超时时关闭连接, 按断线处理等待重连; 空闲或对话时长超时时告别并结束对话
+// 等待重连期间只检查对话时长
+func (e *Engine) watch() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case now := <-ticker.C:
+			reason := e.watchdog.Expired(now)
+			switch {
+			case reason == "":
+			case reason == domain.TimeoutMaxDuration:
+				e.windDown(reason)
+				return
+			case e.offline.Load():
+			case reason == domain.TimeoutHeartbeat:
+				log.Info("心跳超时, sessionId: %s", e.sessionId)
+				e.offline.Store(true)
+				_ = e.ws.Abort()
+			default:
+				e.windDown(reason)
+				return
+			}
+		}
+	}
+}
+
+// windDown 超时后数字人说出告别语, 播放完后结束对话
+func (e *Engine) windDown(reason string) {
+	log.Info("对话超时: %s, sessionId: %s", reason, e.sessionId)
+	// 对话时长超时时AI可能正在回复, 先打断
+	if reason == domain.TimeoutMaxDuration {
+		e.interrupt()
+	}
+	if err := e.ws.Send(consts.MsgEvent, &dto.ChatEvent{
+		Type:      sessionTimeout,
+		Content:   reason,
+		SessionId: e.sessionId,
+		Timestamp: time.Now().Unix(),
+	}); err != nil {
+		log.Error("ws write timeout err:", err)
+	}
+	e.farewell(e.newReply(), goodbyeLine(&config.GetConfig().Timeout, reason))
+	e.Close()
+}
+
+// farewell 输出并合成告别语, 等待前端播放完, 所有等待合计最长farewellWait
+func (e *Engine) farewell(r *reply, text string) {
+	defer close(r.done)
+	ctx, cancel := context.WithTimeout(e.ctx, farewellWait)
+	defer cancel()
+	if r.prev != nil {
+		select {
+		case <-r.prev:
+		case <-ctx.Done():
+		}
+	}
+	if err := e.rs.AddAi(e.sessionId, text); err != nil {
+		log.Error("ai history err:", err)
+	}
+	if err := e.ws.Send(consts.MsgText, &dto.ChatData{
+		Content:   text,
+		SessionId: e.sessionId,
+		Timestamp: time.Now().Unix(),
+		Finish:    consts.FinishTimeout,
+	}); err != nil {
+		log.Error("ws write goodbye err:", err)
+	}
+	// 连接已断开或只有文字时不需要等待播放
+	if e.offline.Load() || e.degraded.Load() {
+		return
+	}
+
+	start := e.subtitles.elapsed()
+	flushed := make(chan struct{})
+	tn := e.styles.newTone(e.risk.Level() >= risk.LevelMedium)
+	for _, u := range []*utterance{
+		{ctx: r.ctx, text: text, style: tn.style(text)},
+		{ctx: r.ctx, end: true, flushed: flushed},
+	} {
+		select {
+		case e.outw <- u:
+		case <-ctx.Done():
+			return
+		}
+	}
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+		return
+	}
+	for e.playlist.pending() {
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return
+		}
+	}
+	// 合成比播放快, 音频全部发送后再等待告别语的时长
+	if d := e.subtitles.elapsed() - start; d > 0 {
+		select {
+		case <-time.After(time.Duration(d) * time.Millisecond):
+		case <-ctx.Done():
+		}
+	}
+}
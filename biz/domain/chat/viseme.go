@@ -0,0 +1,261 @@
+package chat
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+// 口型事件类型
+const visemeEvent = "viseme"
+
+// pauseGap 字之间超过这个时长(毫秒)时插入闭口的关键帧
+const pauseGap = 80
+
+// 口型, 参考常见数字人使用的15种口型
+const (
+	visemeSil = "sil"
+	visemePP  = "PP"
+	visemeFF  = "FF"
+	visemeDD  = "DD"
+	visemeKK  = "kk"
+	visemeCH  = "CH"
+	visemeSS  = "SS"
+	visemeNN  = "nn"
+	visemeRR  = "RR"
+	visemeAA  = "aa"
+	visemeE   = "E"
+	visemeIH  = "ih"
+	visemeOH  = "oh"
+	visemeOU  = "ou"
+)
+
+// pinyinInitials 声母对应的口型, 按长度降序匹配
+var pinyinInitials = []struct {
+	initial string
+	viseme  string
+}{
+	{"zh", visemeCH}, {"ch", visemeCH}, {"sh", visemeCH},
+	{"b", visemePP}, {"p", visemePP}, {"m", visemePP}, {"f", visemeFF},
+	{"d", visemeDD}, {"t", visemeDD}, {"n", visemeNN}, {"l", visemeNN},
+	{"g", visemeKK}, {"k", visemeKK}, {"h", visemeKK},
+	{"j", visemeCH}, {"q", visemeCH}, {"x", visemeCH}, {"r", visemeRR},
+	{"z", visemeSS}, {"c", visemeSS}, {"s", visemeSS},
+}
+
+// vowelVisemes 韵母主要元音和英文元音字母对应的口型, 拼音中v表示ü
+var vowelVisemes = map[rune]string{
+	'a': visemeAA, 'o': visemeOH, 'e': visemeE, 'i': visemeIH, 'u': visemeOU, 'v': visemeOU, 'y': visemeIH, 'w': visemeOU,
+}
+
+// letterVisemes 英文辅音字母对应的口型
+var letterVisemes = map[rune]string{
+	'b': visemePP, 'p': visemePP, 'm': visemePP, 'f': visemeFF, 'v': visemeFF,
+	'd': visemeDD, 't': visemeDD, 'n': visemeNN, 'l': visemeNN, 'g': visemeKK, 'k': visemeKK,
+	'h': visemeKK, 'c': visemeKK, 'q': visemeKK, 'j': visemeCH, 'x': visemeSS, 's': visemeSS, 'z': visemeSS, 'r': visemeRR,
+}
+
+// toneMarks 带声调的拼音字母
+var toneMarks = strings.NewReplacer(
+	"ā", "a", "á", "a", "ǎ", "a", "à", "a", "ē", "e", "é", "e", "ě", "e", "è", "e",
+	"ī", "i", "í", "i", "ǐ", "i", "ì", "i", "ō", "o", "ó", "o", "ǒ", "o", "ò", "o",
+	"ū", "u", "ú", "u", "ǔ", "u", "ù", "u", "ǖ", "v", "ǘ", "v", "ǚ", "v", "ǜ", "v", "ü", "v",
+	"ń", "n", "ň", "n", "ǹ", "n", "ḿ", "m",
+)
+
+// lipSync 根据字幕生成口型关键帧
+// 汉字通过拼音词典转换为声母和韵母的口型, 词典中没有的字使用通用的开合口型
+type lipSync struct {
+	// dict 汉字对应的拼音, 不带声调
+	dict map[rune]string
+}
+
+var (
+	lips     *lipSync
+	lipsOnce sync.Once
+)
+
+// getLipSync 返回全局的口型生成器, 首次调用时加载配置中的拼音词典
+func getLipSync() *lipSync {
+	lipsOnce.Do(func() {
+		lips = &lipSync{dict: map[rune]string{}}
+		if path := config.GetConfig().Chat.PinyinDict; path != "" {
+			dict, err := loadPinyinDict(path)
+			if err != nil {
+				log.Error("load pinyin dict err:", err)
+				return
+			}
+			lips.dict = dict
+		}
+	})
+	return lips
+}
+
+// loadPinyinDict 加载拼音词典, 每行一个字, 多音字取第一个读音, #之后为注释
+// 支持"中 zhong1"和pinyin-data的"U+4E2D: zhōng,zhòng"两种格式
+func loadPinyinDict(path string) (map[rune]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	dict := map[rune]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(strings.Replace(line, ":", " ", 1))
+		if len(fields) < 2 {
+			continue
+		}
+		key, value := fields[0], fields[1]
+		var r rune
+		if code, found := strings.CutPrefix(key, "U+"); found {
+			n, err := strconv.ParseInt(code, 16, 32)
+			if err != nil {
+				continue
+			}
+			r = rune(n)
+		} else {
+			r = []rune(key)[0]
+		}
+		reading, _, _ := strings.Cut(value, ",")
+		if reading = normalizePinyin(reading); reading != "" {
+			dict[r] = reading
+		}
+	}
+	return dict, scanner.Err()
+}
+
+// normalizePinyin 去除拼音的声调
+func normalizePinyin(s string) string {
+	s = toneMarks.Replace(strings.ToLower(strings.TrimSpace(s)))
+	return strings.TrimRightFunc(s, unicode.IsDigit)
+}
+
+// keyframes 根据字幕的逐字时间戳生成口型关键帧, 时间为音频流中的绝对时间
+// 没有逐字时间戳时按字数平均分配句子的时长
+func (l *lipSync) keyframes(sub *dto.ChatSubtitle) []*dto.ChatVisemeFrame {
+	var frames []*dto.ChatVisemeFrame
+	add := func(viseme string, at int64) {
+		if n := len(frames); n > 0 && frames[n-1].Viseme == viseme {
+			return
+		}
+		frames = append(frames, &dto.ChatVisemeFrame{Viseme: viseme, Time: at})
+	}
+
+	var last int64
+	for _, span := range spans(sub) {
+		// 字之间有明显停顿时闭口
+		if len(frames) > 0 && span.start-last > pauseGap {
+			add(visemeSil, last)
+		}
+		last = span.end
+		shapes := l.shapes(span.r)
+		if len(shapes) == 0 {
+			add(visemeSil, span.start)
+			continue
+		}
+		step := (span.end - span.start) / int64(len(shapes))
+		for i, shape := range shapes {
+			add(shape, span.start+int64(i)*step)
+		}
+	}
+	add(visemeSil, sub.End)
+	return frames
+}
+
+// shapes 返回一个字依次经过的口型, 标点和空白返回nil
+func (l *lipSync) shapes(r rune) []string {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		if py, ok := l.dict[r]; ok {
+			return pinyinShapes(py)
+		}
+		// 不知道读音时张口再闭合
+		return []string{visemeAA, visemeE}
+	case r < unicode.MaxASCII && unicode.IsLetter(r):
+		r = unicode.ToLower(r)
+		if v, ok := letterVisemes[r]; ok {
+			return []string{v}
+		}
+		return []string{vowelVisemes[r]}
+	case unicode.IsDigit(r):
+		return []string{visemeIH}
+	default:
+		return nil
+	}
+}
+
+// pinyinShapes 将一个音节转换为声母、主要元音和鼻音韵尾的口型
+func pinyinShapes(py string) []string {
+	var shapes []string
+	for _, in := range pinyinInitials {
+		if rest, ok := strings.CutPrefix(py, in.initial); ok {
+			shapes = append(shapes, in.viseme)
+			py = rest
+			break
+		}
+	}
+	if py == "er" {
+		return append(shapes, visemeE, visemeRR)
+	}
+	// 主要元音按a、o、e、i、u、v的优先级选择, 如iao取a, ie取e
+	main := ""
+	for _, v := range "aoeiuv" {
+		if strings.ContainsRune(py, v) {
+			main = vowelVisemes[v]
+			break
+		}
+	}
+	if main != "" {
+		shapes = append(shapes, main)
+	}
+	if strings.HasSuffix(py, "n") || strings.HasSuffix(py, "ng") {
+		shapes = append(shapes, visemeNN)
+	}
+	return shapes
+}
+
+// span 一个字在音频流中的时间
+type span struct {
+	r          rune
+	start, end int64
+}
+
+// spans 计算字幕中每个字的时间, 逐字时间戳相对句子开始, 一个词中的字平均分配时长
+func spans(sub *dto.ChatSubtitle) []span {
+	var out []span
+	if len(sub.Words) > 0 {
+		for _, w := range sub.Words {
+			runes := []rune(w.Word)
+			if len(runes) == 0 {
+				continue
+			}
+			step := (w.End - w.Start) / int64(len(runes))
+			for i, r := range runes {
+				start := sub.Start + w.Start + int64(i)*step
+				out = append(out, span{r: r, start: start, end: start + step})
+			}
+		}
+		return out
+	}
+
+	runes := []rune(sub.Text)
+	if len(runes) == 0 || sub.End <= sub.Start {
+		return nil
+	}
+	step := (sub.End - sub.Start) / int64(len(runes))
+	for i, r := range runes {
+		start := sub.Start + int64(i)*step
+		out = append(out, span{r: r, start: start, end: start + step})
+	}
+	return out
+}
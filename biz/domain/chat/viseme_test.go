@@ -0,0 +1,68 @@
+package chat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+)
+
+func visemes(frames []*dto.ChatVisemeFrame) string {
+	parts := make([]string, 0, len(frames))
+	for _, f := range frames {
+		parts = append(parts, f.Viseme)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestLoadPinyinDict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pinyin.txt")
+	content := "# 注释\nU+4F60: nǐ  # 你\n好 hao3\n吕\tlǚ\n\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dict, err := loadPinyinDict(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dict['你'] != "ni" || dict['好'] != "hao" || dict['吕'] != "lv" || len(dict) != 3 {
+		t.Errorf("unexpected dict: %v", dict)
+	}
+}
+
+func TestPinyinShapes(t *testing.T) {
+	cases := map[string]string{
+		"ni": "nn ih", "hao": "kk aa", "zhong": "CH oh nn", "er": "E RR", "bian": "PP aa nn", "lv": "nn ou", "yue": "E",
+	}
+	for py, want := range cases {
+		if got := strings.Join(pinyinShapes(py), " "); got != want {
+			t.Errorf("pinyinShapes(%q) = %q, want %q", py, got, want)
+		}
+	}
+}
+
+func TestLipSync_Keyframes(t *testing.T) {
+	l := &lipSync{dict: map[rune]string{'你': "ni", '好': "hao"}}
+
+	// 使用逐字时间戳, 字之间的停顿闭口
+	frames := l.keyframes(&dto.ChatSubtitle{
+		Text:  "你好",
+		Start: 1000,
+		End:   1600,
+		Words: []*dto.ChatSubtitleWord{{Word: "你", Start: 0, End: 200}, {Word: "好", Start: 300, End: 500}},
+	})
+	if got := visemes(frames); got != "nn ih sil kk aa sil" {
+		t.Errorf("visemes = %q", got)
+	}
+	if frames[0].Time != 1000 || frames[1].Time != 1100 || frames[2].Time != 1200 || frames[3].Time != 1300 || frames[5].Time != 1600 {
+		t.Errorf("unexpected times: %+v %+v %+v %+v %+v", frames[0], frames[1], frames[2], frames[3], frames[5])
+	}
+
+	// 没有时间戳时平均分配, 词典中没有的字使用通用口型
+	frames = l.keyframes(&dto.ChatSubtitle{Text: "你呀, ok", Start: 0, End: 600})
+	if got := visemes(frames); got != "nn ih aa E sil oh kk sil" {
+		t.Errorf("visemes = %q", got)
+	}
+}
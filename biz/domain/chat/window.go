@@ -0,0 +1,98 @@
+package chat
+
+import (
+	"unicode"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// 上下文预算的默认值
+const (
+	defaultMaxTurns  = 20
+	defaultMaxTokens = 4000
+)
+
+// window 对话上下文窗口, 按轮数和token预算截断对话记录
+type window struct {
+	maxTurns  int
+	maxTokens int
+}
+
+// newWindow 根据配置创建上下文窗口, 未配置的预算使用默认值
+func newWindow(c *config.Chat) *window {
+	w := &window{
+		maxTurns:  c.MaxTurns,
+		maxTokens: c.MaxTokens,
+	}
+	if w.maxTurns <= 0 {
+		w.maxTurns = defaultMaxTurns
+	}
+	if w.maxTokens <= 0 {
+		w.maxTokens = defaultMaxTokens
+	}
+	return w
+}
+
+// build 将redis中的对话记录转换为模型上下文
+// 开场白包含学生的姓名和班级, 总是保留; 其余记录从最新向前保留, 直到超出轮数或token预算
+// 最新的一条记录即使超出预算也会保留, 保证模型能看到本轮输入
+func (w *window) build(history []*dto.ChatHistory) []*dto.ChatMessage {
+	var opener *dto.ChatHistory
+	if len(history) > 0 && history[0].Role == "system" {
+		opener, history = history[0], history[1:]
+	}
+
+	budget := w.maxTokens
+	if opener != nil {
+		budget -= estimateTokens(opener.Content)
+	}
+
+	// 一轮包括用户输入和AI回复两条记录
+	start := len(history)
+	for i := len(history) - 1; i >= 0; i-- {
+		cost := estimateTokens(history[i].Content)
+		kept := len(history) - start
+		if kept > 0 && (kept >= 2*w.maxTurns || cost > budget) {
+			break
+		}
+		budget -= cost
+		start = i
+	}
+
+	messages := make([]*dto.ChatMessage, 0, len(history)-start+1)
+	if opener != nil {
+		messages = append(messages, toMessage(opener))
+	}
+	for _, his := range history[start:] {
+		messages = append(messages, toMessage(his))
+	}
+	return messages
+}
+
+// toMessage 将对话记录的角色转换为模型上下文的角色
+// 开场白是以学生口吻做的自我介绍, 所以作为用户输入发送
+func toMessage(his *dto.ChatHistory) *dto.ChatMessage {
+	role := consts.RoleUser
+	if his.Role == "ai" {
+		role = consts.RoleAssistant
+	}
+	return &dto.ChatMessage{
+		Role:    role,
+		Content: his.Content,
+	}
+}
+
+// estimateTokens 粗略估算文本的token数, 中日韩文字按一字一token, 其余字符按四个一token
+func estimateTokens(text string) int {
+	var cjk, other int
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
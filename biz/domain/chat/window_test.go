@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+func TestWindow_Build(t *testing.T) {
+	history := []*dto.ChatHistory{
+		{Role: "system", Content: "你好呀, 我是三年级二班的小明"},
+		{Role: "ai", Content: "小明你好"},
+		{Role: "user", Content: "第一轮"},
+		{Role: "ai", Content: "回复一"},
+		{Role: "user", Content: "第二轮"},
+		{Role: "ai", Content: "回复二", Interrupted: true},
+		{Role: "user", Content: "第三轮"},
+	}
+
+	w := newWindow(&config.Chat{MaxTurns: 1})
+	messages := w.build(history)
+	if len(messages) != 3 {
+		t.Fatalf("len = %d, want 3", len(messages))
+	}
+	if messages[0].Role != "user" || !strings.Contains(messages[0].Content, "小明") {
+		t.Errorf("opener should be kept as user message, got %+v", messages[0])
+	}
+	if messages[1].Role != "assistant" || messages[1].Content != "回复二" {
+		t.Errorf("unexpected message %+v", messages[1])
+	}
+	if messages[2].Content != "第三轮" {
+		t.Errorf("latest input should be last, got %+v", messages[2])
+	}
+
+	// token预算只够开场白时, 仍然保留最新的输入
+	w = newWindow(&config.Chat{MaxTokens: estimateTokens(history[0].Content)})
+	messages = w.build(history)
+	if len(messages) != 2 || messages[1].Content != "第三轮" {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if n := estimateTokens("你好"); n != 2 {
+		t.Errorf("estimateTokens(你好) = %d", n)
+	}
+	if n := estimateTokens("hello world"); n != 3 {
+		t.Errorf("estimateTokens(hello world) = %d", n)
+	}
+}
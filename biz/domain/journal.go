@@ -0,0 +1,51 @@
+package domain
+
+// journal 记录发送给前端的消息, 断线重连后重放前端没有收到的部分
+// 序号从1开始, 超过容量时丢弃最早的消息
+type journal struct {
+	// seq 最后一条消息的序号
+	seq      uint64
+	size     int
+	maxBytes int
+	msgs     []*journalMsg
+}
+
+// journalMsg 一条发送给前端的消息
+type journalMsg struct {
+	seq  uint64
+	mt   int
+	data []byte
+}
+
+// newJournal 创建消息记录
+func newJournal(maxBytes int) *journal {
+	return &journal{maxBytes: maxBytes}
+}
+
+// add 记录一条消息
+func (j *journal) add(mt int, data []byte) {
+	j.seq++
+	j.msgs = append(j.msgs, &journalMsg{seq: j.seq, mt: mt, data: data})
+	j.size += len(data)
+	for len(j.msgs) > 1 && j.size > j.maxBytes {
+		j.size -= len(j.msgs[0].data)
+		j.msgs[0] = nil
+		j.msgs = j.msgs[1:]
+	}
+}
+
+// since 返回序号在received之后的消息, 以及其中已被丢弃的消息数
+func (j *journal) since(received uint64) ([]*journalMsg, int) {
+	if received >= j.seq {
+		return nil, 0
+	}
+	var missed int
+	if len(j.msgs) == 0 {
+		return nil, int(j.seq - received)
+	}
+	if first := j.msgs[0].seq; first > received+1 {
+		missed = int(first - received - 1)
+		received = first - 1
+	}
+	return j.msgs[received-j.msgs[0].seq+1:], missed
+}
@@ -0,0 +1,37 @@
+package domain
+
+import "testing"
+
+func TestJournal(t *testing.T) {
+	j := newJournal(10)
+	for _, s := range []string{"aaaa", "bbbb", "cccc"} {
+		j.add(1, []byte(s))
+	}
+	// 超过容量时丢弃最早的消息
+	if len(j.msgs) != 2 || j.size != 8 || j.seq != 3 {
+		t.Fatalf("got %d msgs, %d bytes, seq %d", len(j.msgs), j.size, j.seq)
+	}
+
+	cases := []struct {
+		received    uint64
+		first       string
+		count, miss int
+	}{
+		{3, "", 0, 0},
+		{2, "cccc", 1, 0},
+		{1, "bbbb", 2, 0},
+		{0, "bbbb", 2, 1},
+	}
+	for _, c := range cases {
+		msgs, missed := j.since(c.received)
+		if len(msgs) != c.count || missed != c.miss || (c.count > 0 && string(msgs[0].data) != c.first) {
+			t.Errorf("since(%d): %d msgs, %d missed", c.received, len(msgs), missed)
+		}
+	}
+
+	// 单条消息超过容量时仍然保留
+	j.add(2, make([]byte, 20))
+	if msgs, missed := j.since(3); len(msgs) != 1 || missed != 0 || msgs[0].seq != 4 {
+		t.Errorf("large message: %d msgs, %d missed", len(msgs), missed)
+	}
+}
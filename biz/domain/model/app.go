@@ -10,7 +10,8 @@ type ChatApp interface {
 	Call(msg string) error
 
 	// StreamCall 流式调用, 默认应该采用增量输出, 即后续的输出不包括之前的输出
-	StreamCall(msg string, sessionId string) (ChatAppScanner, error)
+	// messages 为完整的上下文, 由调用方维护, 模型供应商不保存对话记忆
+	StreamCall(messages []*dto.ChatMessage) (ChatAppScanner, error)
 
 	// Close 关闭资源
 	Close() error
@@ -39,11 +40,14 @@ type TtsApp interface {
 	// Start 建立application级连接
 	Start() error
 
-	// Send 发送文字请求
-	Send(texts string) error
+	// Send 发送文字请求, style为nil时使用默认的语音风格
+	Send(texts string, style *VoiceStyle) error
 
-	// Receive 接受音频流响应
-	Receive() []byte
+	// Receive 接受音频流响应, 包括音频和句子边界, 连接断开或出错时返回nil
+	Receive() *TtsFrame
+
+	// Cancel 打断当前合成, 丢弃已排队但尚未返回的音频
+	Cancel() error
 
 	// Close 断开连接, 释放资源
 	Close() error
@@ -60,9 +64,78 @@ type AsrApp interface {
 	// Send 发送音频流
 	Send(bytes []byte) error
 
-	// Receive 接受文字
-	Receive() (string, error)
+	// Receive 接受识别结果, 没有新的识别结果时返回nil
+	Receive() (*AsrResult, error)
 
 	// Close  关闭连接, 释放资源
 	Close() error
 }
+
+// VoiceStyle 一句话的语音风格, 零值表示使用默认风格
+type VoiceStyle struct {
+	// Emotion 情感, 取值取决于音色支持的情感, 如happy、comfort
+	Emotion string
+	// Rate 语速, [-50, 100], 0为正常语速, 供应商有默认语速时作为相对默认语速的调整
+	Rate int32
+	// Volume 音量, [-50, 100], 0为正常音量
+	Volume int32
+}
+
+// 语音合成的音频格式
+const (
+	TtsFormatPCM     = "pcm"
+	TtsFormatMP3     = "mp3"
+	TtsFormatOggOpus = "ogg_opus"
+)
+
+// TtsConfig 一次对话的语音合成参数, 由前端在开始对话时协商, 零值字段使用供应商的默认值
+type TtsConfig struct {
+	// Format 音频格式, pcm / mp3 / ogg_opus, pcm为16位单声道
+	Format string
+	// SampleRate 采样频率
+	SampleRate int
+	// Speaker 音色
+	Speaker string
+}
+
+// AsrResult 一次语音识别的结果
+type AsrResult struct {
+	// Text 识别出的文字
+	Text string
+	// Final 一句话是否已经结束, 结束后文字不会再变化
+	Final bool
+}
+
+// TtsFrameType 语音合成响应的类型
+type TtsFrameType int
+
+const (
+	// TtsAudio 一段音频
+	TtsAudio TtsFrameType = iota
+	// TtsSentenceStart 一句话开始合成, 之后的音频属于这句话
+	TtsSentenceStart
+	// TtsSentenceEnd 一句话合成结束, 支持时携带逐字时间戳
+	TtsSentenceEnd
+)
+
+// TtsFrame 语音合成的一次响应
+type TtsFrame struct {
+	Type TtsFrameType
+	// Audio 音频数据, 只有TtsAudio有
+	Audio []byte
+	// Duration 音频时长, 单位毫秒, 压缩格式无法计算时为0
+	Duration int64
+	// Text 句子的文本
+	Text string
+	// Words 逐字时间戳, 相对句子开始, 供应商不支持时为空
+	Words []*TtsWord
+}
+
+// TtsWord 一个字或词在句子中的时间
+type TtsWord struct {
+	Word string
+	// Start 开始时间, 单位毫秒
+	Start int64
+	// End 结束时间, 单位毫秒
+	End int64
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/xh-polaris/psych-digital/biz/application/dto"
 	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/util"
 	"io"
@@ -17,14 +18,19 @@ import (
 
 var _ model.ChatApp = (*BLChatApp)(nil)
 
+func init() {
+	model.RegisterChatApp(consts.ProviderBaiLian, func(c *config.Config) model.ChatApp {
+		return NewBLChatApp(c.BaiLianChat.AppId, c.BaiLianChat.ApiKey)
+	})
+}
+
 // BLChatApp 是阿里云对话大模型应用
-// 使用云端上下文管理，本地不管理聊天记录
+// 上下文由调用方管理, 每次调用发送完整的messages, 不使用云端的session_id
 type BLChatApp struct {
 	appId  string
 	apiKey string
 	url    string
 	header http.Header
-	body   map[string]any
 }
 
 // NewBLChatApp 创建一个百炼模型应用实例
@@ -34,14 +40,6 @@ func NewBLChatApp(appId string, apiKey string) model.ChatApp {
 		apiKey: apiKey,
 		url:    fmt.Sprintf("https://dashscope.aliyuncs.com/api/v1/apps/%s/completion", appId),
 		header: http.Header{},
-		body:   make(map[string]any),
-	}
-
-	// 初始化请求模板
-	app.body["input"] = make(map[string]string)
-	// 设置增量流式响应
-	app.body["parameters"] = map[string]any{
-		"incremental_output": true,
 	}
 
 	// 设置请求头,其中X-DashScope-SSE设置为enable，表示开启流式响应
@@ -58,15 +56,22 @@ func (app *BLChatApp) Call(msg string) error {
 }
 
 // StreamCall 流式调用
-func (app *BLChatApp) StreamCall(msg string, sessionId string) (model.ChatAppScanner, error) {
+func (app *BLChatApp) StreamCall(messages []*dto.ChatMessage) (model.ChatAppScanner, error) {
 	client := util.GetHttpClient()
 
-	// 设置调用提示词
-	app.body["input"].(map[string]string)["prompt"] = msg
-	app.body["input"].(map[string]string)["session_id"] = sessionId
+	// 每次调用构造新的请求体, 避免并发调用时互相覆盖
+	body := map[string]any{
+		"input": map[string]any{
+			"messages": messages,
+		},
+		// 设置增量流式响应
+		"parameters": map[string]any{
+			"incremental_output": true,
+		},
+	}
 
 	// 获取流式响应reader
-	reader, err := client.StreamReq(consts.Post, app.url, app.header, app.body)
+	reader, err := client.StreamReq(consts.Post, app.url, app.header, body)
 	if err != nil {
 		return nil, err
 	}
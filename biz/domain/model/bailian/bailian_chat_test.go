@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"io"
 	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
 )
 
 func TestBaiLianChatApp_StreamCall(t *testing.T) {
 	app := NewBLChatApp("d37840a0f7d6490f87952dd3ca0bb441", "sk-02654c3231f54c90b3500a1b75003e5f")
-	scanner, err := app.StreamCall("你好")
+	scanner, err := app.StreamCall([]*dto.ChatMessage{{Role: "user", Content: "你好"}})
 	if err != nil {
 		t.Fatal(err)
 	}
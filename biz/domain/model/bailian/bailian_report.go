@@ -23,7 +23,6 @@ type BLReportApp struct {
 	apiKey string
 	url    string
 	header http.Header
-	body   map[string]any
 }
 
 // NewBLReportApp 创建一个百炼报告分析模型应用实例
@@ -33,14 +32,8 @@ func NewBLReportApp(appId string, apiKey string) model.ReportApp {
 		apiKey: apiKey,
 		url:    fmt.Sprintf("https://dashscope.aliyuncs.com/api/v1/apps/%s/completion", appId),
 		header: http.Header{},
-		body:   make(map[string]any),
 	}
 
-	// 初始化请求模板
-	app.body["input"] = make(map[string]string)
-	// 设置增量流式响应
-	app.body["parameters"] = map[string]any{}
-
 	// 设置请求头,其中X-DashScope-SSE设置为enable，表示开启流式响应
 	app.header.Set("Authorization", "Bearer "+apiKey)
 	app.header.Set("Content-Type", "application/json")
@@ -60,14 +53,33 @@ func GetBLReportApp() model.ReportApp {
 	return instance
 }
 
+var trend model.ReportApp
+var trendOnce sync.Once
+
+// GetBLTrendApp 获取百炼趋势分析模型单例, 未单独配置时复用报告分析应用
+func GetBLTrendApp() model.ReportApp {
+	trendOnce.Do(func() {
+		c := config.GetConfig()
+		if c.BaiLianTrend.AppId == "" {
+			trend = GetBLReportApp()
+			return
+		}
+		trend = NewBLReportApp(c.BaiLianTrend.AppId, c.BaiLianTrend.ApiKey)
+	})
+	return trend
+}
+
 func (app *BLReportApp) Call(prompt string) (*dto.ChatReport, error) {
 	var err error
 	var report dto.ChatReport
 	client := util.GetHttpClient()
 
-	// 设置调用提示词
-	app.body["input"].(map[string]string)["prompt"] = prompt
-	res, err := client.Req(consts.Post, app.url, app.header, app.body)
+	// 每次调用构造新的请求体, 避免并发调用时互相覆盖
+	body := map[string]any{
+		"input":      map[string]string{"prompt": prompt},
+		"parameters": map[string]any{},
+	}
+	res, err := client.Req(consts.Post, app.url, app.header, body)
 	if err != nil {
 		return nil, err
 	}
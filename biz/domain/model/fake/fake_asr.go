@@ -0,0 +1,143 @@
+package fake
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/audio"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+var _ model.AsrApp = (*FakeAsrApp)(nil)
+
+func init() {
+	model.RegisterAsrApp(consts.ProviderFake, func(c *config.Config, opts *model.AsrOptions) (model.AsrApp, error) {
+		lines, err := LoadTranscript(c.FakeAsr.Transcript)
+		if err != nil {
+			return nil, err
+		}
+		return NewFakeAsrApp(lines, c.FakeAsr.Every, opts.Format), nil
+	})
+}
+
+// defaultEvery 默认每收到多少毫秒的音频返回一条结果
+const defaultEvery = 1000
+
+// partialPrefix 中间结果的行前缀
+const partialPrefix = "~"
+
+var errClosed = errors.New("fake asr closed")
+
+// FakeAsrApp 按顺序回放转写文本的语音识别, 不连接任何服务, 结果只取决于收到的音频长度
+// 每收到every毫秒的音频返回一条结果, 压缩格式无法计算时长, 每次发送按every毫秒计算; 回放完后不再返回结果
+type FakeAsrApp struct {
+	mu    sync.Mutex
+	lines []string
+	// every 每条结果需要的音频字节数, 压缩格式为0
+	every int
+	// received 距离上一条结果收到的音频字节数
+	received int
+	closed   bool
+	results  chan *model.AsrResult
+}
+
+// NewFakeAsrApp 创建回放lines的语音识别, 以~开头的行为中间结果, 其余为一句话的最终结果
+func NewFakeAsrApp(lines []string, every int, format audio.Format) *FakeAsrApp {
+	if every <= 0 {
+		every = defaultEvery
+	}
+	app := &FakeAsrApp{
+		lines:   lines,
+		results: make(chan *model.AsrResult, len(lines)),
+	}
+	if format.IsPCM() {
+		app.every = every * format.SampleRate / 1000 * format.Bits / 8 * format.Channels
+	}
+	return app
+}
+
+// LoadTranscript 读取转写文件, 忽略空行
+func LoadTranscript(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// Dial 不需要连接
+func (app *FakeAsrApp) Dial() error {
+	return nil
+}
+
+// Start 不需要握手
+func (app *FakeAsrApp) Start() error {
+	return nil
+}
+
+// Send 累计收到的音频, 足够时返回下一条结果
+func (app *FakeAsrApp) Send(data []byte) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.closed {
+		return errClosed
+	}
+
+	if app.every == 0 {
+		app.next()
+		return nil
+	}
+	for app.received += len(data); app.received >= app.every && len(app.lines) > 0; app.received -= app.every {
+		app.next()
+	}
+	return nil
+}
+
+// next 返回下一条结果, 调用方需持有锁
+func (app *FakeAsrApp) next() {
+	if len(app.lines) == 0 {
+		return
+	}
+	line := app.lines[0]
+	app.lines = app.lines[1:]
+	if text, ok := strings.CutPrefix(line, partialPrefix); ok {
+		app.results <- &model.AsrResult{Text: strings.TrimSpace(text)}
+	} else {
+		app.results <- &model.AsrResult{Text: line, Final: true}
+	}
+}
+
+// Receive 获取下一条结果, 关闭后返回io.EOF
+func (app *FakeAsrApp) Receive() (*model.AsrResult, error) {
+	res, ok := <-app.results
+	if !ok {
+		return nil, io.EOF
+	}
+	return res, nil
+}
+
+// Close 结束回放
+func (app *FakeAsrApp) Close() error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if !app.closed {
+		app.closed = true
+		close(app.results)
+	}
+	return nil
+}
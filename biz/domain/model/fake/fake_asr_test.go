@@ -0,0 +1,53 @@
+package fake
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/audio"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+func TestFakeAsrApp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transcript.txt")
+	if err := os.WriteFile(path, []byte("~我最近\n\n我最近睡不好\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := &config.Config{Asr: config.Asr{Provider: consts.ProviderFake}, FakeAsr: config.FakeAsr{Transcript: path, Every: 100}}
+	app, err := model.NewAsrApp(c, &model.AsrOptions{Format: audio.Target()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 100毫秒16位单声道PCM为3200字节, 不足时没有结果
+	_ = app.Send(make([]byte, 3000))
+	_ = app.Send(make([]byte, 3400))
+	_ = app.Send(make([]byte, 6400))
+	want := []model.AsrResult{{Text: "我最近"}, {Text: "我最近睡不好", Final: true}}
+	for _, w := range want {
+		res, err := app.Receive()
+		if err != nil || *res != w {
+			t.Fatalf("got %+v, %v, want %+v", res, err, w)
+		}
+	}
+
+	_ = app.Close()
+	if _, err = app.Receive(); err != io.EOF {
+		t.Errorf("receive after close: %v", err)
+	}
+	if err = app.Send([]byte{0}); err == nil {
+		t.Error("send after close should fail")
+	}
+}
+
+func TestFakeAsrApp_Compressed(t *testing.T) {
+	app := NewFakeAsrApp([]string{"一", "二"}, 0, audio.Format{Container: audio.ContainerOgg})
+	_ = app.Send([]byte{1})
+	if res, _ := app.Receive(); res.Text != "一" || !res.Final {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,132 @@
+package fake
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+var _ model.TtsApp = (*FakeTtsApp)(nil)
+
+func init() {
+	model.RegisterTtsApp(consts.ProviderFake, func(c *config.Config, tc *model.TtsConfig) (model.TtsApp, error) {
+		return NewFakeTtsApp(c.FakeTts.CharMs, tc), nil
+	})
+}
+
+const (
+	// defaultCharMs 默认每个字的时长
+	defaultCharMs = 200
+	// defaultSampleRate 未指定采样频率时的默认值
+	defaultSampleRate = 24000
+)
+
+var errTtsClosed = errors.New("fake tts closed")
+
+// FakeTtsApp 生成静音的语音合成, 不连接任何服务
+// 每句话按字数生成固定时长的静音PCM和逐字时间戳, 压缩格式只返回句子边界, 不返回音频
+type FakeTtsApp struct {
+	mu     sync.Mutex
+	charMs int64
+	// bytesPerMs 每毫秒的PCM字节数, 压缩格式为0
+	bytesPerMs int64
+	closed     bool
+	frames     chan *model.TtsFrame
+}
+
+// NewFakeTtsApp 创建语音合成, charMs为每个字的时长
+func NewFakeTtsApp(charMs int, tc *model.TtsConfig) *FakeTtsApp {
+	if charMs <= 0 {
+		charMs = defaultCharMs
+	}
+	app := &FakeTtsApp{
+		charMs: int64(charMs),
+		frames: make(chan *model.TtsFrame, 64),
+	}
+	if tc.Format == "" || tc.Format == model.TtsFormatPCM {
+		rate := tc.SampleRate
+		if rate == 0 {
+			rate = defaultSampleRate
+		}
+		app.bytesPerMs = int64(rate) / 1000 * 2
+	}
+	return app
+}
+
+// Dial 不需要连接
+func (app *FakeTtsApp) Dial() error {
+	return nil
+}
+
+// Start 不需要握手
+func (app *FakeTtsApp) Start() error {
+	return nil
+}
+
+// Send 合成一句话, 依次返回句子开始、静音和句子结束
+func (app *FakeTtsApp) Send(text string, _ *model.VoiceStyle) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.closed {
+		return errTtsClosed
+	}
+
+	end := &model.TtsFrame{Type: model.TtsSentenceEnd, Text: text}
+	var duration int64
+	for _, r := range text {
+		end.Words = append(end.Words, &model.TtsWord{Word: string(r), Start: duration, End: duration + app.charMs})
+		duration += app.charMs
+	}
+	frames := []*model.TtsFrame{{Type: model.TtsSentenceStart, Text: text}}
+	if app.bytesPerMs > 0 && duration > 0 {
+		frames = append(frames, &model.TtsFrame{
+			Type:     model.TtsAudio,
+			Audio:    make([]byte, duration*app.bytesPerMs),
+			Duration: duration,
+		})
+	}
+	frames = append(frames, end)
+	// 没有人接收时整句丢弃, 避免阻塞对话
+	if len(app.frames)+len(frames) > cap(app.frames) {
+		return nil
+	}
+	for _, f := range frames {
+		app.frames <- f
+	}
+	return nil
+}
+
+// Receive 获取下一个响应, 关闭后返回nil
+func (app *FakeTtsApp) Receive() *model.TtsFrame {
+	return <-app.frames
+}
+
+// Cancel 丢弃尚未接收的响应
+func (app *FakeTtsApp) Cancel() error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.closed {
+		return nil
+	}
+	for {
+		select {
+		case <-app.frames:
+		default:
+			return nil
+		}
+	}
+}
+
+// Close 结束合成
+func (app *FakeTtsApp) Close() error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if !app.closed {
+		app.closed = true
+		close(app.frames)
+	}
+	return nil
+}
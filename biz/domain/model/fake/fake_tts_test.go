@@ -0,0 +1,46 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+)
+
+func TestFakeTtsApp(t *testing.T) {
+	app := NewFakeTtsApp(100, &model.TtsConfig{SampleRate: 16000})
+	if err := app.Send("你好", nil); err != nil {
+		t.Fatal(err)
+	}
+	if f := app.Receive(); f.Type != model.TtsSentenceStart || f.Text != "你好" {
+		t.Errorf("start: %+v", f)
+	}
+	if f := app.Receive(); f.Type != model.TtsAudio || f.Duration != 200 || len(f.Audio) != 200*32 {
+		t.Errorf("audio: %d bytes %d ms", len(f.Audio), f.Duration)
+	}
+	f := app.Receive()
+	if f.Type != model.TtsSentenceEnd || len(f.Words) != 2 || f.Words[1].Start != 100 || f.Words[1].End != 200 {
+		t.Errorf("end: %+v", f)
+	}
+
+	// 打断后丢弃尚未接收的响应
+	_ = app.Send("再见", nil)
+	_ = app.Cancel()
+	_ = app.Close()
+	if f = app.Receive(); f != nil {
+		t.Errorf("receive after close: %+v", f)
+	}
+	if err := app.Send("再见", nil); err == nil {
+		t.Error("expect error after close")
+	}
+}
+
+func TestFakeTtsApp_Compressed(t *testing.T) {
+	app := NewFakeTtsApp(0, &model.TtsConfig{Format: model.TtsFormatMP3})
+	_ = app.Send("嗯", nil)
+	if f := app.Receive(); f.Type != model.TtsSentenceStart {
+		t.Errorf("start: %+v", f)
+	}
+	if f := app.Receive(); f.Type != model.TtsSentenceEnd || f.Words[0].End != defaultCharMs {
+		t.Errorf("end: %+v", f)
+	}
+}
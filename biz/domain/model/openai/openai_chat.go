@@ -0,0 +1,154 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/util"
+)
+
+var _ model.ChatApp = (*OAChatApp)(nil)
+
+func init() {
+	model.RegisterChatApp(consts.ProviderOpenAI, func(c *config.Config) model.ChatApp {
+		return NewOAChatApp(c.OpenAIChat.Url, c.OpenAIChat.ApiKey, c.OpenAIChat.Model, c.OpenAIChat.Prompt)
+	})
+}
+
+// OAChatApp 是兼容OpenAI chat/completions协议的对话大模型
+// 服务端不保存上下文, 每次调用发送完整的messages
+type OAChatApp struct {
+	url    string
+	apiKey string
+	model  string
+	prompt string
+	header http.Header
+}
+
+// NewOAChatApp 创建一个OpenAI兼容的对话模型实例, url为接口前缀, 如 https://api.openai.com/v1
+func NewOAChatApp(url, apiKey, model, prompt string) model.ChatApp {
+	app := &OAChatApp{
+		url:    strings.TrimSuffix(url, "/") + "/chat/completions",
+		apiKey: apiKey,
+		model:  model,
+		prompt: prompt,
+		header: http.Header{},
+	}
+	app.header.Set("Authorization", "Bearer "+apiKey)
+	app.header.Set("Content-Type", "application/json")
+	app.header.Set("Accept", "text/event-stream")
+	return app
+}
+
+// Call 非流式调用，暂时没用上
+func (app *OAChatApp) Call(msg string) error {
+	panic("implement me")
+}
+
+// StreamCall 流式调用, 配置了系统提示词时会加在上下文最前面
+func (app *OAChatApp) StreamCall(messages []*dto.ChatMessage) (model.ChatAppScanner, error) {
+	client := util.GetHttpClient()
+
+	if app.prompt != "" {
+		messages = append([]*dto.ChatMessage{{Role: consts.RoleSystem, Content: app.prompt}}, messages...)
+	}
+
+	// 每次调用构造新的请求体, 避免并发调用时互相覆盖
+	body := map[string]any{
+		"model":    app.model,
+		"stream":   true,
+		"messages": messages,
+	}
+
+	reader, err := client.StreamReq(consts.Post, app.url, app.header, body)
+	if err != nil {
+		return nil, err
+	}
+	return newOAChatAppScanner(reader), nil
+}
+
+// Close 释放相关资源
+// OAChat暂时没有需要释放的资源
+func (app *OAChatApp) Close() error {
+	return nil
+}
+
+// OAChatAppScanner 是OpenAI兼容对话调用的响应
+type OAChatAppScanner struct {
+	closer  io.ReadCloser
+	scanner *bufio.Scanner
+	id      uint64
+}
+
+// oARawChatData 是chat.completion.chunk的原始响应
+type oARawChatData struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// newOAChatAppScanner 创建一个新的大模型对话结果对象
+func newOAChatAppScanner(r io.ReadCloser) *OAChatAppScanner {
+	return &OAChatAppScanner{
+		closer:  r,
+		scanner: bufio.NewScanner(r),
+	}
+}
+
+// Next 返回下一个读取到的对象或错误, 读取到[DONE]时返回io.EOF
+func (s *OAChatAppScanner) Next() (*dto.ChatData, error) {
+	for s.scanner.Scan() {
+		line := strings.TrimSpace(s.scanner.Text())
+		// 只处理data行, 跳过空行、注释和event等字段
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "[DONE]" {
+			return nil, io.EOF
+		}
+
+		var raw oARawChatData
+		if err := json.Unmarshal([]byte(payload), &raw); err != nil {
+			return nil, err
+		}
+		// 部分实现会在最后单独返回一个只有usage的chunk
+		if len(raw.Choices) == 0 {
+			continue
+		}
+
+		s.id++
+		data := &dto.ChatData{
+			Id:        s.id,
+			Content:   raw.Choices[0].Delta.Content,
+			Timestamp: time.Now().Unix(),
+		}
+		if raw.Choices[0].FinishReason != nil {
+			data.Finish = *raw.Choices[0].FinishReason
+		}
+		return data, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// 没有更多内容
+	return nil, io.EOF
+}
+
+// Close 释放资源
+func (s *OAChatAppScanner) Close() error {
+	return s.closer.Close()
+}
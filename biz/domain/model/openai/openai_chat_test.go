@@ -0,0 +1,109 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+)
+
+// newSSEServer 启动一个模拟chat/completions流式接口的本地服务
+func newSSEServer(t *testing.T, chunks []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Model    string `json:"model"`
+			Stream   bool   `json:"stream"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if !body.Stream || body.Model != "test-model" || len(body.Messages) != 4 ||
+			body.Messages[0].Role != "system" || body.Messages[2].Role != "assistant" || body.Messages[3].Content != "你好" {
+			t.Errorf("unexpected request body: %+v", body)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for i, c := range chunks {
+			finish := "null"
+			if i == len(chunks)-1 {
+				finish = `"stop"`
+			}
+			_, _ = fmt.Fprintf(w, ": keep-alive\n\ndata: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q},\"finish_reason\":%s}]}\n\n", c, finish)
+			flusher.Flush()
+		}
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"total_tokens\":10}}\n\ndata: [DONE]\n\n")
+		flusher.Flush()
+	}))
+}
+
+func TestOAChatApp_StreamCall(t *testing.T) {
+	chunks := []string{"你好呀", ", 我是", "小助手。"}
+	server := newSSEServer(t, chunks)
+	defer server.Close()
+
+	app := NewOAChatApp(server.URL+"/v1/", "test-key", "test-model", "你是心理陪伴助手")
+	scanner, err := app.StreamCall([]*dto.ChatMessage{
+		{Role: "user", Content: "我是三年级二班的小明"},
+		{Role: "assistant", Content: "小明你好"},
+		{Role: "user", Content: "你好"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = scanner.Close() }()
+
+	var content, finish string
+	var count uint64
+	for {
+		data, err := scanner.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+		if data.Id != count {
+			t.Errorf("id = %d, want %d", data.Id, count)
+		}
+		content += data.Content
+		finish = data.Finish
+	}
+
+	if content != "你好呀, 我是小助手。" {
+		t.Errorf("content = %q", content)
+	}
+	if count != uint64(len(chunks)) {
+		t.Errorf("chunks = %d, want %d", count, len(chunks))
+	}
+	if finish != "stop" {
+		t.Errorf("finish = %q, want stop", finish)
+	}
+}
+
+func TestOAChatApp_StreamCallUnauthorized(t *testing.T) {
+	server := newSSEServer(t, nil)
+	defer server.Close()
+
+	app := NewOAChatApp(server.URL+"/v1", "wrong-key", "test-model", "")
+	if _, err := app.StreamCall([]*dto.ChatMessage{{Role: "user", Content: "你好"}}); err == nil {
+		t.Fatal("expected error for unauthorized request")
+	}
+}
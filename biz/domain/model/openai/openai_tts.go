@@ -0,0 +1,284 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+var _ model.TtsApp = (*OATtsApp)(nil)
+
+func init() {
+	model.RegisterTtsApp(consts.ProviderOpenAI, func(c *config.Config, tc *model.TtsConfig) (model.TtsApp, error) {
+		t := c.OpenAITts
+		return NewOATtsApp(t.Url, t.ApiKey, t.Model, t.Voice, tc)
+	})
+}
+
+const (
+	ttsModel = "tts-1"
+	ttsVoice = "alloy"
+	// ttsSampleRate audio/speech接口的pcm固定为24000采样频率的16位单声道
+	ttsSampleRate = 24000
+	// ttsChunk 每次读取并返回的音频长度
+	ttsChunk = 4800
+)
+
+// ttsFormats 合成格式到接口response_format的映射
+var ttsFormats = map[string]string{
+	model.TtsFormatPCM:     "pcm",
+	model.TtsFormatMP3:     "mp3",
+	model.TtsFormatOggOpus: "opus",
+}
+
+var errTtsClosed = errors.New("openai tts closed")
+
+// OATtsApp 兼容OpenAI audio/speech协议的语音合成
+// 接口不支持双向流, 每句话单独请求, 请求前后分别返回句子的开始和结束, 不支持逐字时间戳
+// 语速由语音风格的Rate换算, 情感暂不支持
+type OATtsApp struct {
+	url    string
+	header http.Header
+	model  string
+	voice  string
+	format string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+	// gen 打断的次数, 打断前提交的句子和返回的音频都会被丢弃
+	gen atomic.Uint64
+	// abort 取消当前正在进行的请求
+	abort context.CancelFunc
+
+	texts  chan *oaTtsText
+	frames chan *oaTtsFrame
+}
+
+// oaTtsText 一句待合成的文字
+type oaTtsText struct {
+	gen   uint64
+	text  string
+	style *model.VoiceStyle
+}
+
+// oaTtsFrame 一次合成响应
+type oaTtsFrame struct {
+	gen   uint64
+	frame *model.TtsFrame
+}
+
+// NewOATtsApp 创建语音合成, url为接口前缀, 如 https://api.openai.com/v1; 只支持24000采样频率
+func NewOATtsApp(url, apiKey, modelName, voice string, tc *model.TtsConfig) (*OATtsApp, error) {
+	format := ttsFormats[model.TtsFormatPCM]
+	if tc.Format != "" {
+		var ok bool
+		if format, ok = ttsFormats[tc.Format]; !ok {
+			return nil, fmt.Errorf("openai tts does not support format %s", tc.Format)
+		}
+	}
+	if tc.SampleRate != 0 && tc.SampleRate != ttsSampleRate {
+		return nil, fmt.Errorf("openai tts does not support sample rate %d", tc.SampleRate)
+	}
+	if modelName == "" {
+		modelName = ttsModel
+	}
+	if tc.Speaker != "" {
+		voice = tc.Speaker
+	}
+	if voice == "" {
+		voice = ttsVoice
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app := &OATtsApp{
+		url:    strings.TrimSuffix(url, "/") + "/audio/speech",
+		header: http.Header{},
+		model:  modelName,
+		voice:  voice,
+		format: format,
+		ctx:    ctx,
+		cancel: cancel,
+		texts:  make(chan *oaTtsText, 64),
+		frames: make(chan *oaTtsFrame, 64),
+	}
+	app.header.Set("Authorization", "Bearer "+apiKey)
+	app.header.Set("Content-Type", "application/json")
+	return app, nil
+}
+
+// Dial 每句话单独发送http请求, 不需要建立连接
+func (app *OATtsApp) Dial() error {
+	return nil
+}
+
+// Start 启动合成协程
+func (app *OATtsApp) Start() error {
+	go app.work()
+	return nil
+}
+
+// Send 提交一句话, 按提交顺序合成
+func (app *OATtsApp) Send(text string, style *model.VoiceStyle) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.closed {
+		return errTtsClosed
+	}
+	select {
+	case app.texts <- &oaTtsText{gen: app.gen.Load(), text: text, style: style}:
+		return nil
+	case <-app.ctx.Done():
+		return errTtsClosed
+	}
+}
+
+// Receive 获取合成响应, 打断前的响应直接丢弃, 关闭后返回nil
+func (app *OATtsApp) Receive() *model.TtsFrame {
+	for f := range app.frames {
+		if f.gen == app.gen.Load() {
+			return f.frame
+		}
+	}
+	return nil
+}
+
+// Cancel 打断当前合成, 取消正在进行的请求并丢弃已提交的句子
+func (app *OATtsApp) Cancel() error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.gen.Add(1)
+	if app.abort != nil {
+		app.abort()
+	}
+	return nil
+}
+
+// Close 取消所有请求并释放资源
+func (app *OATtsApp) Close() error {
+	app.cancel()
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if !app.closed {
+		app.closed = true
+		close(app.texts)
+	}
+	return nil
+}
+
+// work 依次合成提交的句子 #消费者
+func (app *OATtsApp) work() {
+	defer close(app.frames)
+	for t := range app.texts {
+		if t.gen != app.gen.Load() {
+			continue
+		}
+		if err := app.synthesize(t); err != nil {
+			if app.ctx.Err() != nil {
+				return
+			}
+			if !errors.Is(err, context.Canceled) {
+				log.Error("openai tts err:", err)
+			}
+		}
+	}
+}
+
+// synthesize 合成一句话, 音频按ttsChunk分段返回
+func (app *OATtsApp) synthesize(t *oaTtsText) error {
+	ctx, abort := context.WithCancel(app.ctx)
+	defer abort()
+	app.mu.Lock()
+	if t.gen != app.gen.Load() {
+		app.mu.Unlock()
+		return nil
+	}
+	app.abort = abort
+	app.mu.Unlock()
+
+	body := map[string]any{
+		"model":           app.model,
+		"input":           t.text,
+		"voice":           app.voice,
+		"response_format": app.format,
+	}
+	if t.style != nil && t.style.Rate != 0 {
+		// Rate的范围为[-50, 100], 对应语速0.5到2倍
+		body["speed"] = 1 + float64(t.style.Rate)/100
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, consts.Post, app.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header = app.header.Clone()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("[code=%d] [body=%s]", resp.StatusCode, msg)
+	}
+
+	if !app.emit(t.gen, &model.TtsFrame{Type: model.TtsSentenceStart, Text: t.text}) {
+		return nil
+	}
+	buf := make([]byte, ttsChunk)
+	for {
+		n, err := io.ReadFull(resp.Body, buf)
+		if n > 0 {
+			audio := bytes.Clone(buf[:n])
+			if !app.emit(t.gen, &model.TtsFrame{Type: model.TtsAudio, Audio: audio, Duration: app.duration(audio)}) {
+				return nil
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	app.emit(t.gen, &model.TtsFrame{Type: model.TtsSentenceEnd, Text: t.text})
+	return nil
+}
+
+// emit 返回一次合成响应, 已被打断或关闭时返回false
+func (app *OATtsApp) emit(gen uint64, frame *model.TtsFrame) bool {
+	if gen != app.gen.Load() {
+		return false
+	}
+	select {
+	case app.frames <- &oaTtsFrame{gen: gen, frame: frame}:
+		return true
+	case <-app.ctx.Done():
+		return false
+	}
+}
+
+// duration 计算一段音频的时长, 只有pcm可以直接计算, 其他格式返回0
+func (app *OATtsApp) duration(audio []byte) int64 {
+	if app.format != ttsFormats[model.TtsFormatPCM] {
+		return 0
+	}
+	return int64(len(audio)) * 1000 / (ttsSampleRate * 2)
+}
@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+)
+
+func TestOATtsApp(t *testing.T) {
+	pcm := bytes.Repeat([]byte{1, 0}, 24000) // 1秒
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/speech" || r.Header.Get("Authorization") != "Bearer test-key" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["model"] != "tts-1" || body["voice"] != "nova" || body["response_format"] != "pcm" || body["speed"] != 1.5 {
+			t.Errorf("unexpected body: %v", body)
+		}
+		_, _ = w.Write(pcm)
+	}))
+	defer server.Close()
+
+	app, err := NewOATtsApp(server.URL+"/v1", "test-key", "", "", &model.TtsConfig{Speaker: "nova"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Close() }()
+	_ = app.Start()
+	if err = app.Send("你好", &model.VoiceStyle{Rate: 50}); err != nil {
+		t.Fatal(err)
+	}
+
+	if f := app.Receive(); f == nil || f.Type != model.TtsSentenceStart || f.Text != "你好" {
+		t.Fatalf("first frame: %+v", f)
+	}
+	var audio []byte
+	var duration int64
+	for {
+		f := app.Receive()
+		if f == nil {
+			t.Fatal("closed before sentence end")
+		}
+		if f.Type == model.TtsSentenceEnd {
+			break
+		}
+		audio = append(audio, f.Audio...)
+		duration += f.Duration
+	}
+	if !bytes.Equal(audio, pcm) || duration != 1000 {
+		t.Errorf("got %d bytes %d ms, want %d bytes 1000 ms", len(audio), duration, len(pcm))
+	}
+}
+
+func TestNewOATtsApp(t *testing.T) {
+	if _, err := NewOATtsApp("", "", "", "", &model.TtsConfig{SampleRate: 16000}); err == nil {
+		t.Error("expect error for 16k")
+	}
+	if _, err := NewOATtsApp("", "", "", "", &model.TtsConfig{Format: "wav"}); err == nil {
+		t.Error("expect error for wav")
+	}
+	app, err := NewOATtsApp("", "", "", "", &model.TtsConfig{Format: model.TtsFormatOggOpus})
+	if err != nil || app.format != "opus" || app.duration([]byte{1, 2}) != 0 {
+		t.Errorf("ogg_opus: %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/audio"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+var _ model.AsrApp = (*WhisperAsrApp)(nil)
+
+func init() {
+	model.RegisterAsrApp(consts.ProviderWhisper, func(c *config.Config, opts *model.AsrOptions) (model.AsrApp, error) {
+		if opts.Format != audio.Target() {
+			return nil, fmt.Errorf("%w: whisper asr needs 16k pcm", audio.ErrUnsupportedFormat)
+		}
+		w, vad := c.WhisperAsr, c.Vad
+		if opts.EndWindow > 0 {
+			vad.Silence = opts.EndWindow
+		}
+		return NewWhisperAsrApp(w.Url, w.ApiKey, w.Model, w.Language, &vad, w.Partial), nil
+	})
+}
+
+// 本地断句的参数, 音频为16000采样频率的16位单声道PCM, 静音检测使用audio.Vad
+const (
+	whisperModel      = "whisper-1"
+	whisperBytesPerMs = audio.VadFrameBytes / audio.VadFrameMs
+	// whisperLeadIn 检测到开始说话前保留的音频时长, 避免切掉第一个字
+	whisperLeadIn = 300
+	// whisperMaxSpeech 一句话的最长时长, 超过后直接识别
+	whisperMaxSpeech = 30000
+)
+
+var errWhisperClosed = errors.New("whisper asr closed")
+
+// WhisperAsrApp 兼容OpenAI audio/transcriptions协议的语音识别
+// 接口不支持流式, 本地用语音活动检测切分出一句话后整句上传识别; 配置了partial时说话过程中定期上传已有的音频作为中间结果
+type WhisperAsrApp struct {
+	url      string
+	header   http.Header
+	model    string
+	language string
+	partial  int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+	vad    *audio.Vad
+	// rest 上次发送时不足一帧的数据
+	rest []byte
+	// buf 当前一句话的音频
+	buf []byte
+	// sincePartial 距离上次识别中间结果的时长
+	sincePartial int
+
+	jobs    chan *whisperJob
+	results chan *model.AsrResult
+}
+
+// whisperJob 一次识别请求
+type whisperJob struct {
+	pcm   []byte
+	final bool
+}
+
+// NewWhisperAsrApp 创建语音识别, url为接口前缀, 如 https://api.openai.com/v1, vad为断句使用的语音活动检测配置
+func NewWhisperAsrApp(url, apiKey, modelName, language string, vad *config.Vad, partial int) *WhisperAsrApp {
+	if modelName == "" {
+		modelName = whisperModel
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	app := &WhisperAsrApp{
+		url:      strings.TrimSuffix(url, "/") + "/audio/transcriptions",
+		header:   http.Header{},
+		model:    modelName,
+		language: language,
+		partial:  partial,
+		ctx:      ctx,
+		cancel:   cancel,
+		vad:      audio.NewVad(vad),
+		jobs:     make(chan *whisperJob, 8),
+		results:  make(chan *model.AsrResult, 8),
+	}
+	app.header.Set("Authorization", "Bearer "+apiKey)
+	return app
+}
+
+// Dial 每次识别单独发送http请求, 不需要建立连接
+func (app *WhisperAsrApp) Dial() error {
+	return nil
+}
+
+// Start 启动识别协程
+func (app *WhisperAsrApp) Start() error {
+	go app.work()
+	return nil
+}
+
+// Send 发送音频, 检测到一句话结束时提交识别
+func (app *WhisperAsrApp) Send(data []byte) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.closed {
+		return errWhisperClosed
+	}
+
+	data = append(app.rest, data...)
+	for len(data) >= audio.VadFrameBytes {
+		app.frame(data[:audio.VadFrameBytes])
+		data = data[audio.VadFrameBytes:]
+	}
+	app.rest = append(app.rest[:0], data...)
+	return nil
+}
+
+// frame 处理一帧音频, 调用方需持有锁
+func (app *WhisperAsrApp) frame(frame []byte) {
+	app.buf = append(app.buf, frame...)
+	event := app.vad.Frame(frame)
+	if !app.vad.Speaking() && event != audio.VadEnd {
+		if keep := whisperLeadIn * whisperBytesPerMs; len(app.buf) > keep {
+			app.buf = append(app.buf[:0], app.buf[len(app.buf)-keep:]...)
+		}
+		return
+	}
+
+	app.sincePartial += audio.VadFrameMs
+	if event == audio.VadEnd || len(app.buf) >= whisperMaxSpeech*whisperBytesPerMs {
+		// 整句识别, 队列满时等待, 关闭后直接丢弃
+		select {
+		case app.jobs <- &whisperJob{pcm: app.buf, final: true}:
+		case <-app.ctx.Done():
+		}
+		// 超过最长时长时检测仍处于说话中, 后续的音频作为新的一句话继续累积
+		app.buf, app.sincePartial = nil, 0
+		return
+	}
+	if app.partial > 0 && app.sincePartial >= app.partial {
+		app.sincePartial = 0
+		// 中间结果只是提示, 识别跟不上时直接丢弃
+		select {
+		case app.jobs <- &whisperJob{pcm: bytes.Clone(app.buf)}:
+		default:
+		}
+	}
+}
+
+// work 依次识别提交的音频 #消费者
+func (app *WhisperAsrApp) work() {
+	defer close(app.results)
+	for job := range app.jobs {
+		text, err := app.transcribe(job.pcm)
+		if err != nil {
+			if app.ctx.Err() != nil {
+				return
+			}
+			log.Error("whisper transcribe err:", err)
+			continue
+		}
+		select {
+		case app.results <- &model.AsrResult{Text: text, Final: job.final}:
+		case <-app.ctx.Done():
+			return
+		}
+	}
+}
+
+// transcribe 上传一段音频并返回识别的文本
+func (app *WhisperAsrApp) transcribe(pcm []byte) (string, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("model", app.model)
+	_ = w.WriteField("response_format", "json")
+	if app.language != "" {
+		_ = w.WriteField("language", app.language)
+	}
+	part, err := w.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return "", err
+	}
+	if _, err = part.Write(audio.EncodeWAV(pcm, audio.Target())); err != nil {
+		return "", err
+	}
+	if err = w.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(app.ctx, consts.Post, app.url, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header = app.header.Clone()
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("[code=%d] [body=%s]", resp.StatusCode, msg)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// Receive 获取识别结果, 关闭后返回io.EOF
+func (app *WhisperAsrApp) Receive() (*model.AsrResult, error) {
+	res, ok := <-app.results
+	if !ok {
+		return nil, io.EOF
+	}
+	return res, nil
+}
+
+// Close 取消进行中的识别并释放资源
+func (app *WhisperAsrApp) Close() error {
+	app.cancel()
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if !app.closed {
+		app.closed = true
+		close(app.jobs)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package openai
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+// speech 生成一段16000采样频率的16位单声道PCM, amplitude为0时为静音
+func speech(ms int, amplitude float64) []byte {
+	n := 16 * ms
+	pcm := make([]byte, 2*n)
+	for i := 0; i < n; i++ {
+		s := int16(amplitude * math.Sin(2*math.Pi*200*float64(i)/16000))
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(s))
+	}
+	return pcm
+}
+
+func TestWhisperAsrApp(t *testing.T) {
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer test-key" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.FormValue("model") != "whisper-1" || r.FormValue("language") != "zh" {
+			t.Errorf("unexpected form: %v", r.MultipartForm.Value)
+		}
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("form file: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(f)
+		sizes = append(sizes, len(data))
+		_ = json.NewEncoder(w).Encode(map[string]string{"text": " 我今天很开心 "})
+	}))
+	defer server.Close()
+
+	app := NewWhisperAsrApp(server.URL+"/v1", "test-key", "", "zh", &config.Vad{Silence: 200}, 0)
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// 说话前的长时间静音只保留一小段, 说完后静音超过200毫秒提交识别
+	for _, pcm := range [][]byte{speech(1000, 0), speech(500, 3000), speech(300, 0)} {
+		if err := app.Send(pcm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		res, err := app.Receive()
+		if err != nil || res.Text != "我今天很开心" || !res.Final {
+			t.Errorf("unexpected result: %+v, %v", res, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("receive timeout")
+	}
+
+	// 确认开始说话前保留的300毫秒(220毫秒静音和80毫秒说话) + 确认后的420毫秒说话 + 200毫秒结尾静音, 加上44字节的文件头
+	if want := 920*32 + 44; len(sizes) != 1 || sizes[0] != want {
+		t.Errorf("uploaded sizes %v, want [%d]", sizes, want)
+	}
+	_ = app.Close()
+	if _, err := app.Receive(); err != io.EOF {
+		t.Errorf("receive after close: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/audio"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// ChatAppFactory 根据配置创建一个ChatApp
+type ChatAppFactory func(c *config.Config) ChatApp
+
+// AsrOptions 创建语音识别时由调用方决定的参数
+type AsrOptions struct {
+	// Format 发送的音频格式, 由audio.Transcoder转换得到
+	Format audio.Format
+	// EndWindow 判断一句话结束的静音时长, 单位毫秒
+	EndWindow int
+}
+
+// AsrAppFactory 根据配置创建一个AsrApp, 不支持音频格式时返回错误
+type AsrAppFactory func(c *config.Config, opts *AsrOptions) (AsrApp, error)
+
+// TtsAppFactory 根据配置和本次对话的合成参数创建一个TtsApp, 不支持合成参数时返回错误
+type TtsAppFactory func(c *config.Config, tc *TtsConfig) (TtsApp, error)
+
+var (
+	mu       sync.RWMutex
+	chatApps = make(map[string]ChatAppFactory)
+	asrApps  = make(map[string]AsrAppFactory)
+	ttsApps  = make(map[string]TtsAppFactory)
+)
+
+// RegisterChatApp 注册对话模型供应商, 由各实现在init中调用
+func RegisterChatApp(name string, factory ChatAppFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+	chatApps[name] = factory
+}
+
+// NewChatApp 根据配置中的Chat.Provider创建对话模型, 未配置时使用百炼
+func NewChatApp(c *config.Config) (ChatApp, error) {
+	name := c.Chat.Provider
+	if name == "" {
+		name = consts.ProviderBaiLian
+	}
+
+	mu.RLock()
+	factory, ok := chatApps[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown chat provider: %s", name)
+	}
+	return factory(c), nil
+}
+
+// RegisterAsrApp 注册语音识别供应商, 由各实现在init中调用
+func RegisterAsrApp(name string, factory AsrAppFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+	asrApps[name] = factory
+}
+
+// NewAsrApp 根据配置中的Asr.Provider创建语音识别, 未配置时使用火山引擎
+func NewAsrApp(c *config.Config, opts *AsrOptions) (AsrApp, error) {
+	name := c.Asr.Provider
+	if name == "" {
+		name = consts.ProviderVolc
+	}
+
+	mu.RLock()
+	factory, ok := asrApps[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown asr provider: %s", name)
+	}
+	return factory(c, opts)
+}
+
+// RegisterTtsApp 注册语音合成供应商, 由各实现在init中调用
+func RegisterTtsApp(name string, factory TtsAppFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+	ttsApps[name] = factory
+}
+
+// NewTtsApp 根据配置中的Tts.Provider创建语音合成, 未配置时使用火山引擎, tc为nil时使用默认参数
+func NewTtsApp(c *config.Config, tc *TtsConfig) (TtsApp, error) {
+	name := c.Tts.Provider
+	if name == "" {
+		name = consts.ProviderVolc
+	}
+
+	mu.RLock()
+	factory, ok := ttsApps[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown tts provider: %s", name)
+	}
+	if tc == nil {
+		tc = &TtsConfig{}
+	}
+	return factory(c, tc)
+}
@@ -124,12 +124,26 @@ func receiveResults(ctx context.Context, t *testing.T, app *VcAsrApp, output *os
 				return
 			}
 
-			if len(res) > 0 {
-				log.Printf("识别结果: %s", res)
-				if _, err := output.WriteString(string(res) + "\n"); err != nil {
+			if res != nil && res.Text != "" {
+				log.Printf("识别结果: %s, 结束: %v", res.Text, res.Final)
+				if _, err := output.WriteString(res.Text + "\n"); err != nil {
 					t.Errorf("写入结果失败: %v", err)
 				}
 			}
 		}
 	}
 }
+
+func TestParseAsrResult(t *testing.T) {
+	res, err := parseAsrResult([]byte(`{"result":{"text":"今天我有点","utterances":[{"text":"今天我有点","definite":false}]}}`))
+	if err != nil || res.Text != "今天我有点" || res.Final {
+		t.Errorf("partial result = %+v, err = %v", res, err)
+	}
+	res, err = parseAsrResult([]byte(`{"result":{"text":"今天我有点难过。","utterances":[{"text":"今天我有点难过。","definite":true}]}}`))
+	if err != nil || res.Text != "今天我有点难过。" || !res.Final {
+		t.Errorf("final result = %+v, err = %v", res, err)
+	}
+	if _, err = parseAsrResult([]byte(`{}`)); err == nil {
+		t.Error("expected error for empty result")
+	}
+}
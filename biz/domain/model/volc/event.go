@@ -17,10 +17,12 @@ const (
 
 	// 上行Session事件
 	EventStartSession  Event = 100
+	EventCancelSession Event = 101
 	EventFinishSession Event = 102
 
 	// 下行Session事件
 	EventSessionStarted  Event = 150
+	EventSessionCanceled Event = 151
 	EventSessionFinished Event = 152
 	EventSessionFailed   Event = 153
 
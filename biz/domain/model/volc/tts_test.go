@@ -4,6 +4,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
 )
 
 // 测试前请替换以下参数为有效值
@@ -50,7 +52,7 @@ func TestTTSGeneration(t *testing.T) {
 		t.Log("start at: ", time.Now().String())
 		testText := []string{"你好呀", "小朋友", "我是张老师", "很高兴你能来我聊天。", "我能知道你叫什么名字吗?"}
 		for _, text := range testText {
-			if err := app.Send(text); err != nil {
+			if err := app.Send(text, nil); err != nil {
 				t.Errorf("文本发送失败: %v", err)
 				return
 			}
@@ -60,10 +62,15 @@ func TestTTSGeneration(t *testing.T) {
 	}()
 
 	for {
-		data := app.Receive()
-		if data == nil || len(data) == 0 {
+		frame := app.Receive()
+		if frame == nil {
 			break
 		}
+		if frame.Type != model.TtsAudio {
+			t.Logf("sentence event %d: %s, words: %d", frame.Type, frame.Text, len(frame.Words))
+			continue
+		}
+		data := frame.Audio
 		t.Logf("get a data with len: %d, at %s ", len(data), time.Now().String())
 		audioData = append(audioData, data...)
 	}
@@ -86,3 +93,28 @@ func TestTTSGeneration(t *testing.T) {
 
 	t.Logf("成功生成音频文件，大小: %d 字节", fileInfo.Size())
 }
+
+func TestSentenceFrame(t *testing.T) {
+	frame := sentenceFrame(EventTTSSentenceEnd, []byte(`{"text":"你好呀","words":[{"word":"你","startTime":0.025,"endTime":0.2},{"word":"好","startTime":0.2,"endTime":0.41}]}`))
+	if frame.Type != model.TtsSentenceEnd || frame.Text != "你好呀" || len(frame.Words) != 2 {
+		t.Fatalf("unexpected frame: %+v", frame)
+	}
+	if w := frame.Words[1]; w.Word != "好" || w.Start != 200 || w.End != 410 {
+		t.Errorf("unexpected word: %+v", w)
+	}
+
+	frame = sentenceFrame(EventTTSSentenceStart, []byte(`{"res_params":{"text":"我是小助手"}}`))
+	if frame.Type != model.TtsSentenceStart || frame.Text != "我是小助手" || frame.Words != nil {
+		t.Errorf("unexpected frame: %+v", frame)
+	}
+}
+
+func TestStyleRate(t *testing.T) {
+	// 没有调整的风格保持会话默认语速, 加快的风格比默认更快
+	if styleRate(0) != speechRate || styleRate(10) <= speechRate || styleRate(-15) >= speechRate {
+		t.Errorf("styleRate = %d, %d, %d", styleRate(0), styleRate(10), styleRate(-15))
+	}
+	if styleRate(100) != 100 || styleRate(-100) != -50 {
+		t.Errorf("styleRate out of range = %d, %d", styleRate(100), styleRate(-100))
+	}
+}
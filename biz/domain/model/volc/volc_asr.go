@@ -8,18 +8,34 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/audio"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/util"
 	"golang.org/x/net/context"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 )
 
 var _ model.AsrApp = (*VcAsrApp)(nil)
 
+func init() {
+	model.RegisterAsrApp(consts.ProviderVolc, func(c *config.Config, opts *model.AsrOptions) (model.AsrApp, error) {
+		if opts.Format != audio.Target() && opts.Format.Container != audio.ContainerOgg {
+			return nil, fmt.Errorf("%w: volc asr needs 16k pcm or ogg", audio.ErrUnsupportedFormat)
+		}
+		app := NewVcAsrApp(c.VolcAsr.AppKey, c.VolcAsr.AccessKey, c.VolcAsr.ResourceId, c.VolcAsr.Url)
+		app.SetEndWindow(opts.EndWindow)
+		app.SetFormat(opts.Format)
+		return app, nil
+	})
+}
+
 // VcAsrApp 是火山引擎的大模型语音识别
 // 默认双向流式, 暂定一次对话共用一个连接, 如果太长了就一段话一个连接
-// 目前只支持单声道音频, 默认使用pcm格式, 16000采样频率, 增量返回
+// 默认使用pcm格式, 16000采样频率的16位单声道音频, 也支持ogg封装的opus, 增量返回
 type VcAsrApp struct {
 	// ws 连接
 	ws         *websocket.Conn
@@ -39,6 +55,10 @@ type VcAsrApp struct {
 	sessionId string
 	// header 是请求头, 携带鉴权信息
 	header http.Header
+	// endWindow 判断一句话结束的静音时长, 单位毫秒
+	endWindow int
+	// format 发送的音频格式
+	format audio.Format
 }
 
 // NewVcAsrApp 构造一个新的
@@ -57,6 +77,8 @@ func NewVcAsrApp(appKey, accessKey, resourceId, url string) *VcAsrApp {
 		sessionId:  sessionId,
 		seq:        1,
 		mu:         sync.Mutex{},
+		endWindow:  defaultEndWindow,
+		format:     audio.Target(),
 	}
 	app.buildHTTPHeader()
 	return app
@@ -92,18 +114,14 @@ func (app *VcAsrApp) Start() error {
 		"user": map[string]any{
 			"uid": "test",
 		},
-		// 音频参数 TODO: 目前格式均固定, 之后允许配置
-		"audio": map[string]any{
-			"format":      "pcm", // 格式,  pcm/wav/ogg
-			"sample_rate": 16000, // 采样频率, 只支持16000
-			"bits":        16,    // 采样位数, 默认16 TODO: 确认
-			"channels":    1,     // 单声道, TODO: 确认
-			"codec":       "raw", // 编码方式, raw(pcm)
-		},
+		// 音频参数, 其他格式由audio.Transcoder在发送前转换
+		"audio": app.audioParams(),
 		"request": map[string]any{
-			"model_name":  "bigmodel", // 目前只有这个模型
-			"enable_punc": true,       // 启用标点
-			"result_type": "single",   // 增量返回
+			"model_name":      "bigmodel", // 目前只有这个模型
+			"enable_punc":     true,       // 启用标点
+			"result_type":     "single",   // 增量返回
+			"show_utterances": true,       // 返回分句信息, 用于判断一句话是否结束
+			"end_window_size": app.endWindow,
 		},
 	}
 
@@ -161,17 +179,60 @@ func (app *VcAsrApp) Send(data []byte) error {
 	return nil
 }
 
+// defaultEndWindow 判断一句话结束的默认静音时长, 单位毫秒
+const defaultEndWindow = 800
+
+// SetEndWindow 设置判断一句话结束的静音时长, 需要在Start之前调用
+func (app *VcAsrApp) SetEndWindow(ms int) {
+	if ms > 0 {
+		app.endWindow = ms
+	}
+}
+
+// SetFormat 设置发送的音频格式, 需要在Start之前调用, 只支持16000采样频率的16位单声道pcm和ogg封装的opus
+func (app *VcAsrApp) SetFormat(f audio.Format) {
+	app.format = f
+}
+
+// audioParams 握手时的音频参数
+func (app *VcAsrApp) audioParams() map[string]any {
+	if app.format.Container == audio.ContainerOgg {
+		return map[string]any{
+			"format": "ogg",  // ogg封装
+			"codec":  "opus", // 编码方式, opus
+		}
+	}
+	return map[string]any{
+		"format":      "pcm",                  // 格式,  pcm/wav/ogg
+		"sample_rate": audio.TargetSampleRate, // 采样频率, 只支持16000
+		"bits":        audio.TargetBits,       // 采样位数
+		"channels":    audio.TargetChannels,   // 单声道
+		"codec":       "raw",                  // 编码方式, raw(pcm)
+	}
+}
+
+// asrResponse 识别结果, 分句的definite为true时表示这句话已经结束
+type asrResponse struct {
+	Result *struct {
+		Text       string `json:"text"`
+		Utterances []struct {
+			Text     string `json:"text"`
+			Definite bool   `json:"definite"`
+		} `json:"utterances"`
+	} `json:"result"`
+}
+
 // Receive 接受响应
-func (app *VcAsrApp) Receive() (string, error) {
+func (app *VcAsrApp) Receive() (*model.AsrResult, error) {
 	if app.ws == nil {
 		log.Error("ws is nil")
 	}
 	mt, res, err := app.ws.ReadMessage()
 	if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
-		return "", io.EOF
+		return nil, io.EOF
 	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	switch mt {
@@ -180,36 +241,46 @@ func (app *VcAsrApp) Receive() (string, error) {
 	case websocket.TextMessage:
 		return app.receiveText(res)
 	default:
-		return "", fmt.Errorf("invalid websocket message")
+		return nil, fmt.Errorf("invalid websocket message")
 	}
 }
 
 // receiveText 接受到文本消息, 暂无实际用途
-func (app *VcAsrApp) receiveText(res []byte) (string, error) {
+func (app *VcAsrApp) receiveText(res []byte) (*model.AsrResult, error) {
 	log.Info("receiveText: ", string(res))
-	return "", nil
+	return nil, nil
 }
 
 // receiveBytes 接收到字节流
-func (app *VcAsrApp) receiveBytes(res []byte) (string, error) {
+func (app *VcAsrApp) receiveBytes(res []byte) (*model.AsrResult, error) {
 	data, seq, err := parse(res)
 	// seq 小于0 表示这是最后一个包, 后续没有了, 暂时没有通过这个来中止
 	if err != nil || seq < 0 {
-		return "", err
+		return nil, err
 	}
+	return parseAsrResult(data)
+}
 
-	// 反序列化, 提前识别后的文字
-	r := make(map[string]any)
-	err = json.Unmarshal(data, &r)
-	if err != nil {
-		return "", err
+// parseAsrResult 反序列化识别结果, 有已结束的分句时只返回已结束的文字
+func parseAsrResult(data []byte) (*model.AsrResult, error) {
+	var r asrResponse
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if r.Result == nil {
+		return nil, errors.New("invalid result")
 	}
 
-	text, ok := r["result"].(map[string]any)["text"].(string)
-	if !ok {
-		return "", errors.New("invalid result")
+	var final strings.Builder
+	for _, u := range r.Result.Utterances {
+		if u.Definite {
+			final.WriteString(u.Text)
+		}
+	}
+	if final.Len() > 0 {
+		return &model.AsrResult{Text: final.String(), Final: true}, nil
 	}
-	return text, nil
+	return &model.AsrResult{Text: r.Result.Text}, nil
 }
 
 // Close 释放资源
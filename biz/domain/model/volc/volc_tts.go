@@ -17,13 +17,23 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
 )
 
 var _ model.TtsApp = (*VcTtsApp)(nil)
 
+func init() {
+	model.RegisterTtsApp(consts.ProviderVolc, func(c *config.Config, tc *model.TtsConfig) (model.TtsApp, error) {
+		app := NewVcTtsApp(c.VolcTts.AppKey, c.VolcTts.AccessKey, c.VolcTts.Speaker, c.VolcTts.ResourceId, c.VolcTts.Url)
+		app.SetConfig(tc)
+		return app, nil
+	})
+}
+
 // VcTtsApp 是火山引擎的大模型语音合成
 // 默认双向流式, 暂定一次对话共用一个连接, 如果太长了之后就一轮话一个连接
-// 默认使用PCM格式, 24000采样频率
+// 默认使用PCM格式, 24000采样频率, 可以通过SetConfig按对话指定格式、采样频率和音色
 type VcTtsApp struct {
 	// ws 连接
 	ws *websocket.Conn
@@ -31,7 +41,6 @@ type VcTtsApp struct {
 
 	appKey     string
 	accessKey  string
-	speaker    string
 	resourceId string
 	url        string
 
@@ -43,6 +52,34 @@ type VcTtsApp struct {
 	sessionId string
 	// header 是请求头, 携带鉴权信息
 	header http.Header
+	// params 是开启session时的参数, 打断后重新开启session时复用
+	params *TTSReqParams
+	// started 在当前session开启后关闭, 用于在新session就绪前阻塞发送
+	started chan struct{}
+	// config 本次对话的音频格式、采样频率和音色
+	config model.TtsConfig
+}
+
+// sessionWait 等待session开启的最长时间
+const sessionWait = 5 * time.Second
+
+// sampleRate 合成音频的默认采样频率
+const sampleRate = 24000
+
+// speechRate 会话的默认语速, 语音风格的Rate在此基础上调整
+const speechRate = 14
+
+// sentencePayload 句子开始和结束事件的响应, 开启时间戳后结束事件携带逐字时间
+type sentencePayload struct {
+	Text      string `json:"text"`
+	ResParams *struct {
+		Text string `json:"text"`
+	} `json:"res_params"`
+	Words []struct {
+		Word      string  `json:"word"`
+		StartTime float64 `json:"startTime"`
+		EndTime   float64 `json:"endTime"`
+	} `json:"words"`
 }
 
 func NewVcTtsApp(appKey, accessKey, speaker, resourceId, url string) *VcTtsApp {
@@ -53,18 +90,56 @@ func NewVcTtsApp(appKey, accessKey, speaker, resourceId, url string) *VcTtsApp {
 		ws:         nil,
 		appKey:     appKey,
 		accessKey:  accessKey,
-		speaker:    speaker,
 		url:        url,
 		resourceId: resourceId,
 		connId:     connId,
 		logId:      logId,
 		sessionId:  sessionId,
 		mu:         sync.Mutex{},
+		started:    make(chan struct{}),
+		config: model.TtsConfig{
+			Format:     model.TtsFormatPCM,
+			SampleRate: sampleRate,
+			Speaker:    speaker,
+		},
 	}
 	app.buildHTTPHeader()
 	return app
 }
 
+// SetConfig 设置本次对话的合成参数, 需要在Start之前调用, 零值字段保持默认值
+func (app *VcTtsApp) SetConfig(c *model.TtsConfig) {
+	if c == nil {
+		return
+	}
+	if c.Format != "" {
+		app.config.Format = c.Format
+	}
+	if c.SampleRate > 0 {
+		app.config.SampleRate = c.SampleRate
+	}
+	if c.Speaker != "" {
+		app.config.Speaker = c.Speaker
+	}
+}
+
+// audioParams 按本次对话的配置构造音频参数
+func (app *VcTtsApp) audioParams() *AudioParams {
+	return &AudioParams{
+		Format:          app.config.Format,
+		SampleRate:      int32(app.config.SampleRate),
+		EnableTimestamp: true,
+	}
+}
+
+// duration 计算一段音频的时长, 只有pcm可以直接计算, 其他格式返回0
+func (app *VcTtsApp) duration(audio []byte) int64 {
+	if app.config.Format != model.TtsFormatPCM || app.config.SampleRate <= 0 {
+		return 0
+	}
+	return int64(len(audio)) * 1000 / int64(app.config.SampleRate*2)
+}
+
 // Dial 建立ws连接
 func (app *VcTtsApp) Dial() error {
 	conn, r, err := websocket.DefaultDialer.DialContext(context.Background(), app.url, app.header)
@@ -88,18 +163,17 @@ func (app *VcTtsApp) Start() (err error) {
 		return
 	}
 	namespace := "BidirectionalTTS"
-	// TODO: 之后可能需要指定采样频率
+	audio := app.audioParams()
+	audio.SpeechRate = speechRate
 	params := &TTSReqParams{
-		Speaker: app.speaker,
-		AudioParams: &AudioParams{
-			Format:     "pcm",
-			SampleRate: 24000,
-			SpeechRate: 14,
-		},
+		Speaker:     app.config.Speaker,
+		AudioParams: audio,
 	}
+	app.params = params
 	if err = app.startTTSSession(namespace, params); err != nil {
 		return
 	}
+	close(app.started)
 	return
 }
 
@@ -203,22 +277,40 @@ func (app *VcTtsApp) startTTSSession(namespace string, params *TTSReqParams) err
 }
 
 // Send 发送请求
-func (app *VcTtsApp) Send(text string) (err error) {
-	return app.sendTtsMessage(text)
+func (app *VcTtsApp) Send(text string, style *model.VoiceStyle) (err error) {
+	return app.sendTtsMessage(text, style)
 }
 
-// sendTtsMessage 发送一条tts消息
-func (app *VcTtsApp) sendTtsMessage(text string) error {
+// styleRate 语音风格的Rate是相对正常语速的调整, 换算为相对会话默认语速的绝对值, 限制在[-50, 100]
+func styleRate(rate int32) int32 {
+	return min(max(speechRate+rate, -50), 100)
+}
+
+// sendTtsMessage 发送一条tts消息, 语音风格只对这一条消息生效
+func (app *VcTtsApp) sendTtsMessage(text string, style *model.VoiceStyle) error {
+	// 打断后新的session可能还未开启, 需要等待就绪
+	app.mu.Lock()
+	started := app.started
+	app.mu.Unlock()
+	select {
+	case <-started:
+	case <-time.After(sessionWait):
+		return fmt.Errorf("wait SessionStarted timeout")
+	}
+
+	audio := app.audioParams()
+	if style != nil {
+		audio.Emotion = style.Emotion
+		audio.SpeechRate = styleRate(style.Rate)
+		audio.Volume = style.Volume
+	}
 	req := TTSRequest{
 		Event:     int32(EventTaskRequest),
 		Namespace: "BidirectionalTTS",
 		ReqParams: &TTSReqParams{
-			Text:    text,
-			Speaker: app.speaker,
-			AudioParams: &AudioParams{
-				Format:     "pcm",
-				SampleRate: 24000,
-			},
+			Text:        text,
+			Speaker:     app.config.Speaker,
+			AudioParams: audio,
 		},
 	}
 	payload, err := json.Marshal(&req)
@@ -231,16 +323,16 @@ func (app *VcTtsApp) sendTtsMessage(text string) error {
 		return fmt.Errorf("create TaskRequest request message: %w", err)
 	}
 	msg.Event = req.Event
-	msg.SessionID = app.sessionId
 	msg.Payload = payload
 
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	msg.SessionID = app.sessionId
 	frame, err := protocol.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("marshal TaskRequest request message: %w", err)
 	}
 
-	app.mu.Lock()
-	defer app.mu.Unlock()
 	if err := app.ws.WriteMessage(websocket.BinaryMessage, frame); err != nil {
 		return fmt.Errorf("send TaskRequest request: %w", err)
 	}
@@ -250,7 +342,7 @@ func (app *VcTtsApp) sendTtsMessage(text string) error {
 }
 
 // Receive 接收请求
-func (app *VcTtsApp) Receive() []byte {
+func (app *VcTtsApp) Receive() *model.TtsFrame {
 	for {
 		msg, err := app.receiveMessage()
 		if err != nil {
@@ -260,15 +352,35 @@ func (app *VcTtsApp) Receive() []byte {
 		switch msg.Type {
 		case MsgTypeFullServer:
 			glog.Infof("Receive text message (event=%s, session_id=%s): %s", Event(msg.Event), msg.SessionID, msg.Payload)
-			if msg.Event == int32(EventSessionFinished) {
+			switch Event(msg.Event) {
+			case EventSessionStarted:
+				app.ready(msg.SessionID)
+			case EventSessionFinished:
+				// 被打断的session结束时继续接收, 只有当前session结束才表示合成不可用
+				if !app.current(msg.SessionID) {
+					continue
+				}
 				log.Info("event type:", msg.Event)
 				return nil
+			case EventTTSSentenceStart, EventTTSSentenceEnd:
+				if !app.current(msg.SessionID) {
+					continue
+				}
+				return sentenceFrame(Event(msg.Event), msg.Payload)
 			}
 			continue
 
 		case MsgTypeAudioOnlyServer:
 			glog.Infof("Receive audio message (event=%s): session_id=%s", Event(msg.Event), msg.SessionID)
-			return msg.Payload
+			// 被打断的session中残留的音频直接丢弃
+			if !app.current(msg.SessionID) {
+				continue
+			}
+			return &model.TtsFrame{
+				Type:     model.TtsAudio,
+				Audio:    msg.Payload,
+				Duration: app.duration(msg.Payload),
+			}
 
 		case MsgTypeError:
 			glog.Errorf("Receive Error message (code=%d): %s", msg.ErrorCode, msg.Payload)
@@ -280,6 +392,101 @@ func (app *VcTtsApp) Receive() []byte {
 	}
 }
 
+// sentenceFrame 解析句子开始和结束事件, 时间戳由秒转换为毫秒
+func sentenceFrame(event Event, payload []byte) *model.TtsFrame {
+	frame := &model.TtsFrame{Type: model.TtsSentenceStart}
+	if event == EventTTSSentenceEnd {
+		frame.Type = model.TtsSentenceEnd
+	}
+	var p sentencePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		log.Error("unmarshal sentence payload err:", err)
+		return frame
+	}
+	frame.Text = p.Text
+	if frame.Text == "" && p.ResParams != nil {
+		frame.Text = p.ResParams.Text
+	}
+	for _, w := range p.Words {
+		frame.Words = append(frame.Words, &model.TtsWord{
+			Word:  w.Word,
+			Start: int64(w.StartTime * 1000),
+			End:   int64(w.EndTime * 1000),
+		})
+	}
+	return frame
+}
+
+// Cancel 打断当前合成
+// 取消当前session并立即开启一个新的session, 旧session中尚未返回的音频会在Receive中被丢弃
+// 新session的SessionStarted响应由Receive处理, 此处不读取ws, 避免和接收协程竞争
+func (app *VcTtsApp) Cancel() error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.ws == nil {
+		return nil
+	}
+
+	if err := app.sendSessionEvent(EventCancelSession, app.sessionId, []byte("{}")); err != nil {
+		return err
+	}
+
+	req := TTSRequest{
+		Event:     int32(EventStartSession),
+		Namespace: "BidirectionalTTS",
+		ReqParams: app.params,
+	}
+	payload, err := json.Marshal(&req)
+	if err != nil {
+		return fmt.Errorf("marshal StartSession request payload: %w", err)
+	}
+	app.sessionId = uuid.New().String()
+	app.started = make(chan struct{})
+	return app.sendSessionEvent(EventStartSession, app.sessionId, payload)
+}
+
+// sendSessionEvent 发送一个session级别的事件, 不等待响应, 调用方需持有锁
+func (app *VcTtsApp) sendSessionEvent(event Event, sessionId string, payload []byte) error {
+	msg, err := NewMessage(MsgTypeFullClient, MsgTypeFlagWithEvent)
+	if err != nil {
+		return fmt.Errorf("create %s request message: %w", event, err)
+	}
+	msg.Event = int32(event)
+	msg.SessionID = sessionId
+	msg.Payload = payload
+
+	frame, err := protocol.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal %s request message: %w", event, err)
+	}
+	if err = app.ws.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+		return fmt.Errorf("send %s request: %w", event, err)
+	}
+	glog.Infof("%s request is sent.", event)
+	return nil
+}
+
+// current 判断是否为当前session
+func (app *VcTtsApp) current(sessionId string) bool {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return app.sessionId == sessionId
+}
+
+// ready 标记当前session已开启
+func (app *VcTtsApp) ready(sessionId string) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.sessionId != sessionId {
+		return
+	}
+	select {
+	case <-app.started:
+	default:
+		close(app.started)
+	}
+}
+
 // receiveMessage 从ws中接受消息
 func (app *VcTtsApp) receiveMessage() (*Message, error) {
 	mt, frame, err := app.ws.ReadMessage()
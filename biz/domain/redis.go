@@ -38,6 +38,15 @@ func (r *RedisHelper) AddUser(sessionId, msg string) error {
 	return r.add(sessionId, "user", msg)
 }
 
+// AddInterruptedAi 添加被用户打断的ai对话记录
+func (r *RedisHelper) AddInterruptedAi(sessionId, msg string) error {
+	return r.push(sessionId, &dto.ChatHistory{
+		Role:        "ai",
+		Content:     msg,
+		Interrupted: true,
+	})
+}
+
 // AddSystem 添加系统对话记录
 func (r *RedisHelper) AddSystem(sessionId, msg string) error {
 	return r.add(sessionId, "system", msg)
@@ -45,11 +54,14 @@ func (r *RedisHelper) AddSystem(sessionId, msg string) error {
 
 // add 将对话记录添加到队列尾部
 func (r *RedisHelper) add(sessionId, role, msg string) error {
-	history := dto.ChatHistory{
+	return r.push(sessionId, &dto.ChatHistory{
 		Role:    role,
 		Content: msg,
-	}
+	})
+}
 
+// push 将一条对话记录序列化后添加到队列尾部
+func (r *RedisHelper) push(sessionId string, history *dto.ChatHistory) error {
 	data, err := json.Marshal(history)
 	if err != nil {
 		return err
@@ -0,0 +1,101 @@
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/domain/model/openai"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+var _ Detector = (*ClassifierDetector)(nil)
+
+// defaultClassifierPrompt 默认的风险分类提示词
+const defaultClassifierPrompt = `你是中小学心理健康风险评估助手。请判断下面这段话是否表现出自伤、自杀、被伤害或严重心理危机的风险。
+只输出一个JSON对象, 不要输出其他内容, 格式为 {"level": "none|low|medium|high", "reason": "简短理由"}`
+
+// ClassifierDetector 调用第二个大模型对文本进行风险分类
+type ClassifierDetector struct {
+	app    model.ChatApp
+	source Source
+}
+
+// NewClassifierDetector 根据配置创建分类检测器, source为空时只检测用户输入
+func NewClassifierDetector(c *config.RiskClassifier) *ClassifierDetector {
+	prompt := c.Prompt
+	if prompt == "" {
+		prompt = defaultClassifierPrompt
+	}
+	source := Source(c.Source)
+	if source == "" {
+		source = SourceUser
+	}
+	return &ClassifierDetector{
+		app:    openai.NewOAChatApp(c.Url, c.ApiKey, c.Model, prompt),
+		source: source,
+	}
+}
+
+func (d *ClassifierDetector) Name() string {
+	return "classifier"
+}
+
+// classifierResult 分类模型的输出
+type classifierResult struct {
+	Level  string `json:"level"`
+	Reason string `json:"reason"`
+}
+
+// Detect 调用分类模型, ctx取消时中止调用
+func (d *ClassifierDetector) Detect(ctx context.Context, in *Input) (*Hit, error) {
+	if in.Source != d.source {
+		return nil, nil
+	}
+
+	scanner, err := d.app.StreamCall([]*dto.ChatMessage{{Role: consts.RoleUser, Content: in.Text}})
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = scanner.Close() })
+	defer func() {
+		stop()
+		_ = scanner.Close()
+	}()
+
+	var sb strings.Builder
+	for {
+		data, err := scanner.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(data.Content)
+	}
+
+	// 模型可能在JSON外包裹markdown代码块
+	text := sb.String()
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("invalid classifier output: " + text)
+	}
+	var res classifierResult
+	if err = json.Unmarshal([]byte(text[start:end+1]), &res); err != nil {
+		return nil, err
+	}
+	level, err := ParseLevel(res.Level)
+	if err != nil || level == LevelNone {
+		return nil, err
+	}
+	return &Hit{
+		Level:    level,
+		Evidence: []string{res.Reason},
+	}, nil
+}
@@ -0,0 +1,219 @@
+package risk
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/domain"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/alert"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	riskmapper "github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/risk"
+)
+
+// detectTimeout 单次检测的最长时间, 主要限制分类模型的调用
+const detectTimeout = 30 * time.Second
+
+// RiskDetector 风险检测流水线, 依次运行所有检测器, 并将命中结果交给处理器
+type RiskDetector struct {
+	detectors []Detector
+	sentinel  *SentinelDetector
+	handlers  []Handler
+}
+
+// NewRiskDetector 创建风险检测流水线, 开销小的检测器排在前面
+func NewRiskDetector(sentinel *SentinelDetector, detectors []Detector, handlers ...Handler) *RiskDetector {
+	return &RiskDetector{
+		detectors: append([]Detector{sentinel}, detectors...),
+		sentinel:  sentinel,
+		handlers:  handlers,
+	}
+}
+
+var (
+	instance *RiskDetector
+	once     sync.Once
+)
+
+// GetRiskDetector 根据配置获取风险检测流水线单例
+func GetRiskDetector() *RiskDetector {
+	once.Do(func() {
+		c := config.GetConfig().Risk
+		var detectors []Detector
+		lexicon, err := NewLexiconDetector(c.Lexicon)
+		if err != nil {
+			log.Error("风险词库加载失败:", err)
+		} else {
+			detectors = append(detectors, lexicon)
+		}
+		if c.Classifier.Enable {
+			detectors = append(detectors, NewClassifierDetector(&c.Classifier))
+		}
+		instance = NewRiskDetector(NewSentinelDetector(c.Sentinel), detectors, persist, notify)
+	})
+	return instance
+}
+
+// NewSession 创建一次对话的风险检测
+func (d *RiskDetector) NewSession(meta Meta) *Session {
+	return &Session{
+		d:    d,
+		meta: meta,
+	}
+}
+
+// Session 一次对话的风险检测
+// 同一对话中只有风险等级升高时才会产生新的事件, 避免流式输出的每个片段都触发预警
+type Session struct {
+	d    *RiskDetector
+	meta Meta
+
+	mu sync.Mutex
+	// level 已产生事件的最高等级
+	level Level
+}
+
+// Inspect 异步检测一段文本, 不阻塞对话
+func (s *Session) Inspect(source Source, text string) {
+	if text == "" {
+		return
+	}
+	go s.inspect(&Input{
+		Source: source,
+		Text:   text,
+	})
+}
+
+// Strip 去除对话模型输出中的风险标记
+func (s *Session) Strip(text string) string {
+	return s.d.sentinel.Strip(text)
+}
+
+// Level 本次对话已产生事件的最高风险等级
+func (s *Session) Level() Level {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.level
+}
+
+// Flag 处理对话模型通过<risk>标记主动报告的风险, 不阻塞对话
+// content为风险等级, 可以在等级后用冒号附带原因, 如"high: 提到自伤"; 无法解析等级时按高风险处理
+func (s *Session) Flag(content string) {
+	hit := flagHit(content)
+	if hit.Level == LevelNone {
+		return
+	}
+	go s.emit(tagDetector, &Input{Source: SourceAI, Text: content}, hit)
+}
+
+// inspect 运行所有检测器
+func (s *Session) inspect(in *Input) {
+	ctx, cancel := context.WithTimeout(context.Background(), detectTimeout)
+	defer cancel()
+
+	for _, detector := range s.d.detectors {
+		hit, err := detector.Detect(ctx, in)
+		if err != nil {
+			log.Error("risk detect err, detector: %s, err: %v", detector.Name(), err)
+			continue
+		}
+		if hit == nil || hit.Level == LevelNone {
+			continue
+		}
+		s.emit(detector.Name(), in, hit)
+	}
+}
+
+// emit 产生风险事件, 等级没有升高时丢弃
+func (s *Session) emit(detector string, in *Input, hit *Hit) {
+	s.mu.Lock()
+	if hit.Level <= s.level {
+		s.mu.Unlock()
+		return
+	}
+	s.level = hit.Level
+	s.mu.Unlock()
+
+	event := &RiskEvent{
+		Meta:       s.meta,
+		Level:      hit.Level,
+		Source:     in.Source,
+		Detector:   detector,
+		Evidence:   hit.Evidence,
+		Text:       in.Text,
+		CreateTime: time.Now(),
+	}
+	for _, h := range s.d.handlers {
+		h(context.Background(), event)
+	}
+}
+
+// persist 将风险事件写入数据库, 供老师查看和处理
+func persist(ctx context.Context, event *RiskEvent) {
+	if err := riskmapper.GetMongoMapper().Insert(ctx, &riskmapper.RiskEvent{
+		SessionId:  event.SessionId,
+		UserId:     event.UserId,
+		UnitId:     event.UnitId,
+		StudentId:  event.StudentId,
+		Name:       event.Name,
+		Class:      event.Class,
+		Level:      event.Level.String(),
+		Source:     string(event.Source),
+		Detector:   event.Detector,
+		Evidence:   event.Evidence,
+		Text:       event.Text,
+		CreateTime: event.CreateTime,
+	}); err != nil {
+		log.Error("风险事件保存失败, session: %s, err: %v", event.SessionId, err)
+	}
+}
+
+// excerptSize 预警中附带的对话节选条数
+const excerptSize = 6
+
+// notify 风险等级达到配置的阈值时, 向学生所在单位发送预警
+func notify(ctx context.Context, event *RiskEvent) {
+	log.Info("risk event, session: %s, student: %s, level: %s, detector: %s, evidence: %v",
+		event.SessionId, event.StudentId, event.Level, event.Detector, event.Evidence)
+	threshold, err := ParseLevel(config.GetConfig().Alert.MinLevel)
+	if err != nil || threshold == LevelNone {
+		threshold = LevelHigh
+	}
+	if event.Level < threshold {
+		return
+	}
+	alert.GetNotifier().Notify(ctx, toMessage(event, excerpt(event)))
+}
+
+// excerpt 获取触发预警时最近的对话记录, 读取失败时只使用触发风险的原文
+func excerpt(event *RiskEvent) []*alert.Line {
+	history, err := domain.GetRedisHelper().Load(event.SessionId)
+	if err != nil || len(history) == 0 {
+		return []*alert.Line{{Role: string(event.Source), Content: event.Text}}
+	}
+	if len(history) > excerptSize {
+		history = history[len(history)-excerptSize:]
+	}
+	lines := make([]*alert.Line, 0, len(history))
+	for _, his := range history {
+		lines = append(lines, &alert.Line{Role: his.Role, Content: his.Content})
+	}
+	return lines
+}
+
+// toMessage 将风险事件转换为预警消息
+func toMessage(event *RiskEvent, lines []*alert.Line) *alert.Message {
+	return &alert.Message{
+		SessionId: event.SessionId,
+		UnitId:    event.UnitId,
+		StudentId: event.StudentId,
+		Name:      event.Name,
+		Class:     event.Class,
+		Level:     event.Level.String(),
+		Detector:  event.Detector,
+		Evidence:  event.Evidence,
+		Excerpt:   lines,
+		Time:      event.CreateTime,
+	}
+}
@@ -0,0 +1,83 @@
+package risk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+func TestLexiconDetector_Detect(t *testing.T) {
+	d, err := NewLexiconDetector([]config.RiskRule{
+		{Pattern: "不想活|自杀", Level: "high", Source: "user"},
+		{Pattern: "失眠|睡不着", Level: "low"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hit, err := d.Detect(context.Background(), &Input{Source: SourceUser, Text: "最近睡不着, 有时候觉得不想活了"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hit == nil || hit.Level != LevelHigh || len(hit.Evidence) != 2 {
+		t.Fatalf("unexpected hit: %+v", hit)
+	}
+
+	// 规则只检测用户输入
+	hit, _ = d.Detect(context.Background(), &Input{Source: SourceAI, Text: "不想活这种想法出现多久了"})
+	if hit != nil {
+		t.Errorf("ai source should not match user rule: %+v", hit)
+	}
+
+	if _, err = NewLexiconDetector([]config.RiskRule{{Pattern: "(", Level: "high"}}); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestSession_Dedup(t *testing.T) {
+	var events []*RiskEvent
+	lexicon, _ := NewLexiconDetector([]config.RiskRule{{Pattern: "难过", Level: "medium"}})
+	d := NewRiskDetector(NewSentinelDetector(""), []Detector{lexicon}, func(_ context.Context, e *RiskEvent) {
+		events = append(events, e)
+	})
+	s := d.NewSession(Meta{SessionId: "s1", StudentId: "20250101"})
+
+	s.inspect(&Input{Source: SourceUser, Text: "我很难过"})
+	s.inspect(&Input{Source: SourceUser, Text: "还是很难过"})
+	s.inspect(&Input{Source: SourceAI, Text: "我们一起想想办法&"})
+	s.inspect(&Input{Source: SourceAI, Text: "你并不孤单&"})
+
+	if len(events) != 2 {
+		t.Fatalf("events = %d, want 2", len(events))
+	}
+	if events[0].Level != LevelMedium || events[1].Level != LevelHigh || events[1].Detector != "sentinel" {
+		t.Errorf("unexpected events: %+v, %+v", events[0], events[1])
+	}
+	if events[1].StudentId != "20250101" {
+		t.Errorf("event should carry meta, got %+v", events[1].Meta)
+	}
+	if got := s.Strip("你并不孤单&"); got != "你并不孤单 " {
+		t.Errorf("Strip = %q", got)
+	}
+}
+
+func TestFlagHit(t *testing.T) {
+	cases := []struct {
+		content  string
+		level    Level
+		evidence []string
+	}{
+		{"medium", LevelMedium, nil},
+		{"high： 提到了自伤", LevelHigh, []string{"提到了自伤"}},
+		{"学生提到不想上学", LevelHigh, []string{"学生提到不想上学"}},
+		{"none", LevelNone, nil},
+	}
+	for _, c := range cases {
+		hit := flagHit(c.content)
+		if hit.Level != c.level || len(hit.Evidence) != len(c.evidence) ||
+			(len(c.evidence) > 0 && hit.Evidence[0] != c.evidence[0]) {
+			t.Errorf("flagHit(%q) = %+v", c.content, hit)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+var _ Detector = (*LexiconDetector)(nil)
+
+// rule 是编译后的风险词规则
+type rule struct {
+	re     *regexp.Regexp
+	level  Level
+	source Source
+}
+
+// LexiconDetector 基于风险词库的检测器, 命中多条规则时取最高等级
+type LexiconDetector struct {
+	rules []*rule
+}
+
+// NewLexiconDetector 根据配置编译风险词库
+func NewLexiconDetector(rules []config.RiskRule) (*LexiconDetector, error) {
+	d := &LexiconDetector{rules: make([]*rule, 0, len(rules))}
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile risk pattern %q: %w", r.Pattern, err)
+		}
+		level, err := ParseLevel(r.Level)
+		if err != nil {
+			return nil, err
+		}
+		d.rules = append(d.rules, &rule{
+			re:     re,
+			level:  level,
+			source: Source(r.Source),
+		})
+	}
+	return d, nil
+}
+
+func (d *LexiconDetector) Name() string {
+	return "lexicon"
+}
+
+// Detect 匹配所有适用于该来源的规则
+func (d *LexiconDetector) Detect(_ context.Context, in *Input) (*Hit, error) {
+	var hit *Hit
+	for _, r := range d.rules {
+		if r.source != "" && r.source != in.Source {
+			continue
+		}
+		matched := r.re.FindAllString(in.Text, -1)
+		if len(matched) == 0 {
+			continue
+		}
+		if hit == nil {
+			hit = &Hit{}
+		}
+		if r.level > hit.Level {
+			hit.Level = r.level
+		}
+		hit.Evidence = append(hit.Evidence, matched...)
+	}
+	return hit, nil
+}
@@ -0,0 +1,95 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Level 风险等级, 数值越大风险越高
+type Level int
+
+const (
+	LevelNone Level = iota
+	LevelLow
+	LevelMedium
+	LevelHigh
+)
+
+var levelName = map[Level]string{
+	LevelNone:   "none",
+	LevelLow:    "low",
+	LevelMedium: "medium",
+	LevelHigh:   "high",
+}
+
+func (l Level) String() string {
+	return levelName[l]
+}
+
+// ParseLevel 解析配置或模型输出中的风险等级
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for l, name := range levelName {
+		if s == name {
+			return l, nil
+		}
+	}
+	return LevelNone, fmt.Errorf("invalid risk level: %s", s)
+}
+
+// Source 被检测文本的来源
+type Source string
+
+const (
+	SourceUser Source = "user"
+	SourceAI   Source = "ai"
+)
+
+// Input 一次检测的输入
+type Input struct {
+	Source Source
+	Text   string
+}
+
+// Hit 单个检测器的命中结果
+type Hit struct {
+	Level Level
+	// Evidence 命中的证据, 如匹配到的词或模型给出的理由
+	Evidence []string
+}
+
+// Detector 风险检测器的抽象, 未命中时返回nil
+type Detector interface {
+	// Name 检测器名称, 会记录在风险事件中
+	Name() string
+
+	// Detect 检测一段文本
+	Detect(ctx context.Context, in *Input) (*Hit, error)
+}
+
+// Meta 风险事件关联的学生和对话信息
+type Meta struct {
+	SessionId string
+	UserId    string
+	UnitId    string
+	StudentId string
+	Name      string
+	Class     string
+}
+
+// RiskEvent 风险事件, 由检测器的命中结果和对话信息组成
+type RiskEvent struct {
+	Meta
+	Level    Level
+	Source   Source
+	Detector string
+	Evidence []string
+	// Text 触发风险的原文
+	Text       string
+	CreateTime time.Time
+}
+
+// Handler 处理风险事件, 如发送预警或持久化
+type Handler func(ctx context.Context, event *RiskEvent)
@@ -0,0 +1,67 @@
+package risk
+
+import (
+	"context"
+	"strings"
+)
+
+var _ Detector = (*SentinelDetector)(nil)
+
+// defaultSentinel 对话模型提示词中约定的高风险标记
+const defaultSentinel = "&"
+
+// SentinelDetector 检测对话模型自身输出的风险标记
+// 模型在判断学生存在高风险时会在回复中输出标记, 标记需要在展示和合成前去除
+type SentinelDetector struct {
+	sentinel string
+}
+
+// NewSentinelDetector 创建标记检测器, sentinel为空时使用默认标记
+func NewSentinelDetector(sentinel string) *SentinelDetector {
+	if sentinel == "" {
+		sentinel = defaultSentinel
+	}
+	return &SentinelDetector{sentinel: sentinel}
+}
+
+func (d *SentinelDetector) Name() string {
+	return "sentinel"
+}
+
+// Detect 只检测AI输出
+func (d *SentinelDetector) Detect(_ context.Context, in *Input) (*Hit, error) {
+	if in.Source != SourceAI || !strings.Contains(in.Text, d.sentinel) {
+		return nil, nil
+	}
+	return &Hit{
+		Level:    LevelHigh,
+		Evidence: []string{strings.TrimSpace(d.Strip(in.Text))},
+	}, nil
+}
+
+// Strip 去除文本中的风险标记
+func (d *SentinelDetector) Strip(text string) string {
+	return strings.ReplaceAll(text, d.sentinel, " ")
+}
+
+// tagDetector 对话模型<risk>标记产生的事件的检测器名称
+const tagDetector = "tag"
+
+// flagHit 解析<risk>标记的内容
+func flagHit(content string) *Hit {
+	content = strings.TrimSpace(content)
+	level, reason := content, ""
+	if i := strings.IndexAny(content, ":："); i >= 0 {
+		level, reason = content[:i], strings.TrimLeft(content[i:], ":：")
+	}
+	l, err := ParseLevel(level)
+	if err != nil {
+		// 没有约定的等级, 整个内容作为原因
+		return &Hit{Level: LevelHigh, Evidence: []string{content}}
+	}
+	hit := &Hit{Level: l}
+	if reason = strings.TrimSpace(reason); reason != "" {
+		hit.Evidence = []string{reason}
+	}
+	return hit
+}
@@ -0,0 +1,191 @@
+package voice
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/audio"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+// 识别和断句的事件类型
+const (
+	EventSpeechStart = "speech_start"
+	EventSpeechEnd   = "speech_end"
+	EventPartial     = "asr_partial"
+	EventFinal       = "asr_final"
+)
+
+// defaultGrace 检测到说完后等待识别结果的默认时长, 单位毫秒
+const defaultGrace = 500
+
+// Endpointer 合并语音活动检测和语音识别的结果, 判断学生什么时候说完一句话
+// 每句话分配一个递增的id, 识别服务判断结束或本地检测到静音超时后产生一次最终结果
+type Endpointer struct {
+	mu    sync.Mutex
+	vad   *audio.Vad
+	grace time.Duration
+	emit  func(*dto.AsrResp)
+
+	// id 当前或最近一句话的id
+	id uint64
+	// open 当前一句话是否还没有最终结果
+	open bool
+	// text 当前一句话最近的识别结果
+	text string
+	// forced 最近一句话由本地检测结束, 识别服务随后返回的同一句话的最终结果需要丢弃
+	forced bool
+	// timer 本地检测到说完后等待识别结果的定时器
+	timer *time.Timer
+}
+
+// NewEndpointer 创建断句器, emit用于发送事件, 可能在定时器的goroutine中调用
+func NewEndpointer(c *config.Vad, emit func(*dto.AsrResp)) *Endpointer {
+	grace := c.Grace
+	if grace <= 0 {
+		grace = defaultGrace
+	}
+	return &Endpointer{
+		vad:   audio.NewVad(c),
+		grace: time.Duration(grace) * time.Millisecond,
+		emit:  emit,
+	}
+}
+
+// Detect 检测转换后发送给识别服务的一段音频, PCM直接检测, Ogg/Opus检测转换时取出的数据包
+func (p *Endpointer) Detect(tc audio.Transcoder, out []byte) {
+	if tc.Output().IsPCM() {
+		p.Audio(out)
+	} else if src, ok := tc.(audio.PacketSource); ok {
+		p.Packets(src.Packets())
+	}
+}
+
+// Audio 检测一段PCM音频中的说话开始和结束
+func (p *Endpointer) Audio(pcm []byte) {
+	p.mu.Lock()
+	out := p.vadEvents(p.vad.Write(pcm))
+	p.mu.Unlock()
+	p.send(out)
+}
+
+// Packets 检测一组Opus数据包中的说话开始和结束
+func (p *Endpointer) Packets(packets [][]byte) {
+	var events []audio.VadEvent
+	p.mu.Lock()
+	for _, packet := range packets {
+		if e := p.vad.Packet(packet); e != 0 {
+			events = append(events, e)
+		}
+	}
+	out := p.vadEvents(events)
+	p.mu.Unlock()
+	p.send(out)
+}
+
+// vadEvents 处理语音活动检测的事件, 返回需要发送的事件, 调用方需持有锁
+func (p *Endpointer) vadEvents(events []audio.VadEvent) []*dto.AsrResp {
+	var out []*dto.AsrResp
+	for _, e := range events {
+		switch e {
+		case audio.VadStart:
+			p.stop()
+			p.begin()
+			out = append(out, p.event(EventSpeechStart, ""))
+		case audio.VadEnd:
+			out = append(out, p.event(EventSpeechEnd, ""))
+			// 等待识别服务的最终结果, 超时后以最近的识别结果结束
+			id := p.id
+			p.stop()
+			p.timer = time.AfterFunc(p.grace, func() { p.expire(id) })
+		}
+	}
+	return out
+}
+
+// Result 处理一次识别结果
+func (p *Endpointer) Result(res *model.AsrResult) {
+	if res == nil || res.Text == "" {
+		return
+	}
+	var out []*dto.AsrResp
+	p.mu.Lock()
+	switch {
+	case res.Final && !p.open && p.forced:
+		// 已经由本地检测结束的一句话
+		p.forced = false
+	case res.Final:
+		p.begin()
+		p.stop()
+		out = append(out, p.finish(res.Text))
+	default:
+		p.begin()
+		p.text = res.Text
+		out = append(out, p.event(EventPartial, res.Text))
+	}
+	p.mu.Unlock()
+	p.send(out)
+}
+
+// Close 停止定时器
+func (p *Endpointer) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+}
+
+// expire 本地检测到说完后识别服务没有及时返回最终结果
+func (p *Endpointer) expire(id uint64) {
+	var out []*dto.AsrResp
+	p.mu.Lock()
+	if p.open && p.id == id && p.text != "" {
+		out = append(out, p.finish(p.text))
+		p.forced = true
+	}
+	p.mu.Unlock()
+	p.send(out)
+}
+
+// begin 开始新的一句话, 已经开始时不做处理, 调用方需持有锁
+func (p *Endpointer) begin() {
+	if p.open {
+		return
+	}
+	p.id++
+	p.open = true
+	p.text = ""
+	p.forced = false
+}
+
+// finish 产生当前一句话的最终结果, 调用方需持有锁
+func (p *Endpointer) finish(text string) *dto.AsrResp {
+	p.open = false
+	p.text = ""
+	return p.event(EventFinal, text)
+}
+
+// stop 停止等待识别结果, 调用方需持有锁
+func (p *Endpointer) stop() {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+}
+
+func (p *Endpointer) event(typ, text string) *dto.AsrResp {
+	return &dto.AsrResp{
+		Type:        typ,
+		UtteranceId: p.id,
+		Text:        text,
+		Final:       typ == EventFinal,
+		Timestamp:   time.Now().Unix(),
+	}
+}
+
+func (p *Endpointer) send(out []*dto.AsrResp) {
+	for _, resp := range out {
+		p.emit(resp)
+	}
+}
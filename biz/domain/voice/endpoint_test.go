@@ -0,0 +1,137 @@
+package voice
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/domain/model"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/audio"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// tone 生成一段指定时长和振幅的正弦波, 振幅为0时为静音
+func tone(ms int, amplitude float64) []byte {
+	n := audio.TargetSampleRate / 1000 * ms
+	pcm := make([]byte, 2*n)
+	for i := 0; i < n; i++ {
+		s := int16(amplitude * math.Sin(2*math.Pi*200*float64(i)/audio.TargetSampleRate))
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(s))
+	}
+	return pcm
+}
+
+// recorder 记录断句器发送的事件
+type recorder struct {
+	mu     sync.Mutex
+	events []*dto.AsrResp
+}
+
+func (r *recorder) emit(resp *dto.AsrResp) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, resp)
+}
+
+func (r *recorder) types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]string, 0, len(r.events))
+	for _, e := range r.events {
+		out = append(out, e.Type)
+	}
+	return out
+}
+
+func TestEndpointer_AsrFinal(t *testing.T) {
+	r := &recorder{}
+	p := NewEndpointer(&config.Vad{}, r.emit)
+	defer p.Close()
+
+	p.Result(&model.AsrResult{Text: "今天"})
+	p.Result(&model.AsrResult{Text: "今天我很开心"})
+	p.Result(&model.AsrResult{Text: "今天我很开心。", Final: true})
+	p.Result(&model.AsrResult{Text: "你呢"})
+
+	if got := r.types(); len(got) != 4 || got[2] != EventFinal || got[3] != EventPartial {
+		t.Fatalf("events = %v", got)
+	}
+	if r.events[0].UtteranceId != 1 || r.events[2].UtteranceId != 1 || r.events[3].UtteranceId != 2 {
+		t.Errorf("unexpected utterance ids")
+	}
+	if !r.events[2].Final || r.events[2].Text != "今天我很开心。" {
+		t.Errorf("unexpected final: %+v", r.events[2])
+	}
+}
+
+func TestEndpointer_VadTimeout(t *testing.T) {
+	r := &recorder{}
+	p := NewEndpointer(&config.Vad{Silence: 200, Grace: 50}, r.emit)
+	defer p.Close()
+
+	p.Audio(tone(300, 3000))
+	p.Result(&model.AsrResult{Text: "我有点难过"})
+	p.Audio(tone(300, 0))
+	time.Sleep(150 * time.Millisecond)
+	// 识别服务随后返回的同一句话的最终结果被丢弃
+	p.Result(&model.AsrResult{Text: "我有点难过。", Final: true})
+
+	got := r.types()
+	want := []string{EventSpeechStart, EventPartial, EventSpeechEnd, EventFinal}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] || r.events[i].UtteranceId != 1 {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+	if r.events[3].Text != "我有点难过" {
+		t.Errorf("final text = %q", r.events[3].Text)
+	}
+}
+
+func TestEndpointer_Opus(t *testing.T) {
+	r := &recorder{}
+	p := NewEndpointer(&config.Vad{Silence: 200}, r.emit)
+	defer p.Close()
+
+	// 浏览器录制的WebM/Opus不解码, 按SILK的语音活动标记断句
+	tc, err := NewTranscoder(&dto.AudioFormat{Format: "webm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x80, 0x16, 0x54, 0xAE, 0x6B, 0xFF, 0xAE, 0xFF, 0x86, 0x86}
+	stream := append(head, "A_OPUS"...)
+	for i := 0; i < 30; i++ {
+		flag := byte(0x80)
+		if i >= 15 {
+			flag = 0
+		}
+		stream = append(stream, 0xA3, 0x88, 0x81, 0, 0, 0x80, 0x09<<3, flag, 0, 0)
+	}
+	out, err := tc.Write(stream)
+	if err != nil || len(out) == 0 {
+		t.Fatalf("write: %v", err)
+	}
+	p.Detect(tc, out)
+
+	if got := r.types(); len(got) != 2 || got[0] != EventSpeechStart || got[1] != EventSpeechEnd {
+		t.Errorf("events = %v", got)
+	}
+}
+
+func TestAsrError(t *testing.T) {
+	if got := AsrError(fmt.Errorf("%w: whisper asr needs 16k pcm", audio.ErrUnsupportedFormat)); got != consts.ErrAsrFormat {
+		t.Errorf("unsupported format: %v", got)
+	}
+	if got := AsrError(errors.New("unknown asr provider")); got != consts.ErrAudioFormat {
+		t.Errorf("other error: %v", got)
+	}
+}
@@ -1,15 +1,21 @@
 package voice
 
 import (
+	"encoding/json"
 	"github.com/hertz-contrib/websocket"
 	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-digital/biz/application/dto"
 	"github.com/xh-polaris/psych-digital/biz/domain"
 	"github.com/xh-polaris/psych-digital/biz/domain/model"
-	"github.com/xh-polaris/psych-digital/biz/domain/model/volc"
+	_ "github.com/xh-polaris/psych-digital/biz/domain/model/fake"
+	_ "github.com/xh-polaris/psych-digital/biz/domain/model/openai"
+	_ "github.com/xh-polaris/psych-digital/biz/domain/model/volc"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/audio"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
 	"golang.org/x/net/context"
 	"io"
+	"sync"
 	"time"
 )
 
@@ -21,54 +27,130 @@ type Engine struct {
 	// ws 管理ws连接
 	ws *domain.WsHelper
 
-	// asrApp 语音识别app
+	// config 全局配置
+	config *config.Config
+
+	// asrApp 语音识别app, 确定音频格式后创建
 	asrApp model.AsrApp
 
-	// finish 结束
+	// transcoder 将前端的音频转换为语音识别支持的格式
+	transcoder audio.Transcoder
+
+	// endpointer 判断学生什么时候说完一句话
+	endpointer *Endpointer
+
+	// finish 结束, 识别、读取和超时检查协程都可能发送, 留足缓冲避免阻塞
 	finish chan struct{}
+
+	// watchdog 心跳、空闲和连接时长的超时检查
+	watchdog *domain.Watchdog
+
+	// recognising 识别协程, 关闭连接前需要等待它结束, 避免向已释放的连接写入
+	recognising sync.WaitGroup
+
+	// listening 读取协程, 超时结束时可能仍阻塞在读取中, 需要中断读取并等待它结束
+	listening sync.WaitGroup
 }
 
 // NewEngine 初始化
 func NewEngine(ctx context.Context, conn *websocket.Conn) *Engine {
+	return newEngine(conn, config.GetConfig())
+}
+
+// newEngine 使用指定的配置初始化
+func newEngine(conn *websocket.Conn, c *config.Config) *Engine {
 	ctx, cancel := context.WithCancel(context.Background())
-	c := config.GetConfig()
 	e := &Engine{
-		ctx:    ctx,
-		cancel: cancel,
-		ws:     domain.NewWsHelper(conn),
-		asrApp: volc.NewVcAsrApp(c.VolcAsr.AppKey, c.VolcAsr.AccessKey, c.VolcAsr.ResourceId, c.VolcAsr.Url),
-		finish: make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		ws:       domain.NewWsHelper(conn),
+		config:   c,
+		finish:   make(chan struct{}, 3),
+		watchdog: domain.NewWatchdog(&c.Timeout),
 	}
+	e.endpointer = NewEndpointer(&c.Vad, e.write)
 	return e
 }
 
-// Start 初始化
+// Start 确定音频格式并初始化语音识别
+// 第一条消息为文本时解析为dto.AudioFormat, 为二进制时使用默认格式, 并作为第一段音频发送
 func (e *Engine) Start() error {
-	if err := e.asrApp.Dial(); err != nil {
+	mt, data, err := e.ws.Read()
+	if err != nil {
 		return err
 	}
-	if err := e.asrApp.Start(); err != nil {
+	var format *dto.AudioFormat
+	var first []byte
+	if mt == websocket.TextMessage {
+		format = &dto.AudioFormat{}
+		err = json.Unmarshal(data, format)
+	} else {
+		first = data
+	}
+	if err == nil {
+		e.transcoder, err = NewTranscoder(format)
+	}
+	if err != nil {
+		log.Error("音频格式错误", err)
+		_ = e.ws.Error(consts.ErrAudioFormat)
 		return err
 	}
-	return nil
+
+	if e.asrApp, err = model.NewAsrApp(e.config, &model.AsrOptions{
+		Format:    e.transcoder.Output(),
+		EndWindow: e.config.Vad.Silence,
+	}); err != nil {
+		_ = e.ws.Error(AsrError(err))
+		return err
+	}
+	if err = e.asrApp.Dial(); err != nil {
+		return err
+	}
+	if err = e.asrApp.Start(); err != nil {
+		return err
+	}
+	return e.audio(first)
 }
 
 // Listen 主事件循环, 获取前端的音频流输入, 返回文字
 func (e *Engine) Listen() {
+	e.listening.Add(1)
 	go e.listen()
+	e.recognising.Add(1)
 	go e.recognise()
+	go e.watch()
 	<-e.finish
 }
 
+// watch 检查连接超时, 超时后通知前端并结束识别, 避免废弃的连接一直占用语音识别
+func (e *Engine) watch() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if reason := e.watchdog.Expired(now); reason != "" {
+				log.Info("asr timeout: %s", reason)
+				_ = e.ws.Error(consts.ErrTimeout)
+				e.finish <- struct{}{}
+				return
+			}
+		}
+	}
+}
+
 // recognise 识别音频并写入输入
 func (e *Engine) recognise() {
+	defer e.recognising.Done()
 	for {
 		select {
 		case <-e.ctx.Done():
 			return
 		default:
 			// 获取响应并写入ws
-			text, err := e.asrApp.Receive()
+			res, err := e.asrApp.Receive()
 			if err == io.EOF {
 				return
 			} else if err != nil {
@@ -76,24 +158,22 @@ func (e *Engine) recognise() {
 				e.finish <- struct{}{}
 				return
 			}
-			if text == "" {
-				continue
-			}
-			resp := &dto.AsrResp{
-				Text:      text,
-				Timestamp: time.Now().Unix(),
-			}
-			if err = e.ws.WriteJSON(resp); err != nil {
-				log.Error("写入响应失败", err)
-				e.finish <- struct{}{}
-				return
-			}
+			e.endpointer.Result(res)
 		}
 	}
 }
 
+// write 写入识别和断句事件
+func (e *Engine) write(resp *dto.AsrResp) {
+	e.watchdog.Active()
+	if err := e.ws.WriteJSON(resp); err != nil {
+		log.Error("写入响应失败", err)
+	}
+}
+
 // listen 获取音频输入并发送给asr #生产者
 func (e *Engine) listen() {
+	defer e.listening.Done()
 	for {
 		select {
 		case <-e.ctx.Done():
@@ -105,10 +185,14 @@ func (e *Engine) listen() {
 			} else if err != nil {
 				log.Error("listen:receive user:err ", err)
 				e.finish <- struct{}{}
-			} else if data == nil || len(data) == 0 {
+				return
+			}
+			// 收到任何消息都说明连接正常, 文本消息只作为心跳
+			e.watchdog.Seen()
+			if len(data) == 0 {
 				continue
 			}
-			if err = e.asrApp.Send(data); err != nil {
+			if err = e.audio(data); err != nil {
 				log.Error("listen:send asr:err ", err)
 				e.finish <- struct{}{}
 				return
@@ -117,8 +201,27 @@ func (e *Engine) listen() {
 	}
 }
 
+// audio 转换音频格式后发送给asr, 同时检测说话的开始和结束
+func (e *Engine) audio(data []byte) error {
+	out, err := e.transcoder.Write(data)
+	if err != nil || len(out) == 0 {
+		return err
+	}
+	e.endpointer.Detect(e.transcoder, out)
+	return e.asrApp.Send(out)
+}
+
 // Close 释放资源
 func (e *Engine) Close() error {
 	e.cancel()
+	e.endpointer.Close()
+	if e.asrApp != nil {
+		if err := e.asrApp.Close(); err != nil {
+			log.Error("close asr err:", err)
+		}
+	}
+	e.recognising.Wait()
+	_ = e.ws.Abort()
+	e.listening.Wait()
 	return e.ws.Close()
 }
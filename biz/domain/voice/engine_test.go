@@ -0,0 +1,145 @@
+package voice
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/gorilla/websocket"
+	hws "github.com/hertz-contrib/websocket"
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// newTestServer 启动一个使用回放语音识别的/voice/asr服务, 返回ws地址
+func newTestServer(t *testing.T, transcript string, timeout config.Timeout) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "transcript.txt")
+	if err := os.WriteFile(path, []byte(transcript), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := &config.Config{
+		Asr:     config.Asr{Provider: consts.ProviderFake},
+		FakeAsr: config.FakeAsr{Transcript: path, Every: 100},
+		Timeout: timeout,
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	h := server.New(server.WithHostPorts(addr), server.WithExitWaitTime(0))
+	upgrader := hws.HertzUpgrader{CheckOrigin: func(*app.RequestContext) bool { return true }}
+	h.GET("/voice/asr", func(ctx context.Context, rc *app.RequestContext) {
+		_ = upgrader.Upgrade(rc, func(conn *hws.Conn) {
+			e := newEngine(conn, c)
+			defer func() { _ = e.Close() }()
+			if err := e.Start(); err != nil {
+				return
+			}
+			e.Listen()
+		})
+	})
+	go func() { _ = h.Run() }()
+	t.Cleanup(func() { _ = h.Shutdown(context.Background()) })
+
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			_ = conn.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return "ws://" + addr + "/voice/asr"
+}
+
+func TestEngine_FakeAsr(t *testing.T) {
+	url := newTestServer(t, "~你好\n你好呀\n", config.Timeout{})
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// 48000采样频率的双声道静音, 转换后每100毫秒返回一条结果
+	if err = conn.WriteJSON(&dto.AudioFormat{Format: "pcm", SampleRate: 48000, Channels: 2}); err != nil {
+		t.Fatal(err)
+	}
+	chunk := make([]byte, 48*20*4)
+	for i := 0; i < 10; i++ {
+		if err = conn.WriteMessage(websocket.BinaryMessage, chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var got []*dto.AsrResp
+	for len(got) < 2 {
+		var resp dto.AsrResp
+		if err = conn.ReadJSON(&resp); err != nil {
+			t.Fatalf("read: %v, got %+v", err, got)
+		}
+		got = append(got, &resp)
+	}
+	if got[0].Type != EventPartial || got[0].Text != "你好" || got[1].Type != EventFinal || got[1].Text != "你好呀" {
+		t.Errorf("unexpected events: %+v, %+v", got[0], got[1])
+	}
+	if got[0].UtteranceId != got[1].UtteranceId {
+		t.Errorf("partial and final should share utterance id: %d, %d", got[0].UtteranceId, got[1].UtteranceId)
+	}
+}
+
+func TestEngine_UnsupportedFormat(t *testing.T) {
+	url := newTestServer(t, "你好\n", config.Timeout{})
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err = conn.WriteJSON(&dto.AudioFormat{Format: "mp3"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var resp dto.Response
+	if err = conn.ReadJSON(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != consts.ErrAudioFormat.Code() {
+		t.Errorf("got code %d, want %d", resp.Code, consts.ErrAudioFormat.Code())
+	}
+}
+
+func TestEngine_HeartbeatTimeout(t *testing.T) {
+	url := newTestServer(t, "你好\n", config.Timeout{Heartbeat: 1})
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err = conn.WriteJSON(&dto.AudioFormat{Format: "pcm"}); err != nil {
+		t.Fatal(err)
+	}
+	// 不再发送任何消息, 心跳超时后收到超时错误并断开
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var resp dto.Response
+	if err = conn.ReadJSON(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != consts.ErrTimeout.Code() {
+		t.Errorf("got code %d, want %d", resp.Code, consts.ErrTimeout.Code())
+	}
+	if _, _, err = conn.ReadMessage(); err == nil {
+		t.Error("expect connection closed")
+	}
+}
@@ -0,0 +1,32 @@
+package voice
+
+import (
+	"errors"
+
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/audio"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// NewTranscoder 根据前端声明的音频格式创建转换器, 未声明时为16000采样频率的16位单声道PCM
+// opus不解码, 本地的语音活动检测使用转换时取出的数据包, 见Endpointer.Detect
+func NewTranscoder(f *dto.AudioFormat) (audio.Transcoder, error) {
+	if f == nil {
+		return audio.NewTranscoder(audio.Target())
+	}
+	return audio.NewTranscoder(audio.Format{
+		Container:  f.Format,
+		SampleRate: f.SampleRate,
+		Channels:   f.Channels,
+		Bits:       f.Bits,
+		Float:      f.Float,
+	})
+}
+
+// AsrError 创建语音识别失败时返回给前端的错误, 识别服务不支持转换后的格式(如whisper不支持opus)时提示改用pcm或wav
+func AsrError(err error) *consts.Errno {
+	if errors.Is(err, audio.ErrUnsupportedFormat) {
+		return consts.ErrAsrFormat
+	}
+	return consts.ErrAudioFormat
+}
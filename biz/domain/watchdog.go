@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+// 超时原因
+const (
+	// TimeoutHeartbeat 长时间没有收到任何消息, 连接可能已经断开
+	TimeoutHeartbeat = "heartbeat"
+	// TimeoutIdle 学生长时间没有输入且AI没有输出
+	TimeoutIdle = "idle"
+	// TimeoutMaxDuration 对话时长超过上限
+	TimeoutMaxDuration = "max_duration"
+)
+
+// 超时的默认值, 单位秒, 为0时不限制
+const (
+	defaultIdle = 300
+	// defaultHeartbeat 旧版前端不发送心跳, 默认不检查, 避免安静思考的学生被断开
+	defaultHeartbeat   = 0
+	defaultMaxDuration = 3600
+)
+
+// Watchdog 记录连接的活动时间, 判断心跳、空闲和对话时长是否超时
+type Watchdog struct {
+	heartbeat time.Duration
+	idle      time.Duration
+	max       time.Duration
+	start     time.Time
+	// seen 最近收到消息的时间
+	seen atomic.Int64
+	// active 最近有输入或输出的时间
+	active atomic.Int64
+}
+
+// NewWatchdog 从现在开始计时
+func NewWatchdog(c *config.Timeout) *Watchdog {
+	w := &Watchdog{
+		heartbeat: timeout(c.Heartbeat, defaultHeartbeat),
+		idle:      timeout(c.Idle, defaultIdle),
+		max:       timeout(c.MaxDuration, defaultMaxDuration),
+		start:     time.Now(),
+	}
+	w.Reset()
+	return w
+}
+
+// timeout 将配置的秒数转换为时长, 为0时使用默认值, 为负数时不限制
+func timeout(seconds, def int) time.Duration {
+	if seconds == 0 {
+		seconds = def
+	}
+	if seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Seen 收到了一条消息, 包括心跳
+func (w *Watchdog) Seen() {
+	w.seen.Store(time.Now().UnixNano())
+}
+
+// Active 学生有输入或AI有输出
+func (w *Watchdog) Active() {
+	w.active.Store(time.Now().UnixNano())
+}
+
+// Reset 重新连接后从现在开始计算心跳和空闲
+func (w *Watchdog) Reset() {
+	now := time.Now().UnixNano()
+	w.seen.Store(now)
+	w.active.Store(now)
+}
+
+// Expired 返回now时超时的原因, 依次判断对话时长、心跳和空闲, 没有超时时返回空
+func (w *Watchdog) Expired(now time.Time) string {
+	switch {
+	case w.max > 0 && now.Sub(w.start) >= w.max:
+		return TimeoutMaxDuration
+	case w.heartbeat > 0 && now.Sub(time.Unix(0, w.seen.Load())) >= w.heartbeat:
+		return TimeoutHeartbeat
+	case w.idle > 0 && now.Sub(time.Unix(0, w.active.Load())) >= w.idle:
+		return TimeoutIdle
+	}
+	return ""
+}
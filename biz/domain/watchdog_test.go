@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+func TestWatchdog(t *testing.T) {
+	w := NewWatchdog(&config.Timeout{Heartbeat: 10, Idle: 20, MaxDuration: 30})
+	now := time.Now()
+	if r := w.Expired(now.Add(5 * time.Second)); r != "" {
+		t.Errorf("5s: %q", r)
+	}
+	if r := w.Expired(now.Add(11 * time.Second)); r != TimeoutHeartbeat {
+		t.Errorf("11s: %q", r)
+	}
+	// 心跳正常但没有输入
+	w.seen.Store(now.Add(20 * time.Second).UnixNano())
+	if r := w.Expired(now.Add(21 * time.Second)); r != TimeoutIdle {
+		t.Errorf("21s: %q", r)
+	}
+	// 对话时长优先
+	w.seen.Store(now.Add(30 * time.Second).UnixNano())
+	w.active.Store(now.Add(30 * time.Second).UnixNano())
+	if r := w.Expired(now.Add(31 * time.Second)); r != TimeoutMaxDuration {
+		t.Errorf("31s: %q", r)
+	}
+
+	// 负数不限制, 0使用默认值
+	w = NewWatchdog(&config.Timeout{Heartbeat: -1, Idle: -1})
+	if r := w.Expired(now.Add(time.Hour - time.Minute)); r != "" {
+		t.Errorf("unlimited: %q", r)
+	}
+	if r := w.Expired(now.Add(time.Hour + time.Second)); r != TimeoutMaxDuration {
+		t.Errorf("default max duration: %q", r)
+	}
+
+	// 默认不检查心跳, 不发送心跳的前端只受空闲超时限制
+	w = NewWatchdog(&config.Timeout{})
+	if r := w.Expired(now.Add(299 * time.Second)); r != "" {
+		t.Errorf("default heartbeat: %q", r)
+	}
+	if r := w.Expired(now.Add(301 * time.Second)); r != TimeoutIdle {
+		t.Errorf("default idle: %q", r)
+	}
+}
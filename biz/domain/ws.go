@@ -1,17 +1,28 @@
 package domain
 
 import (
+	"encoding/json"
+	"sync"
+	"time"
+
 	"github.com/hertz-contrib/websocket"
+	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-digital/biz/application/dto"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
-	"sync"
 )
 
 // WsHelper 是封装Websocket协议的工具类
 // 最佳实践是单协程读, 所以不需要使用读锁, 但是涉及到文字和音频的混合传输, 所以可能需要一个协程读, 另外两个协程分别处理文本和音频
+// 开启消息记录后, 连接断开时写入只记录不发送, 重新连接后重放前端没有收到的消息
 type WsHelper struct {
 	mu   sync.Mutex
 	conn *websocket.Conn
+	// journal 发送给前端的消息记录, 未开启时为nil
+	journal *journal
+	// offline 连接已断开, 消息只记录不发送
+	offline bool
+	// version 对话协议版本, 决定文本消息和音频帧的编码
+	version int
 }
 
 func NewWsHelper(conn *websocket.Conn) *WsHelper {
@@ -47,7 +58,80 @@ func (ws *WsHelper) Error(errno *consts.Errno) error {
 		Code: errno.Code(),
 		Msg:  errno.Error(),
 	}
-	return ws.WriteJSON(resp)
+	return ws.Send(consts.MsgError, resp)
+}
+
+// Protocol 设置对话协议版本, 需要在发送开始响应之前调用
+func (ws *WsHelper) Protocol(version int) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.version = version
+}
+
+// Send 写入一条指定类型的消息, v1协议包装为带序号的信封, 旧协议直接写入json
+func (ws *WsHelper) Send(typ string, obj any) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	data, err := ws.encode(typ, ws.next(), obj)
+	if err != nil {
+		return err
+	}
+	return ws.write(websocket.TextMessage, data)
+}
+
+// Pong 回复心跳, 旧协议为空的二进制消息
+func (ws *WsHelper) Pong() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.version == consts.ProtocolLegacy {
+		return ws.write(websocket.BinaryMessage, []byte{})
+	}
+	data, err := ws.encode(consts.MsgPong, ws.next(), nil)
+	if err != nil {
+		return err
+	}
+	return ws.write(websocket.TextMessage, data)
+}
+
+// WriteAudio 写入一段合成语音, v1协议在音频前加上所属句子和序号
+func (ws *WsHelper) WriteAudio(segment uint64, audio []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.version != consts.ProtocolLegacy {
+		h := &dto.AudioHeader{
+			Version:   uint8(ws.version),
+			Kind:      consts.AudioTts,
+			SegmentId: uint32(segment),
+			Seq:       ws.next(),
+		}
+		audio = h.Append(audio)
+	}
+	return ws.write(websocket.BinaryMessage, audio)
+}
+
+// next 下一条消息的序号, 未开启消息记录时为0, 调用方需持有锁
+func (ws *WsHelper) next() uint64 {
+	if ws.journal == nil {
+		return 0
+	}
+	return ws.journal.seq + 1
+}
+
+// encode 按协议版本编码一条文本消息, 调用方需持有锁
+func (ws *WsHelper) encode(typ string, seq uint64, obj any) ([]byte, error) {
+	if ws.version == consts.ProtocolLegacy {
+		return json.Marshal(obj)
+	}
+	return dto.MarshalEnvelope(ws.version, typ, seq, obj)
+}
+
+// write 写入一条消息, 开启消息记录时先记录, 调用方需持有锁
+func (ws *WsHelper) write(mt int, data []byte) error {
+	if ws.journal == nil {
+		return ws.conn.WriteMessage(mt, data)
+	}
+	ws.record(mt, data)
+	return nil
 }
 
 // WriteJSON 写入一个Json对象
@@ -55,17 +139,100 @@ func (ws *WsHelper) WriteJSON(obj any) error {
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
 
-	return ws.conn.WriteJSON(obj)
+	if ws.journal == nil {
+		return ws.conn.WriteJSON(obj)
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	ws.record(websocket.TextMessage, data)
+	return nil
 }
 
 // WriteBytes 写入字节流
 func (ws *WsHelper) WriteBytes(bytes []byte) error {
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
-	return ws.conn.WriteMessage(websocket.BinaryMessage, bytes)
+	return ws.write(websocket.BinaryMessage, bytes)
+}
+
+// record 记录并发送一条消息, 发送失败时视为连接断开, 之后的消息等待重连后重放, 调用方需持有锁
+func (ws *WsHelper) record(mt int, data []byte) {
+	ws.journal.add(mt, data)
+	if ws.offline {
+		return
+	}
+	if err := ws.conn.WriteMessage(mt, data); err != nil {
+		log.Error("ws write err, wait for resume:", err)
+		ws.offline = true
+	}
+}
+
+// Journal 开启消息记录, 之后发送的消息依次编号, 超过maxBytes时丢弃最早的消息
+func (ws *WsHelper) Journal(maxBytes int) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.journal = newJournal(maxBytes)
+}
+
+// Detach 连接断开后关闭连接, 之后的消息只记录不发送
+func (ws *WsHelper) Detach() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conn != nil {
+		_ = ws.conn.Close()
+		ws.conn = nil
+	}
+	ws.offline = true
+}
+
+// Attach 使用新的连接继续发送, 先发送ack, 再重放序号在received之后的消息
+// ack中记录重放的消息数和已被丢弃无法重放的消息数, 协议版本沿用开始时的版本
+func (ws *WsHelper) Attach(conn *websocket.Conn, received uint64, ack *dto.ChatStartResp) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	msgs, missed := ws.journal.since(received)
+	ack.Replay, ack.Missed, ack.Version = len(msgs), missed, ws.version
+	data, err := ws.encode(consts.MsgStart, 0, ack)
+	if err != nil {
+		return err
+	}
+	if err = conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		if err := conn.WriteMessage(m.mt, m.data); err != nil {
+			return err
+		}
+	}
+	ws.conn, ws.offline = conn, false
+	return nil
+}
+
+// Abort 让阻塞中的读取尽快返回错误
+// hertz在处理函数返回后才真正关闭劫持的连接, 超时结束时需要先让读取协程退出
+// netpoll不支持读取超时, 此时发送关闭帧, 对方回复关闭或连接断开后读取返回
+func (ws *WsHelper) Abort() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conn == nil {
+		return nil
+	}
+	if err := ws.conn.SetReadDeadline(time.Now()); err == nil {
+		return nil
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, consts.FinishTimeout)
+	return ws.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
 }
 
 // Close 关闭连接
 func (ws *WsHelper) Close() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conn == nil {
+		return nil
+	}
 	return ws.conn.Close()
 }
@@ -0,0 +1,124 @@
+package alert
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/util"
+)
+
+// 渠道类型
+const (
+	TypeSMTP     = "smtp"
+	TypeWebhook  = "webhook"
+	TypeDingTalk = "dingtalk"
+	TypeWeCom    = "wecom"
+)
+
+// Channel 预警投递渠道的抽象
+type Channel interface {
+	// Send 投递一条预警, 返回错误时会被重试
+	Send(ctx context.Context, notice *Notice) error
+}
+
+// NewChannel 根据配置创建渠道
+func NewChannel(c *config.AlertChannel) (Channel, error) {
+	switch c.Type {
+	case TypeSMTP:
+		return &smtpChannel{to: c.To}, nil
+	case TypeWebhook:
+		return &webhookChannel{url: c.Url}, nil
+	case TypeDingTalk:
+		return &dingTalkChannel{url: c.Url, secret: c.Secret}, nil
+	case TypeWeCom:
+		return &weComChannel{url: c.Url}, nil
+	default:
+		return nil, fmt.Errorf("unknown alert channel: %s", c.Type)
+	}
+}
+
+// smtpChannel 发送HTML邮件
+type smtpChannel struct {
+	to []string
+}
+
+func (ch *smtpChannel) Send(ctx context.Context, notice *Notice) error {
+	return util.SendMail(ctx, ch.to, notice.Subject, "text/html", notice.Html)
+}
+
+// webhookChannel 以JSON形式推送预警原始内容, 便于对接其他系统
+// 接收方返回任意2xx即视为成功, 不要求响应体
+type webhookChannel struct {
+	url string
+}
+
+func (ch *webhookChannel) Send(ctx context.Context, notice *Notice) error {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return util.GetHttpClient().SendCtx(ctx, consts.Post, ch.url, header, map[string]any{
+		"subject": notice.Subject,
+		"text":    notice.Text,
+		"alert":   notice.Message,
+	})
+}
+
+// dingTalkChannel 钉钉群机器人, 配置了secret时使用加签校验
+type dingTalkChannel struct {
+	url    string
+	secret string
+}
+
+func (ch *dingTalkChannel) Send(ctx context.Context, notice *Notice) error {
+	target := ch.url
+	if ch.secret != "" {
+		ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(ch.secret))
+		mac.Write([]byte(ts + "\n" + ch.secret))
+		sign := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		target = fmt.Sprintf("%s&timestamp=%s&sign=%s", ch.url, ts, sign)
+	}
+	return robot(ctx, target, map[string]any{
+		"msgtype": "markdown",
+		"markdown": map[string]any{
+			"title": notice.Subject,
+			"text":  notice.Text,
+		},
+	})
+}
+
+// weComChannel 企业微信群机器人
+type weComChannel struct {
+	url string
+}
+
+func (ch *weComChannel) Send(ctx context.Context, notice *Notice) error {
+	return robot(ctx, ch.url, map[string]any{
+		"msgtype": "markdown",
+		"markdown": map[string]any{
+			"content": notice.Text,
+		},
+	})
+}
+
+// robot 调用机器人webhook, 钉钉和企业微信都以errcode非0表示失败
+func robot(ctx context.Context, target string, body map[string]any) error {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	res, err := util.GetHttpClient().ReqCtx(ctx, consts.Post, target, header, body)
+	if err != nil {
+		return err
+	}
+	if code, ok := res["errcode"].(float64); ok && code != 0 {
+		return fmt.Errorf("robot errcode: %v, errmsg: %v", code, res["errmsg"])
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package alert
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"text/template"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+// Message 一条风险预警, 包含学生信息和对话节选
+type Message struct {
+	SessionId string    `json:"sessionId"`
+	UnitId    string    `json:"unitId"`
+	StudentId string    `json:"studentId"`
+	Name      string    `json:"name"`
+	Class     string    `json:"class"`
+	Level     string    `json:"level"`
+	Detector  string    `json:"detector"`
+	Evidence  []string  `json:"evidence"`
+	Excerpt   []*Line   `json:"excerpt"`
+	Time      time.Time `json:"time"`
+}
+
+// Line 对话节选中的一句
+type Line struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Notice 渲染后的预警内容, 供各渠道使用
+type Notice struct {
+	*Message
+	Subject string
+	// Text 纯文本/markdown内容, 用于机器人
+	Text string
+	// Html 用于邮件
+	Html string
+}
+
+const defaultSubject = `心理预警: {{.Class}} {{.Name}} ({{level .Level}})`
+
+const defaultText = `### 心理预警: {{level .Level}}
+- 学生: {{.Name}} ({{.StudentId}})
+- 班级: {{.Class}}
+- 时间: {{time .Time}}
+- 依据: {{range $i, $e := .Evidence}}{{if $i}}; {{end}}{{$e}}{{end}}
+
+**对话节选**
+{{range .Excerpt}}
+> {{role .Role}}: {{.Content}}
+{{end}}
+请尽快联系该学生并跟进处理。`
+
+const defaultHtml = `<h3>心理预警: {{level .Level}}</h3>
+<table>
+<tr><td>学生</td><td>{{.Name}} ({{.StudentId}})</td></tr>
+<tr><td>班级</td><td>{{.Class}}</td></tr>
+<tr><td>时间</td><td>{{time .Time}}</td></tr>
+<tr><td>依据</td><td>{{range $i, $e := .Evidence}}{{if $i}}; {{end}}{{$e}}{{end}}</td></tr>
+</table>
+<h4>对话节选</h4>
+<ul>{{range .Excerpt}}<li><b>{{role .Role}}</b>: {{.Content}}</li>{{end}}</ul>
+<p>请尽快联系该学生并跟进处理。</p>`
+
+var (
+	levelName = map[string]string{"low": "低风险", "medium": "中风险", "high": "高风险"}
+	roleName  = map[string]string{"ai": "AI", "user": "学生", "system": "开场"}
+	funcs     = map[string]any{
+		"level": func(l string) string { return lookup(levelName, l) },
+		"role":  func(r string) string { return lookup(roleName, r) },
+		"time":  func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	}
+)
+
+func lookup(m map[string]string, k string) string {
+	if v, ok := m[k]; ok {
+		return v
+	}
+	return k
+}
+
+// renderer 渲染预警模板
+type renderer struct {
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+// newRenderer 解析模板, 未配置的使用默认模板
+func newRenderer(c *config.Alert) (*renderer, error) {
+	subject, text, html := defaultSubject, defaultText, defaultHtml
+	if c.Subject != "" {
+		subject = c.Subject
+	}
+	if c.Text != "" {
+		text = c.Text
+	}
+	if c.Html != "" {
+		html = c.Html
+	}
+
+	var r renderer
+	var err error
+	if r.subject, err = template.New("subject").Funcs(funcs).Parse(subject); err != nil {
+		return nil, err
+	}
+	if r.text, err = template.New("text").Funcs(funcs).Parse(text); err != nil {
+		return nil, err
+	}
+	if r.html, err = htmltemplate.New("html").Funcs(funcs).Parse(html); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// render 渲染一条预警
+func (r *renderer) render(msg *Message) (*Notice, error) {
+	var subject, text, html bytes.Buffer
+	if err := r.subject.Execute(&subject, msg); err != nil {
+		return nil, err
+	}
+	if err := r.text.Execute(&text, msg); err != nil {
+		return nil, err
+	}
+	if err := r.html.Execute(&html, msg); err != nil {
+		return nil, err
+	}
+	return &Notice{
+		Message: msg,
+		Subject: subject.String(),
+		Text:    text.String(),
+		Html:    html.String(),
+	}, nil
+}
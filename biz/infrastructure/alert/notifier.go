@@ -0,0 +1,259 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	rs "github.com/xh-polaris/psych-digital/biz/infrastructure/redis"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+const (
+	// retryKey 待重试投递的有序集合, score为下次投递时间
+	retryKey = "alert:retry"
+	// messageKey 待重试预警的内容, 按预警id存储, 多个渠道的投递共用
+	messageKey = "alert:message:"
+	// retryTTL 重试相关的key在redis中保留的时长, 超过后不再重试
+	retryTTL = 24 * time.Hour
+	// defaultMaxRetry 默认最大重试次数
+	defaultMaxRetry = 5
+	// retryBase 首次重试的等待时间, 之后每次翻倍
+	retryBase = 30 * time.Second
+	// retryMax 重试等待时间的上限
+	retryMax = 30 * time.Minute
+	// pollInterval 扫描重试队列的间隔
+	pollInterval = 10 * time.Second
+	// sendTimeout 单次投递的最长时间
+	sendTimeout = 10 * time.Second
+)
+
+// delivery 一次渠道投递, 失败时只把预警id和渠道序号存入redis等待重试
+// 重试时按当前配置重新路由, 渠道的密钥不写入redis
+type delivery struct {
+	// Id 预警id, 预警内容单独存储在messageKey下
+	Id string `json:"id"`
+	// Index 渠道在单位路由结果中的序号
+	Index    int `json:"index"`
+	Attempts int `json:"attempts"`
+
+	channel config.AlertChannel
+	message *Message
+}
+
+// Notifier 将预警按单位路由到对应的渠道, 投递失败时持久化并重试
+type Notifier struct {
+	routes   []config.AlertRoute
+	fallback []config.AlertChannel
+	maxRetry int
+	renderer *renderer
+	// rs 为nil时不持久化, 失败的投递直接丢弃
+	rs *redis.Redis
+}
+
+// NewNotifier 根据配置创建预警通知器
+func NewNotifier(c *config.Alert, r *redis.Redis) (*Notifier, error) {
+	rd, err := newRenderer(c)
+	if err != nil {
+		return nil, err
+	}
+	n := &Notifier{
+		routes:   c.Routes,
+		maxRetry: c.MaxRetry,
+		renderer: rd,
+		rs:       r,
+	}
+	if n.maxRetry <= 0 {
+		n.maxRetry = defaultMaxRetry
+	}
+	if len(c.Fallback) > 0 {
+		n.fallback = []config.AlertChannel{{Type: TypeSMTP, To: c.Fallback}}
+	}
+	return n, nil
+}
+
+var (
+	instance *Notifier
+	once     sync.Once
+)
+
+// GetNotifier 获取预警通知器单例, 并启动重试协程
+func GetNotifier() *Notifier {
+	once.Do(func() {
+		c := config.GetConfig()
+		ac := c.Alert
+		// 兼容只配置了SMTP.Alert的旧部署
+		if len(ac.Fallback) == 0 && c.SMTP.Alert != "" {
+			ac.Fallback = []string{c.SMTP.Alert}
+		}
+		n, err := NewNotifier(&ac, rs.NewRedis(c))
+		if err != nil {
+			log.Error("预警模板解析失败, 使用默认模板:", err)
+			ac.Subject, ac.Text, ac.Html = "", "", ""
+			n, _ = NewNotifier(&ac, rs.NewRedis(c))
+		}
+		go n.Run(context.Background())
+		instance = n
+	})
+	return instance
+}
+
+// Notify 投递一条预警到单位对应的所有渠道
+func (n *Notifier) Notify(ctx context.Context, msg *Message) {
+	chs := n.route(msg.UnitId)
+	if len(chs) == 0 {
+		log.Error("预警没有可用的投递渠道, 请配置Alert.Routes或Alert.Fallback, unit: %s, session: %s", msg.UnitId, msg.SessionId)
+		return
+	}
+	id := uuid.New().String()
+	for i, ch := range chs {
+		n.deliver(ctx, &delivery{Id: id, Index: i, channel: ch, message: msg})
+	}
+}
+
+// route 选择单位对应的渠道, 没有精确匹配时使用默认路由, 都没有时发送到兜底邮箱
+func (n *Notifier) route(unitId string) []config.AlertChannel {
+	var defaults []config.AlertChannel
+	for _, r := range n.routes {
+		if r.UnitId == unitId && unitId != "" {
+			return r.Channels
+		}
+		if r.UnitId == "" {
+			defaults = append(defaults, r.Channels...)
+		}
+	}
+	if len(defaults) > 0 {
+		return defaults
+	}
+	return n.fallback
+}
+
+// deliver 执行一次投递, 失败时加入重试队列
+func (n *Notifier) deliver(ctx context.Context, d *delivery) {
+	err := n.send(ctx, d)
+	if err == nil {
+		return
+	}
+	d.Attempts++
+	if d.Attempts > n.maxRetry {
+		log.Error("预警投递失败, 已达最大重试次数, channel: %s, session: %s, err: %v", d.channel.Type, d.message.SessionId, err)
+		return
+	}
+	log.Error("预警投递失败, 稍后重试, channel: %s, session: %s, attempts: %d, err: %v", d.channel.Type, d.message.SessionId, d.Attempts, err)
+	n.schedule(d)
+}
+
+// send 渲染并通过渠道发送
+func (n *Notifier) send(ctx context.Context, d *delivery) error {
+	ch, err := NewChannel(&d.channel)
+	if err != nil {
+		return err
+	}
+	notice, err := n.renderer.render(d.message)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return ch.Send(ctx, notice)
+}
+
+// schedule 将投递存入redis, 按指数退避计算下次投递时间
+// 预警内容和重试队列都设置过期时间, 长期未处理的预警不会一直留在redis中
+func (n *Notifier) schedule(d *delivery) {
+	if n.rs == nil {
+		return
+	}
+	msg, err := json.Marshal(d.message)
+	if err != nil {
+		log.Error("预警序列化失败:", err)
+		return
+	}
+	ttl := int(retryTTL / time.Second)
+	if err = n.rs.Setex(messageKey+d.Id, string(msg), ttl); err != nil {
+		log.Error("预警存入重试队列失败:", err)
+		return
+	}
+	data, _ := json.Marshal(d)
+	if _, err = n.rs.Zadd(retryKey, time.Now().Add(backoff(d.Attempts)).Unix(), string(data)); err != nil {
+		log.Error("预警存入重试队列失败:", err)
+		return
+	}
+	if err = n.rs.Expire(retryKey, ttl); err != nil {
+		log.Error("设置预警重试队列过期时间失败:", err)
+	}
+}
+
+// restore 读取重试投递的预警内容并按当前配置重新路由, 预警已过期或渠道已移除时返回false
+func (n *Notifier) restore(d *delivery) bool {
+	data, err := n.rs.Get(messageKey + d.Id)
+	if err != nil || data == "" {
+		log.Error("预警内容已过期, 放弃重试, id: %s, err: %v", d.Id, err)
+		return false
+	}
+	var msg Message
+	if err = json.Unmarshal([]byte(data), &msg); err != nil {
+		log.Error("预警反序列化失败:", err)
+		return false
+	}
+	chs := n.route(msg.UnitId)
+	if d.Index >= len(chs) {
+		log.Error("预警渠道已不存在, 放弃重试, id: %s, session: %s", d.Id, msg.SessionId)
+		return false
+	}
+	d.channel, d.message = chs[d.Index], &msg
+	return true
+}
+
+// backoff 第attempts次重试前的等待时间
+func backoff(attempts int) time.Duration {
+	wait := retryBase
+	for i := 1; i < attempts && wait < retryMax; i++ {
+		wait *= 2
+	}
+	return min(wait, retryMax)
+}
+
+// Run 定期扫描重试队列并重新投递, 直到ctx结束
+func (n *Notifier) Run(ctx context.Context) {
+	if n.rs == nil {
+		return
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.retry(ctx)
+		}
+	}
+}
+
+// retry 重新投递所有到期的预警
+// 通过Zrem认领, 多实例部署时同一条投递只会被一个实例处理
+func (n *Notifier) retry(ctx context.Context) {
+	pairs, err := n.rs.ZrangebyscoreWithScores(retryKey, 0, time.Now().Unix())
+	if err != nil {
+		log.Error("读取预警重试队列失败:", err)
+		return
+	}
+	for _, p := range pairs {
+		if removed, err := n.rs.Zrem(retryKey, p.Key); err != nil || removed == 0 {
+			continue
+		}
+		var d delivery
+		if err = json.Unmarshal([]byte(p.Key), &d); err != nil {
+			log.Error("预警反序列化失败:", err)
+			continue
+		}
+		if n.restore(&d) {
+			n.deliver(ctx, &d)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package alert
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+func newMessage() *Message {
+	return &Message{
+		SessionId: "s1",
+		UnitId:    "u1",
+		StudentId: "2024001",
+		Name:      "小明",
+		Class:     "三年级二班",
+		Level:     "high",
+		Detector:  "lexicon",
+		Evidence:  []string{"不想活了"},
+		Excerpt: []*Line{
+			{Role: "user", Content: "我不想活了"},
+			{Role: "ai", Content: "能和我说说发生了什么吗"},
+		},
+		Time: time.Date(2025, 3, 1, 8, 30, 0, 0, time.Local),
+	}
+}
+
+func TestNotifier_Route(t *testing.T) {
+	n, err := NewNotifier(&config.Alert{Routes: []config.AlertRoute{
+		{UnitId: "u1", Channels: []config.AlertChannel{{Type: TypeWebhook, Url: "u1"}}},
+		{Channels: []config.AlertChannel{{Type: TypeWebhook, Url: "default"}}},
+	}, Fallback: []string{"alert@example.com"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chs := n.route("u1"); len(chs) != 1 || chs[0].Url != "u1" {
+		t.Errorf("route(u1) = %+v", chs)
+	}
+	if chs := n.route("u2"); len(chs) != 1 || chs[0].Url != "default" {
+		t.Errorf("route(u2) = %+v", chs)
+	}
+
+	n, _ = NewNotifier(&config.Alert{Fallback: []string{"alert@example.com"}}, nil)
+	if chs := n.route("u1"); len(chs) != 1 || chs[0].Type != TypeSMTP || chs[0].To[0] != "alert@example.com" {
+		t.Errorf("fallback route = %+v", chs)
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	r, err := newRenderer(&config.Alert{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notice, err := r.render(newMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notice.Subject != "心理预警: 三年级二班 小明 (高风险)" {
+		t.Errorf("subject = %q", notice.Subject)
+	}
+	for _, want := range []string{"小明 (2024001)", "2025-03-01 08:30:00", "> 学生: 我不想活了", "> AI: 能和我说说发生了什么吗"} {
+		if !strings.Contains(notice.Text, want) {
+			t.Errorf("text missing %q:\n%s", want, notice.Text)
+		}
+	}
+	if !strings.Contains(notice.Html, "<li><b>学生</b>: 我不想活了</li>") {
+		t.Errorf("html = %s", notice.Html)
+	}
+
+	r, _ = newRenderer(&config.Alert{Subject: "[{{.UnitId}}] {{.Name}}"})
+	if notice, _ = r.render(newMessage()); notice.Subject != "[u1] 小明" {
+		t.Errorf("custom subject = %q", notice.Subject)
+	}
+}
+
+func TestNotifier_Robots(t *testing.T) {
+	var got []map[string]any
+	var query []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got = append(got, body)
+		query = append(query, r.URL.RawQuery)
+		if r.URL.Path == "/hook" {
+			// 普通webhook可以不返回响应体
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.URL.Path == "/fail" {
+			_, _ = w.Write([]byte(`{"errcode":310000,"errmsg":"sign not match"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	n, _ := NewNotifier(&config.Alert{Routes: []config.AlertRoute{{UnitId: "u1", Channels: []config.AlertChannel{
+		{Type: TypeDingTalk, Url: server.URL + "/ding?access_token=t", Secret: "sec"},
+		{Type: TypeWeCom, Url: server.URL + "/wecom?key=k"},
+		{Type: TypeWebhook, Url: server.URL + "/hook"},
+	}}}}, nil)
+	n.Notify(context.Background(), newMessage())
+
+	if len(got) != 3 {
+		t.Fatalf("deliveries = %d, want 3", len(got))
+	}
+	if got[0]["msgtype"] != "markdown" || !strings.Contains(got[0]["markdown"].(map[string]any)["text"].(string), "小明") {
+		t.Errorf("dingtalk body = %v", got[0])
+	}
+	q := query[0]
+	ts := q[strings.Index(q, "timestamp=")+len("timestamp=") : strings.Index(q, "&sign=")]
+	mac := hmac.New(sha256.New, []byte("sec"))
+	mac.Write([]byte(ts + "\nsec"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); !strings.Contains(q, "sign="+strings.NewReplacer("+", "%2B", "/", "%2F", "=", "%3D").Replace(want)) {
+		t.Errorf("dingtalk query = %s", q)
+	}
+	if !strings.Contains(got[1]["markdown"].(map[string]any)["content"].(string), "三年级二班") {
+		t.Errorf("wecom body = %v", got[1])
+	}
+	if got[2]["alert"].(map[string]any)["studentId"] != "2024001" {
+		t.Errorf("webhook body = %v", got[2])
+	}
+
+	// 普通webhook只看状态码
+	ch, _ := NewChannel(&config.AlertChannel{Type: TypeWebhook, Url: server.URL + "/hook"})
+	if err := ch.Send(context.Background(), &Notice{Message: newMessage()}); err != nil {
+		t.Errorf("webhook 204: %v", err)
+	}
+
+	// 机器人返回errcode非0时视为失败
+	ch, _ = NewChannel(&config.AlertChannel{Type: TypeWeCom, Url: server.URL + "/fail"})
+	if err := ch.Send(context.Background(), &Notice{Message: newMessage()}); err == nil {
+		t.Error("expected error for errcode != 0")
+	}
+}
+
+func TestChannel_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// 没有响应的webhook在ctx超时后返回, 不会阻塞投递协程
+	ch, _ := NewChannel(&config.AlertChannel{Type: TypeWebhook, Url: server.URL})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := ch.Send(ctx, &Notice{Message: newMessage()}); err == nil {
+		t.Error("expected timeout error")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("send took %v", d)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if backoff(1) != retryBase || backoff(2) != 2*retryBase || backoff(20) != retryMax {
+		t.Errorf("backoff = %v, %v, %v", backoff(1), backoff(2), backoff(20))
+	}
+}
+
+func TestDelivery_Marshal(t *testing.T) {
+	// 重试队列中只保存预警id和渠道序号, 不包含渠道密钥和对话内容
+	d := &delivery{Id: "a1", Index: 1, Attempts: 2,
+		channel: config.AlertChannel{Type: TypeDingTalk, Url: "https://robot?access_token=t", Secret: "sec"},
+		message: newMessage()}
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"id":"a1","index":1,"attempts":2}` {
+		t.Errorf("delivery = %s", data)
+	}
+}
@@ -0,0 +1,317 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// sine 生成一段16位PCM正弦波, channels个声道的值相同
+func sine(rate, channels, ms int) []byte {
+	n := rate * ms / 1000
+	out := make([]byte, 0, 2*n*channels)
+	for i := 0; i < n; i++ {
+		s := int16(8000 * math.Sin(2*math.Pi*300*float64(i)/float64(rate)))
+		for c := 0; c < channels; c++ {
+			out = binary.LittleEndian.AppendUint16(out, uint16(s))
+		}
+	}
+	return out
+}
+
+// writeAll 分成小段写入, 模拟流式传输
+func writeAll(t *testing.T, tc Transcoder, data []byte, chunk int) []byte {
+	t.Helper()
+	var out []byte
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		b, err := tc.Write(data[:n])
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		out = append(out, b...)
+		data = data[n:]
+	}
+	return out
+}
+
+func TestNewTranscoder(t *testing.T) {
+	tc, err := NewTranscoder(Format{})
+	if err != nil || tc.Output() != Target() {
+		t.Fatalf("default format: %v, %+v", err, tc)
+	}
+	if _, err = NewTranscoder(Format{Container: "mp3"}); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("mp3: %v", err)
+	}
+	if _, err = NewTranscoder(Format{Container: ContainerPCM, Bits: 12}); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("12 bits: %v", err)
+	}
+	for _, c := range []string{ContainerOgg, "WebM"} {
+		if tc, err = NewTranscoder(Format{Container: c}); err != nil || tc.Output().IsPCM() {
+			t.Errorf("%s: %v", c, err)
+		}
+	}
+}
+
+func TestPCMConverter(t *testing.T) {
+	// 目标格式原样输出
+	in := sine(16000, 1, 100)
+	tc, _ := NewTranscoder(Format{Container: ContainerPCM})
+	if out := writeAll(t, tc, in, 333); !bytes.Equal(out, in) {
+		t.Errorf("target format changed, %d -> %d bytes", len(in), len(out))
+	}
+
+	cases := []struct {
+		rate, channels int
+	}{
+		{48000, 2},
+		{44100, 1},
+		{8000, 1},
+	}
+	for _, c := range cases {
+		tc, err := NewTranscoder(Format{Container: ContainerPCM, SampleRate: c.rate, Channels: c.channels})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 奇数长度的分段会截断采样点, 验证跨段衔接
+		out := writeAll(t, tc, sine(c.rate, c.channels, 1000), 1001)
+		if n := len(out) / 2; n < 15990 || n > 16010 {
+			t.Errorf("%d Hz x%d: got %d samples, want about 16000", c.rate, c.channels, n)
+		}
+		// 与直接生成的16000采样频率的正弦波接近
+		want := sine(16000, 1, 1000)
+		var diff float64
+		for i := 0; i+1 < len(out) && i+1 < len(want); i += 2 {
+			d := float64(int16(binary.LittleEndian.Uint16(out[i:]))) - float64(int16(binary.LittleEndian.Uint16(want[i:])))
+			diff = math.Max(diff, math.Abs(d))
+		}
+		if diff > 400 {
+			t.Errorf("%d Hz x%d: max diff %.0f", c.rate, c.channels, diff)
+		}
+	}
+}
+
+func TestDecodeSample(t *testing.T) {
+	cases := []struct {
+		b     []byte
+		bits  int
+		float bool
+		want  float64
+	}{
+		{[]byte{0xFF}, 8, false, 127.0 / 128},
+		{[]byte{0x00, 0x80}, 16, false, -1},
+		{[]byte{0x00, 0x00, 0x40}, 24, false, 0.5},
+		{binary.LittleEndian.AppendUint32(nil, math.Float32bits(-0.25)), 32, true, -0.25},
+	}
+	for _, c := range cases {
+		if got := decodeSample(c.b, c.bits, c.float); math.Abs(got-c.want) > 1e-6 {
+			t.Errorf("decodeSample(%x, %d) = %v, want %v", c.b, c.bits, got, c.want)
+		}
+	}
+}
+
+// wavFile 生成WAV文件, 在fmt和data之间插入一个LIST块
+func wavFile(rate, channels int, pcm []byte) []byte {
+	var b []byte
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, wavFormatPCM)
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate*channels*2))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels*2))
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "LIST"...)
+	b = binary.LittleEndian.AppendUint32(b, 3)
+	b = append(b, "abc\x00"...)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, 0xFFFFFFFF)
+	return append(b, pcm...)
+}
+
+func TestWavDecoder(t *testing.T) {
+	pcm := sine(16000, 1, 100)
+	tc, _ := NewTranscoder(Format{Container: ContainerWAV})
+	if out := writeAll(t, tc, wavFile(16000, 1, pcm), 7); !bytes.Equal(out, pcm) {
+		t.Errorf("16k mono wav: got %d bytes, want %d", len(out), len(pcm))
+	}
+
+	tc, _ = NewTranscoder(Format{Container: ContainerWAV})
+	out := writeAll(t, tc, wavFile(32000, 2, sine(32000, 2, 100)), 500)
+	if len(out) != 2*1600 {
+		t.Errorf("32k stereo wav: got %d bytes, want %d", len(out), 2*1600)
+	}
+
+	tc, _ = NewTranscoder(Format{Container: ContainerWAV})
+	if _, err := tc.Write([]byte("RIFF\x00\x00\x00\x00AVI LIST")); err == nil {
+		t.Error("expect error for non-wav input")
+	}
+}
+
+func TestEncodeWAV(t *testing.T) {
+	pcm := sine(16000, 1, 20)
+	f, offset, err := parseWavHeader(EncodeWAV(pcm, Target()))
+	if err != nil || offset != 44 || f != Target() {
+		t.Errorf("parse encoded wav: %+v, %d, %v", f, offset, err)
+	}
+}
+
+func TestOpusSamples(t *testing.T) {
+	cases := []struct {
+		packet []byte
+		want   int
+	}{
+		{[]byte{0x09 << 3}, 960},             // SILK 20ms
+		{[]byte{0x0B<<3 | 1}, 2 * 2880},      // SILK 60ms, 两帧
+		{[]byte{0x1F << 3}, 960},             // CELT 20ms
+		{[]byte{0x10<<3 | 3, 0x04}, 4 * 120}, // CELT 2.5ms, 四帧
+		{nil, 0},
+	}
+	for _, c := range cases {
+		if got := opusSamples(c.packet); got != c.want {
+			t.Errorf("opusSamples(%x) = %d, want %d", c.packet, got, c.want)
+		}
+	}
+}
+
+// element 生成一个EBML元素, size为-1时长度未知
+func element(id uint32, size int, data []byte) []byte {
+	var b []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if c := byte(id >> shift); c != 0 || len(b) > 0 {
+			b = append(b, c)
+		}
+	}
+	if size < 0 {
+		b = append(b, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	} else {
+		b = append(b, 0x40|byte(size>>8), byte(size))
+	}
+	return append(b, data...)
+}
+
+// oggPages 拆分Ogg页, 校验CRC并返回每页的颗粒位置和内容
+func oggPages(t *testing.T, b []byte) (granules []uint64, packets [][]byte) {
+	t.Helper()
+	for len(b) > 0 {
+		if len(b) < 27 || string(b[:4]) != "OggS" {
+			t.Fatalf("invalid page %x", b)
+		}
+		n := int(b[26])
+		size := 0
+		for _, s := range b[27 : 27+n] {
+			size += int(s)
+		}
+		page := append([]byte(nil), b[:27+n+size]...)
+		crc := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		if crc != oggChecksum(page) {
+			t.Fatalf("page %d crc mismatch", len(packets))
+		}
+		granules = append(granules, binary.LittleEndian.Uint64(page[6:]))
+		packets = append(packets, page[27+n:])
+		b = b[27+n+size:]
+	}
+	return granules, packets
+}
+
+func TestWebMRemuxer(t *testing.T) {
+	head := opusHead(1, 48000)
+	track := element(ebmlTrackEntry, -1, append(append(
+		element(ebmlTrackNumber, 1, []byte{1}),
+		element(ebmlCodecID, len(webmCodecOpus), []byte(webmCodecOpus))...),
+		element(ebmlCodecPrivate, len(head), head)...))
+	// 大于255字节的数据包跨越多个分段
+	packets := [][]byte{{0x1F << 3, 1, 2, 3}, append([]byte{0x1F << 3}, bytes.Repeat([]byte{9}, 300)...)}
+
+	var stream []byte
+	stream = append(stream, element(0x1A45DFA3, 4, []byte{0x42, 0x86, 0x81, 0x01})...)
+	stream = append(stream, element(ebmlSegment, -1, nil)...)
+	stream = append(stream, element(0x1549A966, 3, []byte{1, 2, 3})...)
+	stream = append(stream, element(ebmlTracks, len(track), nil)...)
+	stream = append(stream, track...)
+	stream = append(stream, element(ebmlCluster, -1, nil)...)
+	stream = append(stream, element(0xE7, 1, []byte{0})...)
+	for i, p := range packets {
+		block := append([]byte{0x81, 0, byte(20 * i), 0x80}, p...)
+		stream = append(stream, element(ebmlSimpleBlock, len(block), block)...)
+	}
+
+	tc, _ := NewTranscoder(Format{Container: ContainerWebM})
+	granules, pages := oggPages(t, writeAll(t, tc, stream, 5))
+	if len(pages) != 4 {
+		t.Fatalf("got %d pages, want 4", len(pages))
+	}
+	if !bytes.Equal(pages[0], head) || !bytes.HasPrefix(pages[1], []byte("OpusTags")) {
+		t.Errorf("header pages: %q, %q", pages[0], pages[1])
+	}
+	for i, p := range packets {
+		if !bytes.Equal(pages[i+2], p) {
+			t.Errorf("packet %d mismatch", i)
+		}
+		if want := uint64(960 * (i + 1)); granules[i+2] != want {
+			t.Errorf("packet %d granule %d, want %d", i, granules[i+2], want)
+		}
+	}
+	if got := tc.(PacketSource).Packets(); len(got) != 2 || !bytes.Equal(got[1], packets[1]) {
+		t.Errorf("got %d packets for vad", len(got))
+	}
+
+	tc, _ = NewTranscoder(Format{Container: ContainerWebM})
+	vorbis := element(ebmlCodecID, 8, []byte("A_VORBIS"))
+	block := element(ebmlSimpleBlock, 5, []byte{0x81, 0, 0, 0x80, 0})
+	if _, err := tc.Write(append(vorbis, block...)); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("vorbis: %v", err)
+	}
+}
+
+func TestOggReader(t *testing.T) {
+	var w oggWriter
+	packets := [][]byte{{0x09 << 3, 0x80, 1}, append([]byte{0x1F << 3}, bytes.Repeat([]byte{9}, 600)...)}
+	stream := w.header(nil, 1, 48000)
+	for _, p := range packets {
+		stream = append(stream, w.packet(p)...)
+	}
+
+	// 原样透传, 跳过头并拆出跨段的数据包
+	tc, _ := NewTranscoder(Format{Container: ContainerOgg})
+	if out := writeAll(t, tc, stream, 7); !bytes.Equal(out, stream) {
+		t.Error("ogg should pass through")
+	}
+	got := tc.(PacketSource).Packets()
+	if len(got) != 2 || !bytes.Equal(got[0], packets[0]) || !bytes.Equal(got[1], packets[1]) {
+		t.Errorf("got %d packets", len(got))
+	}
+
+	// 不是Ogg流时停止拆包, 仍然透传
+	tc, _ = NewTranscoder(Format{Container: ContainerOgg})
+	junk := bytes.Repeat([]byte{1}, 64)
+	if out, err := tc.Write(junk); err != nil || !bytes.Equal(out, junk) || len(tc.(PacketSource).Packets()) != 0 {
+		t.Errorf("junk: %v", err)
+	}
+}
+
+func TestOpusVoiced(t *testing.T) {
+	cases := []struct {
+		packet []byte
+		voiced bool
+		ok     bool
+	}{
+		{[]byte{0x09 << 3, 0x80, 0}, true, true},            // SILK, 标记为有声
+		{[]byte{0x09 << 3, 0x7F, 0}, false, true},           // SILK, 标记为静音
+		{[]byte{0x0D<<3 | 1, 0x80, 0, 0x00, 0}, true, true}, // 混合模式, 两帧取第一帧
+		{[]byte{0x09<<3 | 2, 2, 0x80, 0, 0}, true, true},    // 两帧长度不同
+		{[]byte{0x09 << 3}, false, true},                    // DTX
+		{[]byte{0x1F << 3, 0x80, 0}, false, false},          // CELT没有标记
+		{[]byte{0x09<<3 | 3, 0x02, 0x80}, false, false},     // 多帧不解析
+	}
+	for _, c := range cases {
+		if voiced, ok := opusVoiced(c.packet); voiced != c.voiced || ok != c.ok {
+			t.Errorf("opusVoiced(%x) = %v, %v", c.packet, voiced, ok)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 支持的封装格式
+const (
+	ContainerPCM  = "pcm"
+	ContainerWAV  = "wav"
+	ContainerOgg  = "ogg"
+	ContainerWebM = "webm"
+)
+
+// 语音识别需要的PCM格式
+const (
+	TargetSampleRate = 16000
+	TargetChannels   = 1
+	TargetBits       = 16
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// Format 音频格式
+type Format struct {
+	// Container 封装格式, pcm / wav / ogg / webm, ogg和webm只支持opus编码
+	Container string
+	// SampleRate 采样频率, 只对pcm有效, wav从文件头读取
+	SampleRate int
+	// Channels 声道数, 只对pcm有效, 多声道会混合为单声道
+	Channels int
+	// Bits 采样位数, 只对pcm有效, 支持8/16/24/32位整数
+	Bits int
+	// Float 采样是否为32位浮点数, 只对pcm有效
+	Float bool
+}
+
+// Target 语音识别需要的PCM格式
+func Target() Format {
+	return Format{Container: ContainerPCM, SampleRate: TargetSampleRate, Channels: TargetChannels, Bits: TargetBits}
+}
+
+// Normalize 补全默认值并校验格式, 未声明格式时使用语音识别需要的格式
+func (f Format) Normalize() (Format, error) {
+	f.Container = strings.ToLower(f.Container)
+	if f.Container == "" {
+		f.Container = ContainerPCM
+	}
+	switch f.Container {
+	case ContainerPCM:
+		if f.SampleRate == 0 {
+			f.SampleRate = TargetSampleRate
+		}
+		if f.Channels == 0 {
+			f.Channels = TargetChannels
+		}
+		if f.Bits == 0 {
+			f.Bits = TargetBits
+		}
+		if f.Float {
+			f.Bits = 32
+		}
+		if f.SampleRate < 8000 || f.SampleRate > 192000 || f.Channels < 1 || f.Channels > 8 {
+			return f, fmt.Errorf("%w: %d Hz, %d channels", ErrUnsupportedFormat, f.SampleRate, f.Channels)
+		}
+		switch f.Bits {
+		case 8, 16, 24, 32:
+		default:
+			return f, fmt.Errorf("%w: %d bits", ErrUnsupportedFormat, f.Bits)
+		}
+	case ContainerWAV, ContainerOgg, ContainerWebM:
+	default:
+		return f, fmt.Errorf("%w: %s", ErrUnsupportedFormat, f.Container)
+	}
+	return f, nil
+}
+
+// IsPCM 是否为未压缩的PCM, 不是PCM时语音活动检测使用转换器取出的Opus数据包
+func (f Format) IsPCM() bool {
+	return f.Container == ContainerPCM
+}
+
+// Transcoder 将客户端的音频流转换为语音识别支持的格式
+// PCM和WAV解码并重采样为16000采样频率的16位单声道PCM; Ogg/Opus直接透传, WebM/Opus重新封装为Ogg/Opus
+// Opus不解码, 只有支持Ogg/Opus的识别服务(火山引擎)可以使用
+type Transcoder interface {
+	// Write 写入一段音频, 返回转换后的数据, 数据不足时返回nil
+	Write(data []byte) ([]byte, error)
+	// Output 转换后的格式
+	Output() Format
+}
+
+// PacketSource 输出为Ogg/Opus的转换器实现, 提供转换时取出的Opus数据包, 用于不解码的语音活动检测
+type PacketSource interface {
+	// Packets 返回并清空上次调用后取出的Opus数据包
+	Packets() [][]byte
+}
+
+// opusPackets 记录取出的Opus数据包
+type opusPackets struct {
+	packets [][]byte
+}
+
+func (o *opusPackets) add(packet []byte) {
+	o.packets = append(o.packets, append([]byte(nil), packet...))
+}
+
+func (o *opusPackets) Packets() [][]byte {
+	out := o.packets
+	o.packets = nil
+	return out
+}
+
+// NewTranscoder 根据客户端声明的格式创建转换器
+func NewTranscoder(in Format) (Transcoder, error) {
+	in, err := in.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	switch in.Container {
+	case ContainerWAV:
+		return &wavDecoder{}, nil
+	case ContainerOgg:
+		return &oggReader{}, nil
+	case ContainerWebM:
+		return newWebMRemuxer(), nil
+	default:
+		return newPCMConverter(in), nil
+	}
+}
@@ -0,0 +1,241 @@
+package audio
+
+import (
+	"encoding/binary"
+)
+
+// oggSerial Ogg逻辑流的序列号, 只有一个流所以使用固定值
+const oggSerial = 0x70737963
+
+// Ogg页头的标记位
+const (
+	oggFirst = 0x02
+)
+
+// oggCRC Ogg页使用的CRC32查找表, 多项式0x04c11db7, 不反转
+var oggCRC = func() [256]uint32 {
+	var t [256]uint32
+	for i := range t {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+// oggWriter 将Opus数据包封装为Ogg页, 每个数据包单独一页, 以降低转发延迟
+type oggWriter struct {
+	seq     uint32
+	granule uint64
+}
+
+// header 生成OpusHead和OpusTags两个头页, head为空时按channels和rate生成
+func (w *oggWriter) header(head []byte, channels, rate int) []byte {
+	if len(head) < 19 || string(head[:8]) != "OpusHead" {
+		head = opusHead(channels, rate)
+	}
+	out := w.page(head, oggFirst, 0)
+	tags := []byte("OpusTags")
+	tags = binary.LittleEndian.AppendUint32(tags, uint32(len("psych-digital")))
+	tags = append(tags, "psych-digital"...)
+	tags = binary.LittleEndian.AppendUint32(tags, 0)
+	return append(out, w.page(tags, 0, 0)...)
+}
+
+// packet 封装一个Opus数据包, 颗粒位置为48000采样频率下已经编码的采样数
+func (w *oggWriter) packet(data []byte) []byte {
+	w.granule += uint64(opusSamples(data))
+	return w.page(data, 0, w.granule)
+}
+
+// page 生成一个Ogg页
+func (w *oggWriter) page(data []byte, flag byte, granule uint64) []byte {
+	// 分段表, 每段最多255字节, 长度为255的整数倍时以一个空段结尾
+	var segments []byte
+	for n := len(data); ; n -= 255 {
+		if n < 255 {
+			segments = append(segments, byte(n))
+			break
+		}
+		segments = append(segments, 255)
+	}
+
+	out := make([]byte, 0, 27+len(segments)+len(data))
+	out = append(out, "OggS"...)
+	out = append(out, 0, flag)
+	out = binary.LittleEndian.AppendUint64(out, granule)
+	out = binary.LittleEndian.AppendUint32(out, oggSerial)
+	out = binary.LittleEndian.AppendUint32(out, w.seq)
+	out = binary.LittleEndian.AppendUint32(out, 0)
+	out = append(out, byte(len(segments)))
+	out = append(out, segments...)
+	out = append(out, data...)
+	w.seq++
+
+	binary.LittleEndian.PutUint32(out[22:], oggChecksum(out))
+	return out
+}
+
+// oggChecksum 计算Ogg页的校验和, 计算时页头中的校验和字段为0
+func oggChecksum(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRC[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// oggReader 客户端发送的Ogg/Opus原样透传给识别服务, 同时拆出Opus数据包用于语音活动检测
+// 数据不是合法的Ogg流时停止拆包, 仍然透传, 由识别服务判断
+type oggReader struct {
+	buf []byte
+	// packet 跨页的未完成数据包
+	packet []byte
+	broken bool
+	opusPackets
+}
+
+func (r *oggReader) Output() Format {
+	return Format{Container: ContainerOgg}
+}
+
+func (r *oggReader) Write(data []byte) ([]byte, error) {
+	if r.broken {
+		return data, nil
+	}
+	r.buf = append(r.buf, data...)
+	for len(r.buf) >= 27 {
+		if string(r.buf[:4]) != "OggS" {
+			r.broken, r.buf, r.packet = true, nil, nil
+			return data, nil
+		}
+		n := int(r.buf[26])
+		if len(r.buf) < 27+n {
+			break
+		}
+		segments := r.buf[27 : 27+n]
+		size := 0
+		for _, s := range segments {
+			size += int(s)
+		}
+		if len(r.buf) < 27+n+size {
+			break
+		}
+		// 分段长度小于255时一个数据包结束, 否则延续到下一段或下一页
+		body := r.buf[27+n:]
+		for _, s := range segments {
+			r.packet = append(r.packet, body[:s]...)
+			body = body[s:]
+			if s < 255 {
+				r.opus(r.packet)
+				r.packet = r.packet[:0]
+			}
+		}
+		r.buf = r.buf[27+n+size:]
+	}
+	// 释放已经处理的数据
+	r.buf = append([]byte(nil), r.buf...)
+	return data, nil
+}
+
+// opus 记录一个数据包, 跳过OpusHead和OpusTags两个头
+func (r *oggReader) opus(packet []byte) {
+	if len(packet) >= 8 && (string(packet[:8]) == "OpusHead" || string(packet[:8]) == "OpusTags") {
+		return
+	}
+	r.add(packet)
+}
+
+// opusHead 生成Opus的标识头
+func opusHead(channels, rate int) []byte {
+	if channels <= 0 {
+		channels = 1
+	}
+	if rate <= 0 {
+		rate = 48000
+	}
+	head := []byte("OpusHead")
+	head = append(head, 1, byte(channels))
+	head = binary.LittleEndian.AppendUint16(head, 0)
+	head = binary.LittleEndian.AppendUint32(head, uint32(rate))
+	head = binary.LittleEndian.AppendUint16(head, 0)
+	return append(head, 0)
+}
+
+// opusVoiced 读取Opus数据包中编码器写入的语音活动标记, 只有SILK和混合模式有标记, 没有时ok为false
+// 不超过1字节的帧是不连续传输(DTX)时的静音帧; SILK的标记是区间编码的第一个比特, 概率为1/2, 即第一帧首字节的最高位
+func opusVoiced(packet []byte) (voiced, ok bool) {
+	if len(packet) == 0 {
+		return false, false
+	}
+	// 第一帧的数据, 多帧且长度不同时跳过帧长字段, 带填充的多帧数据包不解析
+	var frame []byte
+	switch packet[0] & 0x03 {
+	case 0:
+		frame = packet[1:]
+	case 1:
+		frame = packet[1 : 1+(len(packet)-1)/2]
+	case 2:
+		if len(packet) < 2 {
+			return false, false
+		}
+		n, off := int(packet[1]), 2
+		if n >= 252 {
+			if len(packet) < 3 {
+				return false, false
+			}
+			n, off = n+4*int(packet[2]), 3
+		}
+		frame = packet[off:min(off+n, len(packet))]
+	default:
+		return false, false
+	}
+	if len(frame) <= 1 {
+		return false, true
+	}
+	if packet[0]>>3 >= 16 {
+		return false, false
+	}
+	return frame[0]&0x80 != 0, true
+}
+
+// opusSamples 根据TOC字节计算一个Opus数据包在48000采样频率下的采样数
+func opusSamples(packet []byte) int {
+	if len(packet) == 0 {
+		return 0
+	}
+	toc := packet[0]
+	config := int(toc >> 3)
+
+	// 单帧时长, 单位为48000采样频率下的采样数
+	var frame int
+	switch {
+	case config < 12:
+		// SILK: 10/20/40/60ms
+		frame = []int{480, 960, 1920, 2880}[config%4]
+	case config < 16:
+		// Hybrid: 10/20ms
+		frame = []int{480, 960}[config%2]
+	default:
+		// CELT: 2.5/5/10/20ms
+		frame = []int{120, 240, 480, 960}[config%4]
+	}
+
+	switch toc & 0x03 {
+	case 0:
+		return frame
+	case 1, 2:
+		return 2 * frame
+	default:
+		if len(packet) < 2 {
+			return 0
+		}
+		return int(packet[1]&0x3F) * frame
+	}
+}
@@ -0,0 +1,119 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// pcmConverter 将任意PCM转换为语音识别需要的格式
+// 多声道取平均值混合为单声道, 采样频率不同时线性插值重采样
+type pcmConverter struct {
+	in Format
+	// frame 一个采样点(所有声道)的字节数
+	frame int
+	// rest 上次写入时不足一个采样点的数据
+	rest []byte
+
+	// step 输出一个采样点对应的输入采样点数
+	step float64
+	// t 下一个输出采样点在本次输入中的位置, 负数表示位于上次输入的最后一个采样点之后
+	t float64
+	// prev 上次输入的最后一个采样点
+	prev    float64
+	hasPrev bool
+}
+
+func newPCMConverter(in Format) *pcmConverter {
+	return &pcmConverter{
+		in:    in,
+		frame: in.Bits / 8 * in.Channels,
+		step:  float64(in.SampleRate) / TargetSampleRate,
+	}
+}
+
+func (c *pcmConverter) Output() Format {
+	return Target()
+}
+
+func (c *pcmConverter) Write(data []byte) ([]byte, error) {
+	data = append(c.rest, data...)
+	n := len(data) / c.frame
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = c.mix(data[i*c.frame : (i+1)*c.frame])
+	}
+	// data可能与rest共用底层数组, 所以解码之后再保存剩余的数据
+	c.rest = append(c.rest[:0], data[n*c.frame:]...)
+	if n == 0 {
+		return nil, nil
+	}
+	if c.step == 1 {
+		return encode16(samples), nil
+	}
+	return encode16(c.resample(samples)), nil
+}
+
+// mix 解码一个采样点并混合为单声道, 范围为[-1, 1]
+func (c *pcmConverter) mix(frame []byte) float64 {
+	size := c.in.Bits / 8
+	var sum float64
+	for ch := 0; ch < c.in.Channels; ch++ {
+		sum += decodeSample(frame[ch*size:(ch+1)*size], c.in.Bits, c.in.Float)
+	}
+	return sum / float64(c.in.Channels)
+}
+
+// resample 线性插值重采样, 跨越多次写入时使用上次的最后一个采样点衔接
+func (c *pcmConverter) resample(samples []float64) []float64 {
+	get := func(i int) float64 {
+		if i < 0 {
+			return c.prev
+		}
+		return samples[i]
+	}
+	if !c.hasPrev {
+		c.t = 0
+	}
+
+	last := float64(len(samples) - 1)
+	out := make([]float64, 0, int(float64(len(samples))/c.step)+1)
+	for ; c.t <= last; c.t += c.step {
+		i := int(math.Floor(c.t))
+		frac := c.t - float64(i)
+		v := get(i)
+		if frac > 0 {
+			v += (get(i+1) - v) * frac
+		}
+		out = append(out, v)
+	}
+	c.t -= float64(len(samples))
+	c.prev, c.hasPrev = samples[len(samples)-1], true
+	return out
+}
+
+// decodeSample 解码一个声道的采样值, 8位为无符号数, 其余为有符号小端序
+func decodeSample(b []byte, bits int, float bool) float64 {
+	switch {
+	case float:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case bits == 8:
+		return (float64(b[0]) - 128) / 128
+	case bits == 16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case bits == 24:
+		v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+		return float64(v) / (1 << 23)
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+}
+
+// encode16 编码为16位小端序PCM, 与decodeSample使用相同的比例, 16位输入可以无损还原
+func encode16(samples []float64) []byte {
+	out := make([]byte, 2*len(samples))
+	for i, v := range samples {
+		v = math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(v*(1<<15))))
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(v)))
+	}
+	return out
+}
@@ -0,0 +1,183 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+// 检测的音频为语音识别需要的PCM格式
+const (
+	// VadFrameMs 检测的帧长, 单位毫秒
+	VadFrameMs = 20
+	// VadFrameBytes 一帧的字节数
+	VadFrameBytes = TargetSampleRate / 1000 * VadFrameMs * TargetBits / 8
+)
+
+// 检测参数的默认值
+const (
+	defaultSilence   = 800
+	defaultMinSpeech = 100
+	defaultThreshold = 300
+	// maxZcr 有声帧的最高过零率, 过零率很高的帧通常是噪声
+	maxZcr = 0.5
+	// noiseRatio 有声帧的能量至少是环境噪声的倍数
+	noiseRatio = 3
+	// opusRatio 没有语音活动标记时, 有声Opus数据包的大小至少是静音时的倍数
+	opusRatio = 1.5
+	// minOpusBytes 没有语音活动标记时, 有声Opus数据包每20ms的最少字节数
+	minOpusBytes = 10
+)
+
+// VadEvent 语音活动检测的事件
+type VadEvent int
+
+const (
+	// VadStart 开始说话
+	VadStart VadEvent = iota + 1
+	// VadEnd 说完一句话
+	VadEnd
+)
+
+// Vad 基于短时能量和过零率的语音活动检测
+// 连续有声超过minSpeech时认为开始说话, 说话后连续静音超过silence时认为一句话结束
+// 环境噪声的能量随静音帧更新, 有声的阈值不低于噪声的noiseRatio倍
+type Vad struct {
+	silence   int
+	minSpeech int
+	threshold float64
+
+	// rest 上次写入时不足一帧的数据
+	rest []byte
+	// noise 环境噪声的能量
+	noise float64
+	// floor 静音时Opus数据包每20ms的字节数
+	floor float64
+	// speaking 是否在说话
+	speaking bool
+	// voiced 连续有声的时长
+	voiced int
+	// unvoiced 连续静音的时长
+	unvoiced int
+}
+
+// NewVad 根据配置创建语音活动检测, 未配置的参数使用默认值
+func NewVad(c *config.Vad) *Vad {
+	v := &Vad{
+		silence:   c.Silence,
+		minSpeech: c.MinSpeech,
+		threshold: float64(c.Threshold),
+	}
+	if v.silence <= 0 {
+		v.silence = defaultSilence
+	}
+	if v.minSpeech <= 0 {
+		v.minSpeech = defaultMinSpeech
+	}
+	if v.threshold <= 0 {
+		v.threshold = defaultThreshold
+	}
+	return v
+}
+
+// Write 写入一段音频, 返回其中检测到的事件
+func (v *Vad) Write(pcm []byte) []VadEvent {
+	var events []VadEvent
+	data := append(v.rest, pcm...)
+	for len(data) >= VadFrameBytes {
+		if e := v.Frame(data[:VadFrameBytes]); e != 0 {
+			events = append(events, e)
+		}
+		data = data[VadFrameBytes:]
+	}
+	v.rest = append(v.rest[:0], data...)
+	return events
+}
+
+// Speaking 是否在说话
+func (v *Vad) Speaking() bool {
+	return v.speaking
+}
+
+// Frame 检测一帧长度为VadFrameBytes的音频, 需要逐帧处理音频的调用方直接使用, 不能与Write混用
+func (v *Vad) Frame(frame []byte) VadEvent {
+	rms, zcr := analyse(frame)
+	voiced := v.isVoiced(rms, zcr)
+	if !voiced && !v.speaking {
+		// 只用静音帧估计噪声, 避免说话声抬高阈值
+		v.noise = smooth(v.noise, rms)
+	}
+	return v.step(voiced, VadFrameMs)
+}
+
+// Packet 检测一个Opus数据包, 不需要解码
+// SILK和混合模式使用编码器写入的语音活动标记; CELT模式没有标记, 按数据量判断,
+// 可变码率时说话的数据量明显高于静音, 固定码率时检测不到说话, 只由识别服务断句
+func (v *Vad) Packet(packet []byte) VadEvent {
+	ms := opusSamples(packet) / 48
+	if ms == 0 {
+		return 0
+	}
+	size := float64(len(packet)) * VadFrameMs / float64(ms)
+	voiced, ok := opusVoiced(packet)
+	if !ok {
+		// 码率与编码器设置有关, 没有绝对的阈值, 第一个数据包只用于估计静音的数据量
+		voiced = v.floor > 0 && size >= math.Max(minOpusBytes, v.floor*opusRatio)
+	}
+	if !voiced && !v.speaking {
+		v.floor = smooth(v.floor, size)
+	}
+	return v.step(voiced, ms)
+}
+
+// step 记录一段时长为ms的音频是否有声, 返回产生的事件
+func (v *Vad) step(voiced bool, ms int) VadEvent {
+	if voiced {
+		v.voiced += ms
+		v.unvoiced = 0
+		if !v.speaking && v.voiced >= v.minSpeech {
+			v.speaking = true
+			return VadStart
+		}
+		return 0
+	}
+
+	v.voiced = 0
+	v.unvoiced += ms
+	if v.speaking && v.unvoiced >= v.silence {
+		v.speaking = false
+		return VadEnd
+	}
+	return 0
+}
+
+// smooth 用新的静音样本更新估计值, 第一个样本直接作为估计值
+func smooth(estimate, sample float64) float64 {
+	if estimate == 0 {
+		return sample
+	}
+	return 0.95*estimate + 0.05*sample
+}
+
+// isVoiced 判断一帧是否有声
+func (v *Vad) isVoiced(rms, zcr float64) bool {
+	return rms >= math.Max(v.threshold, v.noise*noiseRatio) && zcr <= maxZcr
+}
+
+// analyse 计算一帧的均方根能量和过零率
+func analyse(frame []byte) (rms, zcr float64) {
+	n := len(frame) / 2
+	var sum float64
+	var crossings int
+	var prev int16
+	for i := 0; i < n; i++ {
+		s := int16(binary.LittleEndian.Uint16(frame[2*i:]))
+		sum += float64(s) * float64(s)
+		if i > 0 && (s >= 0) != (prev >= 0) {
+			crossings++
+		}
+		prev = s
+	}
+	return math.Sqrt(sum / float64(n)), float64(crossings) / float64(n)
+}
@@ -0,0 +1,86 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
+
+// tone 生成一段指定时长和振幅的正弦波, 振幅为0时为静音
+func tone(ms int, amplitude float64) []byte {
+	n := TargetSampleRate / 1000 * ms
+	pcm := make([]byte, 2*n)
+	for i := 0; i < n; i++ {
+		s := int16(amplitude * math.Sin(2*math.Pi*200*float64(i)/TargetSampleRate))
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(s))
+	}
+	return pcm
+}
+
+func TestVad_Write(t *testing.T) {
+	v := NewVad(&config.Vad{Silence: 300})
+
+	var events []VadEvent
+	// 背景噪声不会触发
+	events = append(events, v.Write(tone(500, 50))...)
+	// 分多次写入, 每次不足一帧
+	speech := tone(400, 3000)
+	for i := 0; i < len(speech); i += 500 {
+		events = append(events, v.Write(speech[i:min(i+500, len(speech))])...)
+	}
+	if !v.Speaking() {
+		t.Fatal("expected speaking")
+	}
+	// 短暂停顿不结束
+	events = append(events, v.Write(tone(200, 0))...)
+	events = append(events, v.Write(tone(200, 3000))...)
+	events = append(events, v.Write(tone(400, 0))...)
+
+	if len(events) != 2 || events[0] != VadStart || events[1] != VadEnd {
+		t.Errorf("events = %v", events)
+	}
+}
+
+// packets 生成ms时长的20ms Opus数据包
+func packets(ms int, packet []byte) [][]byte {
+	out := make([][]byte, ms/VadFrameMs)
+	for i := range out {
+		out[i] = packet
+	}
+	return out
+}
+
+func TestVad_Packet(t *testing.T) {
+	silk := func(voiced bool) []byte {
+		if voiced {
+			return []byte{0x09 << 3, 0x80, 0, 0}
+		}
+		return []byte{0x09 << 3, 0x00, 0, 0}
+	}
+	celt := func(size int) []byte {
+		return append([]byte{0x1F << 3}, make([]byte, size-1)...)
+	}
+	// SILK使用语音活动标记, CELT按可变码率的数据量判断
+	for name, tt := range map[string][3][]byte{
+		"silk": {silk(false), silk(true), silk(false)},
+		"celt": {celt(30), celt(120), celt(30)},
+	} {
+		v := NewVad(&config.Vad{Silence: 300})
+		var events []VadEvent
+		for _, seq := range []struct {
+			ms     int
+			packet []byte
+		}{{500, tt[0]}, {400, tt[1]}, {200, tt[2]}, {200, tt[1]}, {400, tt[2]}} {
+			for _, p := range packets(seq.ms, seq.packet) {
+				if e := v.Packet(p); e != 0 {
+					events = append(events, e)
+				}
+			}
+		}
+		if len(events) != 2 || events[0] != VadStart || events[1] != VadEnd {
+			t.Errorf("%s: events = %v", name, events)
+		}
+	}
+}
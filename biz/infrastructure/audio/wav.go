@@ -0,0 +1,131 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// WAV的编码格式
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// maxWavHeader 等待data块时最多缓存的文件头长度
+const maxWavHeader = 64 * 1024
+
+var errInvalidWav = errors.New("invalid wav header")
+
+// wavDecoder 解析WAV文件头, 之后的数据按文件头声明的格式转换
+// 流式录制的WAV文件头中的长度通常不准确, 所以data块之后的所有数据都作为音频
+type wavDecoder struct {
+	header []byte
+	pcm    *pcmConverter
+}
+
+func (d *wavDecoder) Output() Format {
+	return Target()
+}
+
+func (d *wavDecoder) Write(data []byte) ([]byte, error) {
+	if d.pcm != nil {
+		return d.pcm.Write(data)
+	}
+
+	d.header = append(d.header, data...)
+	format, offset, err := parseWavHeader(d.header)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		if len(d.header) > maxWavHeader {
+			return nil, errInvalidWav
+		}
+		return nil, nil
+	}
+	if format, err = format.Normalize(); err != nil {
+		return nil, err
+	}
+	d.pcm = newPCMConverter(format)
+	body := d.header[offset:]
+	d.header = nil
+	return d.pcm.Write(body)
+}
+
+// parseWavHeader 解析WAV文件头, 返回音频格式和data块的起始位置, 文件头不完整时位置为-1
+func parseWavHeader(b []byte) (Format, int, error) {
+	var f Format
+	if len(b) < 12 {
+		return f, -1, nil
+	}
+	if string(b[0:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return f, -1, errInvalidWav
+	}
+
+	hasFmt := false
+	for pos := 12; pos+8 <= len(b); {
+		id, size := string(b[pos:pos+4]), int(binary.LittleEndian.Uint32(b[pos+4:pos+8]))
+		body := pos + 8
+		switch id {
+		case "fmt ":
+			if body+16 > len(b) {
+				return f, -1, nil
+			}
+			tag := binary.LittleEndian.Uint16(b[body:])
+			f = Format{
+				Container:  ContainerPCM,
+				Channels:   int(binary.LittleEndian.Uint16(b[body+2:])),
+				SampleRate: int(binary.LittleEndian.Uint32(b[body+4:])),
+				Bits:       int(binary.LittleEndian.Uint16(b[body+14:])),
+			}
+			if tag == wavFormatExtensible && size >= 40 {
+				if body+26 > len(b) {
+					return f, -1, nil
+				}
+				// 扩展格式的子格式GUID的前两个字节为实际的编码格式
+				tag = binary.LittleEndian.Uint16(b[body+24:])
+			}
+			switch tag {
+			case wavFormatPCM:
+			case wavFormatFloat:
+				f.Float = true
+			default:
+				return f, -1, fmt.Errorf("%w: wav format %d", ErrUnsupportedFormat, tag)
+			}
+			hasFmt = true
+		case "data":
+			if !hasFmt {
+				return f, -1, errInvalidWav
+			}
+			return f, body, nil
+		}
+		// 块的长度为奇数时有一个填充字节
+		pos = body + size + size%2
+	}
+	return f, -1, nil
+}
+
+// EncodeWAV 为PCM数据加上WAV文件头
+func EncodeWAV(pcm []byte, f Format) []byte {
+	size := f.Bits / 8 * f.Channels
+	tag := uint16(wavFormatPCM)
+	if f.Float {
+		tag = wavFormatFloat
+	}
+	b := make([]byte, 0, 44+len(pcm))
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+len(pcm)))
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, tag)
+	b = binary.LittleEndian.AppendUint16(b, uint16(f.Channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(f.SampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(f.SampleRate*size))
+	b = binary.LittleEndian.AppendUint16(b, uint16(size))
+	b = binary.LittleEndian.AppendUint16(b, uint16(f.Bits))
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(pcm)))
+	return append(b, pcm...)
+}
@@ -0,0 +1,249 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// WebM(Matroska)中用到的元素id
+const (
+	ebmlSegment      = 0x18538067
+	ebmlCluster      = 0x1F43B675
+	ebmlTracks       = 0x1654AE6B
+	ebmlTrackEntry   = 0xAE
+	ebmlTrackNumber  = 0xD7
+	ebmlCodecID      = 0x86
+	ebmlCodecPrivate = 0x63A2
+	ebmlAudio        = 0xE1
+	ebmlSampling     = 0xB5
+	ebmlChannels     = 0x9F
+	ebmlBlockGroup   = 0xA0
+	ebmlBlock        = 0xA1
+	ebmlSimpleBlock  = 0xA3
+)
+
+// webmCodecOpus WebM中Opus的编码id
+const webmCodecOpus = "A_OPUS"
+
+// maxWebMElement 需要完整读取的元素的最大长度, 超过时认为数据有误
+const maxWebMElement = 1 << 20
+
+var (
+	errInvalidWebM = errors.New("invalid webm stream")
+	errWebMCodec   = fmt.Errorf("%w: webm only supports opus", ErrUnsupportedFormat)
+)
+
+// webmMasters 需要进入内部解析的元素, 浏览器录制时Segment和Cluster的长度通常未知
+var webmMasters = map[uint32]bool{
+	ebmlSegment:    true,
+	ebmlCluster:    true,
+	ebmlTracks:     true,
+	ebmlTrackEntry: true,
+	ebmlAudio:      true,
+	ebmlBlockGroup: true,
+}
+
+// webmRemuxer 从浏览器MediaRecorder录制的WebM/Opus中取出Opus数据包, 重新封装为Ogg/Opus
+// 只解析用到的元素, 其余元素直接跳过; 不解码音频, 所以不需要Opus解码库
+type webmRemuxer struct {
+	buf []byte
+	// skip 还需要跳过的字节数
+	skip int64
+
+	// track 音频轨道的编号, 0表示取第一个出现的轨道
+	track    uint64
+	codec    string
+	private  []byte
+	channels int
+	rate     int
+
+	ogg     oggWriter
+	started bool
+	opusPackets
+}
+
+func newWebMRemuxer() *webmRemuxer {
+	return &webmRemuxer{}
+}
+
+func (r *webmRemuxer) Output() Format {
+	return Format{Container: ContainerOgg}
+}
+
+func (r *webmRemuxer) Write(data []byte) ([]byte, error) {
+	if r.skip > 0 {
+		n := min(r.skip, int64(len(data)))
+		r.skip -= n
+		data = data[n:]
+	}
+	r.buf = append(r.buf, data...)
+
+	var out []byte
+	for len(r.buf) > 0 && r.skip == 0 {
+		id, idLen, ok := readVint(r.buf, true)
+		if !ok {
+			break
+		}
+		size, sizeLen, ok := readVint(r.buf[idLen:], false)
+		if !ok {
+			break
+		}
+		head := idLen + sizeLen
+		if idLen == 0 || idLen > 4 || sizeLen == 0 {
+			return nil, errInvalidWebM
+		}
+
+		if webmMasters[uint32(id)] {
+			r.buf = r.buf[head:]
+			continue
+		}
+		if size < 0 {
+			return nil, errInvalidWebM
+		}
+		if !r.wanted(uint32(id)) {
+			// 不需要的元素, 缓存中不足的部分在后续写入时跳过
+			if int64(len(r.buf)-head) < size {
+				r.skip = size - int64(len(r.buf)-head)
+				r.buf = r.buf[:0]
+				break
+			}
+			r.buf = r.buf[head+int(size):]
+			continue
+		}
+		if size > maxWebMElement {
+			return nil, errInvalidWebM
+		}
+		if len(r.buf) < head+int(size) {
+			break
+		}
+
+		pages, err := r.element(uint32(id), r.buf[head:head+int(size)])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, pages...)
+		r.buf = r.buf[head+int(size):]
+	}
+	// 释放已经处理的数据
+	r.buf = append([]byte(nil), r.buf...)
+	return out, nil
+}
+
+// wanted 是否需要读取元素的内容
+func (r *webmRemuxer) wanted(id uint32) bool {
+	switch id {
+	case ebmlTrackNumber, ebmlCodecID, ebmlCodecPrivate, ebmlSampling, ebmlChannels, ebmlBlock, ebmlSimpleBlock:
+		return true
+	}
+	return false
+}
+
+// element 处理一个元素, 返回生成的Ogg页
+func (r *webmRemuxer) element(id uint32, data []byte) ([]byte, error) {
+	switch id {
+	case ebmlTrackNumber:
+		if r.track == 0 {
+			r.track = readUint(data)
+		}
+	case ebmlCodecID:
+		if r.codec == "" {
+			r.codec = string(data)
+		}
+	case ebmlCodecPrivate:
+		if r.private == nil {
+			r.private = append([]byte(nil), data...)
+		}
+	case ebmlChannels:
+		if r.channels == 0 {
+			r.channels = int(readUint(data))
+		}
+	case ebmlSampling:
+		if r.rate == 0 {
+			r.rate = int(readFloat(data))
+		}
+	case ebmlBlock, ebmlSimpleBlock:
+		return r.block(data)
+	}
+	return nil, nil
+}
+
+// block 取出一个块中的Opus数据包
+func (r *webmRemuxer) block(data []byte) ([]byte, error) {
+	track, n, ok := readVint(data, false)
+	// 轨道编号之后是2字节的时间码和1字节的标记
+	if !ok || n == 0 || len(data) < n+3 {
+		return nil, errInvalidWebM
+	}
+	if r.track != 0 && uint64(track) != r.track {
+		return nil, nil
+	}
+	if r.codec != "" && r.codec != webmCodecOpus {
+		return nil, errWebMCodec
+	}
+	if data[n+2]&0x06 != 0 {
+		return nil, fmt.Errorf("%w: webm lacing", ErrUnsupportedFormat)
+	}
+
+	var out []byte
+	if !r.started {
+		r.started = true
+		r.track = uint64(track)
+		out = r.ogg.header(r.private, r.channels, r.rate)
+	}
+	r.add(data[n+3:])
+	return append(out, r.ogg.packet(data[n+3:])...), nil
+}
+
+// readVint 读取EBML的变长整数, 返回值和长度, 数据不足时ok为false, 格式错误时长度为0
+// id保留长度标记位, 长度去掉标记位, 全1的长度表示未知, 返回-1
+func readVint(b []byte, id bool) (v int64, n int, ok bool) {
+	if len(b) == 0 {
+		return 0, 0, false
+	}
+	n = 1
+	for mask := byte(0x80); n <= 8 && b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 8 {
+		return 0, 0, true
+	}
+	if len(b) < n {
+		return 0, 0, false
+	}
+
+	first := uint64(b[0])
+	if !id {
+		first &= 0xFF >> n
+	}
+	u, all := first, first == 0xFF>>n
+	for _, c := range b[1:n] {
+		u = u<<8 | uint64(c)
+		all = all && c == 0xFF
+	}
+	if !id && all {
+		return -1, n, true
+	}
+	return int64(u), n, true
+}
+
+// readUint 读取无符号整数元素
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// readFloat 读取浮点数元素
+func readFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
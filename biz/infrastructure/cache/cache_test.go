@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDiskStore(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("miss: %v", err)
+	}
+	if err = s.Set("../a", []byte("x")); err == nil {
+		t.Error("expect error for invalid key")
+	}
+	if err = s.Set("big", make([]byte, 101)); err == nil {
+		t.Error("expect error for value over limit")
+	}
+
+	old := time.Now().Add(-time.Hour)
+	for i, key := range []string{"a", "b"} {
+		if err = s.Set(key, bytes.Repeat([]byte{byte(i)}, 40)); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.Chtimes(filepath.Join(dir, key), old.Add(time.Duration(i)*time.Minute), old.Add(time.Duration(i)*time.Minute))
+	}
+	// 命中后a变为最近使用, 写入c时淘汰b
+	if v, err := s.Get("a"); err != nil || len(v) != 40 || v[0] != 0 {
+		t.Fatalf("get a: %v", err)
+	}
+	if err = s.Set("c", make([]byte, 40)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Get("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("b should be evicted: %v", err)
+	}
+	if _, err = s.Get("a"); err != nil {
+		t.Errorf("a should be kept: %v", err)
+	}
+
+	// 重新打开时统计已有文件
+	if s, err = NewDiskStore(dir, 100); err != nil || s.size != 80 {
+		t.Errorf("reopen size %d: %v", s.size, err)
+	}
+}
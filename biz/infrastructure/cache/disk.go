@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+var _ Store = (*DiskStore)(nil)
+
+// DiskStore 每个key一个文件的磁盘缓存, 总大小超过上限时按修改时间淘汰, 命中时更新修改时间
+type DiskStore struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	// size 缓存文件的总大小
+	size int64
+}
+
+// NewDiskStore 创建磁盘缓存, 目录不存在时创建, 已有的文件计入总大小
+func NewDiskStore(dir string, maxBytes int64) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &DiskStore{dir: dir, maxBytes: maxBytes}
+	entries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		s.size += e.size
+	}
+	return s, nil
+}
+
+// Get 读取key对应的文件
+func (s *DiskStore) Get(key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, nil
+}
+
+// Set 先写入临时文件再重命名, 避免读到写了一半的内容, 超过上限时淘汰最久未使用的文件
+func (s *DiskStore) Set(key string, value []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if int64(len(value)) > s.maxBytes {
+		return fmt.Errorf("cache value %d bytes exceeds %d", len(value), s.maxBytes)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(value)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if info, err := os.Stat(path); err == nil {
+		s.size -= info.Size()
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	s.size += int64(len(value))
+	if s.size > s.maxBytes {
+		return s.evict()
+	}
+	return nil
+}
+
+// diskEntry 一个缓存文件
+type diskEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// entries 列出所有缓存文件, 忽略临时文件
+func (s *DiskStore) entries() ([]*diskEntry, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*diskEntry
+	for _, f := range files {
+		if f.IsDir() || f.Name()[0] == '.' {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, &diskEntry{path: filepath.Join(s.dir, f.Name()), size: info.Size(), modTime: info.ModTime()})
+	}
+	return entries, nil
+}
+
+// evict 按修改时间从旧到新删除文件, 直到总大小不超过上限, 调用方需持有锁
+func (s *DiskStore) evict() error {
+	entries, err := s.entries()
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(entries, func(a, b *diskEntry) int { return a.modTime.Compare(b.modTime) })
+	// 以实际文件为准重新统计, 修正其他进程写入或删除造成的偏差
+	s.size = 0
+	for _, e := range entries {
+		s.size += e.size
+	}
+	for _, e := range entries {
+		if s.size <= s.maxBytes {
+			break
+		}
+		if err = os.Remove(e.path); err == nil || errors.Is(err, fs.ErrNotExist) {
+			s.size -= e.size
+		}
+	}
+	return nil
+}
+
+// path key对应的文件路径, key只能是文件名
+func (s *DiskStore) path(key string) (string, error) {
+	if key == "" || key[0] == '.' || filepath.Base(key) != key {
+		return "", fmt.Errorf("invalid cache key %q", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+var _ Store = (*RedisStore)(nil)
+
+// RedisStore redis缓存, 内容存储在prefix+key中, 有序集合prefix+index按最近使用时间记录所有key
+// 条数超过上限时删除最久未使用的内容
+type RedisStore struct {
+	rs         *redis.Redis
+	prefix     string
+	maxEntries int
+}
+
+// NewRedisStore 创建redis缓存
+func NewRedisStore(rs *redis.Redis, prefix string, maxEntries int) *RedisStore {
+	return &RedisStore{rs: rs, prefix: prefix, maxEntries: maxEntries}
+}
+
+// Get 读取内容并更新最近使用时间
+func (s *RedisStore) Get(key string) ([]byte, error) {
+	value, err := s.rs.Get(s.prefix + key)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, ErrNotFound
+	}
+	_, _ = s.rs.Zadd(s.index(), time.Now().UnixMilli(), key)
+	return []byte(value), nil
+}
+
+// Set 写入内容, 超过条数上限时淘汰最久未使用的内容
+func (s *RedisStore) Set(key string, value []byte) error {
+	if err := s.rs.Set(s.prefix+key, string(value)); err != nil {
+		return err
+	}
+	if _, err := s.rs.Zadd(s.index(), time.Now().UnixMilli(), key); err != nil {
+		return err
+	}
+	n, err := s.rs.Zcard(s.index())
+	if err != nil || n <= s.maxEntries {
+		return err
+	}
+	stop := int64(n - s.maxEntries - 1)
+	keys, err := s.rs.Zrange(s.index(), 0, stop)
+	if err != nil {
+		return err
+	}
+	for i := range keys {
+		keys[i] = s.prefix + keys[i]
+	}
+	if _, err = s.rs.Del(keys...); err != nil {
+		return err
+	}
+	_, err = s.rs.Zremrangebyrank(s.index(), 0, stop)
+	return err
+}
+
+// index 记录最近使用时间的有序集合
+func (s *RedisStore) index() string {
+	return s.prefix + "index"
+}
@@ -0,0 +1,14 @@
+package cache
+
+import "errors"
+
+// ErrNotFound 缓存中没有对应的内容
+var ErrNotFound = errors.New("cache not found")
+
+// Store 内容寻址的二进制缓存, key由调用方根据内容计算, 超过容量后淘汰最久未使用的内容
+type Store interface {
+	// Get 获取key对应的内容, 不存在时返回ErrNotFound
+	Get(key string) ([]byte, error)
+	// Set 写入key对应的内容, 已存在时覆盖
+	Set(key string, value []byte) error
+}
@@ -17,7 +17,8 @@ type SMTP struct {
 	Password string
 	Host     string
 	Port     int
-	Alert    string
+	// Alert 旧版的预警收件邮箱, 没有配置Alert.Fallback时作为兜底收件人
+	Alert string `json:",optional"`
 }
 type Config struct {
 	service.ServiceConf
@@ -32,10 +33,26 @@ type Config struct {
 	Redis         *redis.RedisConf
 	RabbitMQ      RabbitMQ
 	SMTP          SMTP
+	Chat          Chat `json:",optional"`
 	BaiLianChat   BaiLianChat
+	OpenAIChat    OpenAIChat `json:",optional"`
 	BaiLianReport BaiLianReport
+	BaiLianTrend  BaiLianTrend `json:",optional"`
 	VolcTts       VolcTts
+	Tts           Tts       `json:",optional"`
+	OpenAITts     OpenAITts `json:",optional"`
+	FakeTts       FakeTts   `json:",optional"`
+	TtsCache      TtsCache  `json:",optional"`
 	VolcAsr       VolcAsr
+	Asr           Asr        `json:",optional"`
+	WhisperAsr    WhisperAsr `json:",optional"`
+	FakeAsr       FakeAsr    `json:",optional"`
+	Vad           Vad        `json:",optional"`
+	Timeout       Timeout    `json:",optional"`
+	Risk          Risk       `json:",optional"`
+	Alert         Alert      `json:",optional"`
+	Dashboard     Dashboard  `json:",optional"`
+	Export        Export     `json:",optional"`
 }
 
 type Auth struct {
@@ -48,16 +65,62 @@ type RabbitMQ struct {
 	Url string
 }
 
+// Chat 对话模型配置
+type Chat struct {
+	// Provider 对话模型供应商, bailian / openai, 为空时使用bailian
+	Provider string `json:",optional"`
+	// MaxTurns 发送给模型的最大对话轮数, 为0时使用默认值20
+	MaxTurns int `json:",optional"`
+	// MaxTokens 发送给模型的上下文token预算(估算值), 为0时使用默认值4000
+	MaxTokens int `json:",optional"`
+	// Styles 情感标记对应的语音风格, 覆盖同名的默认风格
+	Styles []VoiceStyle `json:",optional"`
+	// PinyinDict 生成口型用的拼音词典路径, 每行一个字, 如"中 zhong1", 为空时汉字使用通用口型
+	PinyinDict string `json:",optional"`
+	// ResumeGrace 连接断开后保留对话等待重连的时长, 单位秒, 为0时使用默认值60
+	ResumeGrace int `json:",optional"`
+	// ResumeBuffer 等待重连期间缓存的消息大小上限, 单位字节, 为0时使用默认值4MB
+	ResumeBuffer int `json:",optional"`
+}
+
+// VoiceStyle 一种情感对应的语音合成参数
+type VoiceStyle struct {
+	// Tag 模型输出的<emotion>标记内容, 如happy
+	Tag string
+	// Emotion 语音合成的情感, 取值取决于音色支持的情感, 为空时不指定
+	Emotion string `json:",optional"`
+	// Rate 语速, [-50, 100], 0为正常语速
+	Rate int32 `json:",optional"`
+	// Volume 音量, [-50, 100], 0为正常音量
+	Volume int32 `json:",optional"`
+}
+
 type BaiLianChat struct {
 	AppId  string
 	ApiKey string
 }
 
+// OpenAIChat 兼容OpenAI chat/completions协议的对话模型
+type OpenAIChat struct {
+	// Url 接口地址前缀, 如 https://api.openai.com/v1
+	Url    string
+	ApiKey string
+	Model  string
+	// Prompt 系统提示词, 应约定用<emotion>/<action>/<risk>标记表情、动作和风险, 标记外的内容会被合成为语音
+	Prompt string `json:",optional"`
+}
+
 type BaiLianReport struct {
 	AppId  string
 	ApiKey string
 }
 
+// BaiLianTrend 学生心理状态趋势分析应用, 输出格式与报告分析相同, 未配置时使用报告分析应用
+type BaiLianTrend struct {
+	AppId  string `json:",optional"`
+	ApiKey string `json:",optional"`
+}
+
 type VolcTts struct {
 	Url        string
 	AppKey     string
@@ -66,6 +129,45 @@ type VolcTts struct {
 	ResourceId string
 }
 
+// Tts 语音合成配置
+type Tts struct {
+	// Provider 语音合成供应商, volc / openai / fake, 为空时使用volc
+	Provider string `json:",optional"`
+	// Speakers 前端可以选择的音色, 为空时只能使用供应商配置的默认音色
+	Speakers []string `json:",optional"`
+}
+
+// OpenAITts 兼容OpenAI audio/speech协议的语音合成, 逐句请求
+type OpenAITts struct {
+	// Url 接口地址前缀, 如 https://api.openai.com/v1
+	Url    string `json:",optional"`
+	ApiKey string `json:",optional"`
+	// Model 模型名称, 为空时使用tts-1
+	Model string `json:",optional"`
+	// Voice 默认音色, 为空时使用alloy
+	Voice string `json:",optional"`
+}
+
+// FakeTts 生成静音的语音合成, 用于本地调试和测试
+type FakeTts struct {
+	// CharMs 每个字的时长, 单位毫秒, 为0时使用默认值200
+	CharMs int `json:",optional"`
+}
+
+// TtsCache 常用语句的合成音频缓存, 命中的句子不再请求语音合成
+type TtsCache struct {
+	// Store 存储位置, redis / disk, 为空时不缓存
+	Store string `json:",optional"`
+	// Dir disk存储的目录
+	Dir string `json:",optional"`
+	// MaxBytes disk存储的总大小上限, 为0时使用默认值64MB
+	MaxBytes int64 `json:",optional"`
+	// MaxEntries redis存储的最大条数, 为0时使用默认值2000
+	MaxEntries int `json:",optional"`
+	// MaxChars 可以缓存的句子的最大字数, 更长的句子很少重复, 为0时使用默认值20
+	MaxChars int `json:",optional"`
+}
+
 type VolcAsr struct {
 	Url        string
 	AppKey     string
@@ -73,6 +175,153 @@ type VolcAsr struct {
 	ResourceId string
 }
 
+// Asr 语音识别配置
+type Asr struct {
+	// Provider 语音识别供应商, volc / whisper / fake, 为空时使用volc
+	Provider string `json:",optional"`
+}
+
+// WhisperAsr 兼容OpenAI audio/transcriptions协议的语音识别, 本地按静音断句后整句识别
+type WhisperAsr struct {
+	// Url 接口地址前缀, 如 https://api.openai.com/v1
+	Url    string `json:",optional"`
+	ApiKey string `json:",optional"`
+	// Model 模型名称, 为空时使用whisper-1
+	Model string `json:",optional"`
+	// Language 音频的语言, 如zh, 为空时由模型判断
+	Language string `json:",optional"`
+	// Partial 说话过程中识别中间结果的间隔, 单位毫秒, 为0时只返回最终结果
+	Partial int `json:",optional"`
+}
+
+// FakeAsr 按顺序回放转写文件的语音识别, 用于本地调试和测试
+type FakeAsr struct {
+	// Transcript 转写文件, 每行一条结果, 以~开头的行为中间结果, 其余为一句话的最终结果
+	Transcript string `json:",optional"`
+	// Every 每收到多少毫秒的音频返回一条结果, 为0时使用默认值1000
+	Every int `json:",optional"`
+}
+
+// Timeout 对话和语音识别连接的超时配置, 时长单位均为秒, 为0时使用默认值, 为负数时不限制
+type Timeout struct {
+	// Idle 学生没有输入且AI没有输出的最长时长, 默认300
+	Idle int `json:",optional"`
+	// Heartbeat 没有收到任何消息(包括心跳)的最长时长, 超过后视为连接断开, 默认不限制
+	// 旧版前端不发送心跳, 只在所有前端都定时发送ping后开启, 且应大于前端的心跳间隔
+	Heartbeat int `json:",optional"`
+	// MaxDuration 一次对话的最长时长, 默认3600
+	MaxDuration int `json:",optional"`
+	// IdleGoodbye 空闲超时后数字人的告别语, 为空时使用默认告别语
+	IdleGoodbye string `json:",optional"`
+	// MaxGoodbye 对话时长超过上限后数字人的告别语, 为空时使用默认告别语
+	MaxGoodbye string `json:",optional"`
+}
+
+// Vad 语音活动检测和断句配置, 时长单位均为毫秒
+type Vad struct {
+	// Silence 说话后静音超过该时长认为一句话结束, 为0时使用默认值800
+	Silence int `json:",optional"`
+	// MinSpeech 有声超过该时长才认为开始说话, 避免噪声误触发, 为0时使用默认值100
+	MinSpeech int `json:",optional"`
+	// Threshold 有声帧的最低均方根能量(16位PCM), 为0时使用默认值300, 实际阈值会随环境噪声提高
+	Threshold int `json:",optional"`
+	// Grace 检测到一句话结束后等待识别结果的时长, 超时后以最近的识别结果作为最终结果, 为0时使用默认值500
+	Grace int `json:",optional"`
+}
+
+// Risk 风险检测配置
+type Risk struct {
+	// Sentinel 旧提示词中表示高风险的标记, 为空时使用&; 新提示词应使用<risk>标记
+	Sentinel string `json:",optional"`
+	// Lexicon 风险词库
+	Lexicon []RiskRule `json:",optional"`
+	// Classifier 调用第二个大模型进行风险分类
+	Classifier RiskClassifier `json:",optional"`
+}
+
+// RiskRule 一条风险词规则
+type RiskRule struct {
+	// Pattern 正则表达式, 普通关键词直接填写即可
+	Pattern string
+	// Level 风险等级, low / medium / high
+	Level string
+	// Source 检测的文本来源, user / ai, 为空时都检测
+	Source string `json:",optional"`
+}
+
+// RiskClassifier 兼容OpenAI chat/completions协议的风险分类模型
+type RiskClassifier struct {
+	Enable bool   `json:",optional"`
+	Url    string `json:",optional"`
+	ApiKey string `json:",optional"`
+	Model  string `json:",optional"`
+	// Prompt 分类提示词, 为空时使用默认提示词
+	Prompt string `json:",optional"`
+	// Source 检测的文本来源, user / ai, 为空时只检测用户输入
+	Source string `json:",optional"`
+}
+
+// Alert 风险预警的投递配置
+type Alert struct {
+	// MinLevel 触发预警的最低风险等级, low / medium / high, 为空时为high
+	MinLevel string `json:",optional"`
+	// Routes 按单位路由预警, UnitId为空的路由在没有单位匹配时使用
+	// 没有配置任何路由时, 发送邮件到Fallback
+	Routes []AlertRoute `json:",optional"`
+	// Fallback 兜底的收件邮箱, 为空时使用SMTP.Alert
+	Fallback []string `json:",optional"`
+	// MaxRetry 投递失败后的最大重试次数, 为0时使用默认值5
+	MaxRetry int `json:",optional"`
+	// Subject / Text / Html 覆盖默认的模板, 使用text/template和html/template语法
+	Subject string `json:",optional"`
+	Text    string `json:",optional"`
+	Html    string `json:",optional"`
+}
+
+// AlertRoute 一个单位的预警接收方
+type AlertRoute struct {
+	UnitId   string `json:",optional"`
+	Channels []AlertChannel
+}
+
+// AlertChannel 预警投递渠道
+type AlertChannel struct {
+	// Type 渠道类型, smtp / webhook / dingtalk / wecom
+	Type string
+	// To 邮件收件人, 仅smtp使用
+	To []string `json:",optional"`
+	// Url webhook或机器人地址
+	Url string `json:",optional"`
+	// Secret 钉钉机器人的加签密钥
+	Secret string `json:",optional"`
+}
+
+// Dashboard 统计看板配置
+type Dashboard struct {
+	// MinGroupSize 分组中的学生数少于该值时不展示统计数据, 避免识别到个人, 为0时使用默认值5
+	MinGroupSize int `json:",optional"`
+	// Timezone 按周/月分组使用的时区, 为空时使用Asia/Shanghai
+	Timezone string `json:",optional"`
+}
+
+// Export 导出配置
+type Export struct {
+	// Font 生成PDF使用的TTF字体路径, 需要包含中文字形, 如NotoSansSC
+	Font string `json:",optional"`
+	// Brands 各单位的PDF抬头, UnitId为空的作为默认抬头
+	Brands []ExportBrand `json:",optional"`
+	// MaxRows 单次导出的最大记录数, 为0时使用默认值10000
+	MaxRows int `json:",optional"`
+}
+
+// ExportBrand 单位的PDF抬头
+type ExportBrand struct {
+	UnitId string `json:",optional"`
+	Name   string
+	// Logo 图片路径, 支持png和jpg
+	Logo string `json:",optional"`
+}
+
 func NewConfig() (*Config, error) {
 	c := new(Config)
 	path := os.Getenv("CONFIG_PATH")
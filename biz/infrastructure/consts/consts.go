@@ -2,8 +2,27 @@ package consts
 
 // 数据库相关
 const (
+	ID         = "_id"
 	CreateTime = "create_time"
+	UpdateTime = "update_time"
 	StartTime  = "start_time"
+	UnitId     = "unit_id"
+	Class      = "class"
+	Level      = "level"
+	Handled    = "handled"
+	HandlerId  = "handler_id"
+	HandleTime = "handle_time"
+	Notes      = "notes"
+)
+
+// 对话记录字段
+const (
+	StudentId      = "studentId"
+	Name           = "name"
+	ReportGrade    = "report.grade"
+	ReportType     = "report.type"
+	ReportKeywords = "report.keywords"
+	DialogContent  = "dialogs.content"
 )
 
 // Post http
@@ -11,8 +30,83 @@ const (
 	Post = "POST"
 )
 
+// 模型供应商
+const (
+	ProviderBaiLian = "bailian"
+	ProviderOpenAI  = "openai"
+	ProviderVolc    = "volc"
+	ProviderWhisper = "whisper"
+	ProviderFake    = "fake"
+)
+
 // 默认值
 const (
-	EndCmd = -1
-	Ping   = 1
+	EndCmd       = -1
+	Ping         = 1
+	InterruptCmd = 2
+)
+
+// 对话协议版本, 开始请求中协商
+const (
+	// ProtocolLegacy 旧协议, 文本消息直接发送json, 音频帧不带头部
+	ProtocolLegacy = 0
+	// ProtocolV1 文本消息使用带type的信封, 音频帧带有句子id和序号
+	ProtocolV1 = 1
+	// ProtocolLatest 服务端支持的最高版本
+	ProtocolLatest = ProtocolV1
+)
+
+// v1协议的消息类型
+const (
+	// MsgStart 前端的开始请求和服务端的开始响应
+	MsgStart = "start"
+	// MsgEnd 前端结束对话和服务端的结束响应
+	MsgEnd = "end"
+	// 前端发送
+	MsgInput     = "input"
+	MsgPing      = "ping"
+	MsgInterrupt = "interrupt"
+	// 服务端发送
+	MsgText     = "text"
+	MsgEvent    = "event"
+	MsgSubtitle = "subtitle"
+	MsgViseme   = "viseme"
+	MsgAsr      = "asr"
+	MsgPong     = "pong"
+	MsgError    = "error"
+)
+
+// v1协议二进制音频帧的类型
+const (
+	// AudioTts 服务端下发的合成语音
+	AudioTts = 1
+	// AudioMic 前端上传的麦克风音频
+	AudioMic = 2
+)
+
+// 对话模型上下文中的角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// 教职工角色
+const (
+	StaffAdmin      = "admin"
+	StaffCounsellor = "counsellor"
+	StaffTeacher    = "teacher"
+)
+
+// 统计周期
+const (
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// 对话结束原因
+const (
+	FinishInterrupted = "interrupted"
+	// FinishTimeout 空闲或对话时长超时后的告别语
+	FinishTimeout = "timeout"
 )
@@ -34,7 +34,19 @@ func NewErrno(code codes.Code, err error) *Errno {
 
 // 定义常量错误
 var (
-	ErrForbidden   = NewErrno(codes.PermissionDenied, errors.New("forbidden"))
-	ErrWsUpgrade   = NewErrno(codes.Code(1000), errors.New("websocket协议升级失败"))
-	ErrInvalidUser = NewErrno(codes.Code(1001), errors.New("非授权用户，请重试或切换账号"))
+	ErrForbidden     = NewErrno(codes.PermissionDenied, errors.New("forbidden"))
+	ErrUnauthorized  = NewErrno(codes.Unauthenticated, errors.New("unauthorized"))
+	ErrWsUpgrade     = NewErrno(codes.Code(1000), errors.New("websocket协议升级失败"))
+	ErrInvalidUser   = NewErrno(codes.Code(1001), errors.New("非授权用户，请重试或切换账号"))
+	ErrInvalidId     = NewErrno(codes.Code(1002), errors.New("无效的id"))
+	ErrNotFound      = NewErrno(codes.Code(1003), errors.New("记录不存在"))
+	ErrInvalidParam  = NewErrno(codes.Code(1004), errors.New("参数错误"))
+	ErrNoExportFont  = NewErrno(codes.Code(1005), errors.New("未配置导出字体, 无法生成PDF"))
+	ErrAudioFormat   = NewErrno(codes.Code(1006), errors.New("不支持的音频格式"))
+	ErrTtsFormat     = NewErrno(codes.Code(1007), errors.New("不支持的语音合成格式或采样频率"))
+	ErrTtsSpeaker    = NewErrno(codes.Code(1008), errors.New("不支持的音色"))
+	ErrResumeExpired = NewErrno(codes.Code(1009), errors.New("对话已结束或重连超时, 请重新开始对话"))
+	ErrTimeout       = NewErrno(codes.Code(1010), errors.New("连接超时, 已自动断开"))
+	ErrResumeMode    = NewErrno(codes.Code(1011), errors.New("对话模式与重连的接口不一致, 请连接开始对话时的接口"))
+	ErrAsrFormat     = NewErrno(codes.Code(1012), errors.New("当前语音识别服务不支持该音频格式, ogg/webm(opus)只能用于火山引擎, 请改用pcm或wav"))
 )
@@ -0,0 +1,89 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+	"github.com/xuri/excelize/v2"
+)
+
+func iter(rows [][]string) RowIter {
+	return func() ([]string, error) {
+		if len(rows) == 0 {
+			return nil, io.EOF
+		}
+		row := rows[0]
+		rows = rows[1:]
+		return row, nil
+	}
+}
+
+var (
+	header = []string{"姓名", "班级", "建议"}
+	rows   = [][]string{{"小明", "一班", "多运动,\n早睡"}, {"小红", "二班", ""}}
+)
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, header, iter(rows)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("\xef\xbb\xbf")) {
+		t.Error("missing BOM")
+	}
+	got, err := csv.NewReader(bytes.NewReader(buf.Bytes()[3:])).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0][0] != "姓名" || got[1][2] != "多运动,\n早睡" {
+		t.Errorf("csv = %q", got)
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteXLSX(&buf, "对话记录", header, iter(rows)); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.GetRows("对话记录")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0][1] != "班级" || got[2][0] != "小红" {
+		t.Errorf("xlsx = %q", got)
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	if err := WritePDF(io.Discard, "", &Document{}); err != consts.ErrNoExportFont {
+		t.Errorf("err = %v, want ErrNoExportFont", err)
+	}
+
+	font := "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+	if _, err := os.Stat(font); err != nil {
+		t.Skip("no ttf font available")
+	}
+	var buf bytes.Buffer
+	err := WritePDF(&buf, font, &Document{
+		Brand: Brand{Name: "Test School"},
+		Title: "Report",
+		Sections: []*Section{
+			{Heading: "Info", Fields: [][2]string{{"Name", "Ming"}}},
+			{Heading: "Dialog", Lines: []string{"user: hello", "ai: hi"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Errorf("not a pdf: %q", buf.Bytes()[:min(16, buf.Len())])
+	}
+}
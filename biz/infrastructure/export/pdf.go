@@ -0,0 +1,93 @@
+package export
+
+import (
+	"io"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// Brand PDF抬头的单位信息
+type Brand struct {
+	Name string
+	// Logo 图片路径, 支持png和jpg, 为空时不显示
+	Logo string
+}
+
+// Document 一份可打印的文档, 由若干小节组成
+type Document struct {
+	Brand    Brand
+	Title    string
+	Sections []*Section
+}
+
+// Section 文档的一个小节, Fields为键值对, Lines为段落
+type Section struct {
+	Heading string
+	Fields  [][2]string
+	Lines   []string
+}
+
+const (
+	fontFamily = "cjk"
+	lineHeight = 6.0
+)
+
+// WritePDF 将文档渲染为A4的PDF, font为包含中文字形的TTF字体路径
+func WritePDF(w io.Writer, font string, doc *Document) error {
+	if font == "" {
+		return consts.ErrNoExportFont
+	}
+	// gofpdf按字体目录拼接字体文件名
+	pdf := gofpdf.New("P", "mm", "A4", filepath.Dir(font))
+	pdf.AddUTF8Font(fontFamily, "", filepath.Base(font))
+	pdf.SetMargins(18, 18, 18)
+	pdf.SetAutoPageBreak(true, 18)
+	pdf.AliasNbPages("")
+	width, _ := pdf.GetPageSize()
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(fontFamily, "", 8)
+		pdf.CellFormat(width/2-18, 6, doc.Brand.Name, "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, strconv.Itoa(pdf.PageNo())+"/{nb}", "", 0, "R", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// 抬头: 单位logo和名称
+	if doc.Brand.Logo != "" {
+		pdf.ImageOptions(doc.Brand.Logo, 18, 14, 0, 14, false, gofpdf.ImageOptions{ReadDpi: true}, 0, "")
+		pdf.SetX(36)
+	}
+	pdf.SetFont(fontFamily, "", 12)
+	pdf.CellFormat(0, 14, doc.Brand.Name, "", 1, "L", false, 0, "")
+	pdf.Line(18, pdf.GetY(), width-18, pdf.GetY())
+	pdf.Ln(4)
+
+	pdf.SetFont(fontFamily, "", 16)
+	pdf.CellFormat(0, 10, doc.Title, "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	for _, s := range doc.Sections {
+		pdf.SetFont(fontFamily, "", 13)
+		pdf.SetFillColor(235, 240, 245)
+		pdf.CellFormat(0, 8, s.Heading, "", 1, "L", true, 0, "")
+		pdf.Ln(1)
+		pdf.SetFont(fontFamily, "", 10)
+		for _, f := range s.Fields {
+			pdf.CellFormat(28, lineHeight, f[0], "", 0, "L", false, 0, "")
+			pdf.MultiCell(0, lineHeight, f[1], "", "L", false)
+		}
+		for _, l := range s.Lines {
+			pdf.MultiCell(0, lineHeight, l, "", "L", false)
+			pdf.Ln(1)
+		}
+		pdf.Ln(3)
+	}
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
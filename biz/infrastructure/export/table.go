@@ -0,0 +1,81 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// RowIter 逐行产生表格数据, 没有更多数据时返回io.EOF
+type RowIter func() ([]string, error)
+
+// WriteCSV 以CSV格式写出表格, 文件头带BOM以便Excel正确识别中文
+func WriteCSV(w io.Writer, header []string, next RowIter) error {
+	if _, err := w.Write([]byte("\xef\xbb\xbf")); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteXLSX 以XLSX格式写出表格, 使用流式写入避免大量数据占用内存
+func WriteXLSX(w io.Writer, sheet string, header []string, next RowIter) error {
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+	if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+		return err
+	}
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+	if err = sw.SetRow("A1", cells(header)); err != nil {
+		return err
+	}
+	for i := 2; ; i++ {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		axis, err := excelize.CoordinatesToCellName(1, i)
+		if err != nil {
+			return err
+		}
+		if err = sw.SetRow(axis, cells(row)); err != nil {
+			return err
+		}
+	}
+	if err = sw.Flush(); err != nil {
+		return err
+	}
+	_, err = f.WriteTo(w)
+	return err
+}
+
+func cells(row []string) []any {
+	res := make([]any, len(row))
+	for i, v := range row {
+		res[i] = v
+	}
+	return res
+}
@@ -4,14 +4,16 @@ import "time"
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type History struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Name      string             `bson:"name" json:"name"`
-	Class     string             `bson:"class" json:"class"`
-	StudentId string             `bson:"studentId" json:"studentId"`
-	Dialogs   []*Dialog          `bson:"dialogs" json:"dialogs"`
-	Report    *Report            `bson:"report" json:"report"`
-	StartTime time.Time          `bson:"start_time" json:"start_time"`
-	EndTime   time.Time          `bson:"end_time" json:"end_time"`
+	ID primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	// UnitId 升级前的记录没有该字段, 按单位查询前根据学号补充
+	UnitId    string    `bson:"unit_id" json:"unit_id"`
+	Name      string    `bson:"name" json:"name"`
+	Class     string    `bson:"class" json:"class"`
+	StudentId string    `bson:"studentId" json:"studentId"`
+	Dialogs   []*Dialog `bson:"dialogs" json:"dialogs"`
+	Report    *Report   `bson:"report" json:"report"`
+	StartTime time.Time `bson:"start_time" json:"start_time"`
+	EndTime   time.Time `bson:"end_time" json:"end_time"`
 }
 
 type Dialog struct {
@@ -26,3 +28,14 @@ type Report struct {
 	Grade      string   `bson:"grade" json:"grade"`
 	Suggestion []string `bson:"suggestion" json:"suggestion"`
 }
+
+// Group 按班级和统计周期分组的对话记录
+type Group struct {
+	Class    string     `bson:"class"`
+	Period   string     `bson:"period"`
+	Sessions int64      `bson:"sessions"`
+	Students []string   `bson:"students"`
+	Grades   []string   `bson:"grades"`
+	Types    [][]string `bson:"types"`
+	Keywords [][]string `bson:"keywords"`
+}
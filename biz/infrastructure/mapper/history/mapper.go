@@ -1,6 +1,12 @@
 package history
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-digital/biz/adaptor/cmd"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
@@ -8,6 +14,7 @@ import (
 	"github.com/zeromicro/go-zero/core/stores/monc"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/net/context"
 	"sync"
@@ -23,7 +30,32 @@ var once sync.Once
 
 type IMongoMapper interface {
 	Insert(ctx context.Context, his History) error
-	FindMany(ctx context.Context, p *cmd.Paging) (data []*History, total int64, err error)
+	FindOne(ctx context.Context, id string) (*History, error)
+	FindMany(ctx context.Context, fopts *FilterOptions, p *cmd.Paging) (data []*History, total int64, err error)
+	FindAfter(ctx context.Context, fopts *FilterOptions, cursor *Cursor, limit int64) ([]*History, error)
+	Aggregate(ctx context.Context, fopts *FilterOptions, period, timezone string) ([]*Group, error)
+	HasLegacy(ctx context.Context) (bool, error)
+	Backfill(ctx context.Context, unitId string, studentIds []string) (int64, error)
+}
+
+// FilterOptions 对话记录的筛选条件, 为nil的条件不生效
+type FilterOptions struct {
+	UnitId *string
+	// Classes 为nil时不限制班级, 为空切片时不匹配任何记录
+	Classes   []string
+	StudentId *string
+	// Name 姓名模糊匹配
+	Name *string
+	// StartTime / EndTime 对话开始时间的范围
+	StartTime *time.Time
+	EndTime   *time.Time
+	Grade     *string
+	Type      *string
+	// Search 在对话内容和报告关键词中做子串匹配
+	// 中文没有分词, mongo的文本索引无法匹配句子中的词语, 所以使用正则
+	Search *string
+	// SearchDialogs 为false时只搜索报告关键词
+	SearchDialogs bool
 }
 
 type MongoMapper struct {
@@ -32,16 +64,34 @@ type MongoMapper struct {
 
 func NewMongoMapper(config *config.Config) *MongoMapper {
 	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.Cache)
-	return &MongoMapper{conn: conn}
+	m := &MongoMapper{conn: conn}
+	m.ensureIndexes()
+	return m
+}
+
+// ensureIndexes 创建查询用到的索引, 所有查询都限定了单位并按开始时间倒序
+func (m *MongoMapper) ensureIndexes() {
+	keys := []bson.D{
+		{{Key: consts.UnitId, Value: 1}, {Key: consts.StartTime, Value: -1}},
+		{{Key: consts.UnitId, Value: 1}, {Key: consts.Class, Value: 1}, {Key: consts.StartTime, Value: -1}},
+		{{Key: consts.UnitId, Value: 1}, {Key: consts.StudentId, Value: 1}, {Key: consts.StartTime, Value: -1}},
+		{{Key: consts.UnitId, Value: 1}, {Key: consts.ReportGrade, Value: 1}, {Key: consts.StartTime, Value: -1}},
+		{{Key: consts.UnitId, Value: 1}, {Key: consts.ReportType, Value: 1}, {Key: consts.StartTime, Value: -1}},
+		{{Key: consts.UnitId, Value: 1}, {Key: consts.ReportKeywords, Value: 1}},
+	}
+	models := make([]mongo.IndexModel, 0, len(keys))
+	for _, k := range keys {
+		models = append(models, mongo.IndexModel{Keys: k})
+	}
+	if _, err := m.conn.Indexes().CreateMany(context.Background(), models); err != nil {
+		log.Error("create history indexes err:", err)
+	}
 }
 
+// GetMongoMapper 获取单例, 与NewMongoMapper相同会创建索引
 func GetMongoMapper() *MongoMapper {
 	once.Do(func() {
-		c := config.GetConfig()
-		conn := monc.MustNewModel(c.Mongo.URL, c.Mongo.DB, CollectionName, c.Cache)
-		Mapper = &MongoMapper{
-			conn: conn,
-		}
+		Mapper = NewMongoMapper(config.GetConfig())
 	})
 	return Mapper
 }
@@ -54,11 +104,29 @@ func (m *MongoMapper) Insert(ctx context.Context, his *History) error {
 	return err
 }
 
-func (m *MongoMapper) FindMany(ctx context.Context, p *cmd.Paging) (data []*History, total int64, err error) {
+func (m *MongoMapper) FindOne(ctx context.Context, id string) (*History, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, consts.ErrInvalidId
+	}
+	var his History
+	err = m.conn.FindOneNoCache(ctx, &his, bson.M{consts.ID: oid})
+	switch {
+	case err == nil:
+		return &his, nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return nil, consts.ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *MongoMapper) FindMany(ctx context.Context, fopts *FilterOptions, p *cmd.Paging) (data []*History, total int64, err error) {
 	skip, limit := util.ParsePaging(p)
+	filter := makeFilter(fopts)
 	data = make([]*History, 0, limit)
 	err = m.conn.Find(ctx, &data,
-		bson.M{}, &options.FindOptions{
+		filter, &options.FindOptions{
 			Skip:  &skip,
 			Limit: &limit,
 			Sort:  bson.M{consts.StartTime: -1},
@@ -67,9 +135,160 @@ func (m *MongoMapper) FindMany(ctx context.Context, p *cmd.Paging) (data []*Hist
 		return nil, 0, err
 	}
 
-	total, err = m.conn.CountDocuments(ctx, bson.M{})
+	total, err = m.conn.CountDocuments(ctx, filter)
 	if err != nil {
 		return nil, 0, err
 	}
 	return data, total, nil
 }
+
+// legacyFilter 升级前写入的记录没有unit_id, 按单位筛选时无法匹配
+func legacyFilter() bson.M {
+	return bson.M{consts.UnitId: bson.M{"$in": bson.A{nil, ""}}}
+}
+
+// HasLegacy 是否还有缺少unit_id的记录
+func (m *MongoMapper) HasLegacy(ctx context.Context) (bool, error) {
+	n, err := m.conn.CountDocuments(ctx, legacyFilter(), options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+// Backfill 为缺少unit_id且学号在studentIds中的记录补充单位, 返回更新的记录数
+func (m *MongoMapper) Backfill(ctx context.Context, unitId string, studentIds []string) (int64, error) {
+	if len(studentIds) == 0 {
+		return 0, nil
+	}
+	filter := legacyFilter()
+	filter[consts.StudentId] = bson.M{"$in": studentIds}
+	res, err := m.conn.UpdateManyNoCache(ctx, filter, bson.M{"$set": bson.M{consts.UnitId: unitId}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// Cursor 按开始时间倒序遍历时, 上一批最后一条记录的位置
+type Cursor struct {
+	StartTime time.Time
+	ID        primitive.ObjectID
+}
+
+// FindAfter 按开始时间和id倒序读取cursor之后的最多limit条记录, cursor为nil时从第一条开始
+// 与按页读取不同, 遍历期间新增或删除的记录不会导致重复或遗漏
+func (m *MongoMapper) FindAfter(ctx context.Context, fopts *FilterOptions, cursor *Cursor, limit int64) ([]*History, error) {
+	data := make([]*History, 0, limit)
+	err := m.conn.Find(ctx, &data, afterFilter(makeFilter(fopts), cursor), &options.FindOptions{
+		Limit: &limit,
+		Sort:  bson.D{{Key: consts.StartTime, Value: -1}, {Key: consts.ID, Value: -1}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// afterFilter 在筛选条件上增加cursor之后的限制
+func afterFilter(filter bson.M, cursor *Cursor) bson.M {
+	if cursor == nil {
+		return filter
+	}
+	return bson.M{"$and": bson.A{filter, bson.M{"$or": bson.A{
+		bson.M{consts.StartTime: bson.M{"$lt": cursor.StartTime}},
+		bson.M{consts.StartTime: cursor.StartTime, consts.ID: bson.M{"$lt": cursor.ID}},
+	}}}}
+}
+
+func makeFilter(fopts *FilterOptions) bson.M {
+	filter := bson.M{}
+	if fopts == nil {
+		return filter
+	}
+	if fopts.UnitId != nil {
+		filter[consts.UnitId] = *fopts.UnitId
+	}
+	if fopts.Classes != nil {
+		filter[consts.Class] = bson.M{"$in": fopts.Classes}
+	}
+	if fopts.StudentId != nil {
+		filter[consts.StudentId] = *fopts.StudentId
+	}
+	if fopts.Name != nil {
+		filter[consts.Name] = primitive.Regex{Pattern: regexp.QuoteMeta(*fopts.Name)}
+	}
+	if fopts.StartTime != nil || fopts.EndTime != nil {
+		period := bson.M{}
+		if fopts.StartTime != nil {
+			period["$gte"] = *fopts.StartTime
+		}
+		if fopts.EndTime != nil {
+			period["$lte"] = *fopts.EndTime
+		}
+		filter[consts.StartTime] = period
+	}
+	if fopts.Grade != nil {
+		filter[consts.ReportGrade] = *fopts.Grade
+	}
+	if fopts.Type != nil {
+		filter[consts.ReportType] = *fopts.Type
+	}
+	if fopts.Search != nil && *fopts.Search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(*fopts.Search)}
+		if fopts.SearchDialogs {
+			filter["$or"] = bson.A{
+				bson.M{consts.DialogContent: pattern},
+				bson.M{consts.ReportKeywords: pattern},
+			}
+		} else {
+			filter[consts.ReportKeywords] = pattern
+		}
+	}
+	return filter
+}
+
+// periodFormat 统计周期对应的日期格式, 周使用ISO周数
+var periodFormat = map[string]string{
+	consts.PeriodWeek:  "%G-W%V",
+	consts.PeriodMonth: "%Y-%m",
+}
+
+// Aggregate 按班级和统计周期分组, 返回每组的对话数、学生和报告字段, 由调用方进一步统计
+func (m *MongoMapper) Aggregate(ctx context.Context, fopts *FilterOptions, period, timezone string) ([]*Group, error) {
+	format, ok := periodFormat[period]
+	if !ok {
+		return nil, fmt.Errorf("invalid period: %s", period)
+	}
+	pipeline := bson.A{
+		bson.M{"$match": makeFilter(fopts)},
+		bson.M{"$group": bson.M{
+			consts.ID: bson.M{
+				"class": "$" + consts.Class,
+				"period": bson.M{"$dateToString": bson.M{
+					"format":   format,
+					"date":     "$" + consts.StartTime,
+					"timezone": timezone,
+				}},
+			},
+			"sessions": bson.M{"$sum": 1},
+			"students": bson.M{"$addToSet": "$" + consts.StudentId},
+			"grades":   bson.M{"$push": "$" + consts.ReportGrade},
+			"types":    bson.M{"$push": "$" + consts.ReportType},
+			"keywords": bson.M{"$push": "$" + consts.ReportKeywords},
+		}},
+		bson.M{"$project": bson.M{
+			consts.ID:  0,
+			"class":    "$_id.class",
+			"period":   "$_id.period",
+			"sessions": 1,
+			"students": 1,
+			"grades":   1,
+			"types":    1,
+			"keywords": 1,
+		}},
+		bson.M{"$sort": bson.D{{Key: "period", Value: 1}, {Key: "class", Value: 1}}},
+	}
+	var groups []*Group
+	if err := m.conn.Aggregate(ctx, &groups, pipeline); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
@@ -0,0 +1,70 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMakeFilter(t *testing.T) {
+	unit, name, search := "u1", "小.明", "失眠"
+	start := time.Unix(100, 0)
+	filter := makeFilter(&FilterOptions{
+		UnitId:    &unit,
+		Classes:   []string{},
+		Name:      &name,
+		StartTime: &start,
+		Search:    &search,
+	})
+
+	if filter["unit_id"] != "u1" {
+		t.Errorf("unit_id = %v", filter["unit_id"])
+	}
+	if in := filter["class"].(bson.M)["$in"].([]string); len(in) != 0 {
+		t.Errorf("class = %v, want empty $in", in)
+	}
+	if re := filter["name"].(primitive.Regex); re.Pattern != `小\.明` {
+		t.Errorf("name pattern = %q", re.Pattern)
+	}
+	if period := filter["start_time"].(bson.M); period["$gte"] != start || period["$lte"] != nil {
+		t.Errorf("start_time = %v", period)
+	}
+	if _, ok := filter["$or"]; ok {
+		t.Error("dialogs should not be searched")
+	}
+	if re := filter["report.keywords"].(primitive.Regex); re.Pattern != "失眠" {
+		t.Errorf("keywords pattern = %q", re.Pattern)
+	}
+
+	filter = makeFilter(&FilterOptions{Search: &search, SearchDialogs: true})
+	if or := filter["$or"].(bson.A); len(or) != 2 {
+		t.Errorf("$or = %v", or)
+	}
+	if len(makeFilter(nil)) != 0 {
+		t.Error("nil options should match all")
+	}
+}
+
+func TestAfterFilter(t *testing.T) {
+	unit := "u1"
+	filter := makeFilter(&FilterOptions{UnitId: &unit})
+	if got := afterFilter(filter, nil); got["unit_id"] != "u1" {
+		t.Errorf("filter without cursor = %v", got)
+	}
+
+	id := primitive.NewObjectID()
+	start := time.Unix(100, 0)
+	and := afterFilter(filter, &Cursor{StartTime: start, ID: id})["$and"].(bson.A)
+	if and[0].(bson.M)["unit_id"] != "u1" {
+		t.Errorf("$and[0] = %v", and[0])
+	}
+	or := and[1].(bson.M)["$or"].(bson.A)
+	if or[0].(bson.M)["start_time"].(bson.M)["$lt"] != start {
+		t.Errorf("$or[0] = %v", or[0])
+	}
+	if tie := or[1].(bson.M); tie["start_time"] != start || tie["_id"].(bson.M)["$lt"] != id {
+		t.Errorf("$or[1] = %v", tie)
+	}
+}
@@ -0,0 +1,188 @@
+package risk
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-digital/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/util"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/context"
+)
+
+const (
+	CollectionName = "risk_event"
+)
+
+var Mapper *MongoMapper
+var once sync.Once
+
+type IMongoMapper interface {
+	Insert(ctx context.Context, event *RiskEvent) error
+	FindOne(ctx context.Context, id string) (*RiskEvent, error)
+	FindMany(ctx context.Context, fopts *FilterOptions, p *cmd.Paging) (data []*RiskEvent, total int64, err error)
+	Ack(ctx context.Context, id, handlerId string) error
+	AddNote(ctx context.Context, id string, note *Note) error
+}
+
+// FilterOptions 风险事件的筛选条件, 为nil的条件不生效
+type FilterOptions struct {
+	UnitId *string
+	// Classes 为nil时不限制班级, 为空切片时不匹配任何记录
+	Classes []string
+	Level   *string
+	Handled *bool
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper(config *config.Config) *MongoMapper {
+	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.Cache)
+	m := &MongoMapper{conn: conn}
+	m.ensureIndexes()
+	return m
+}
+
+// ensureIndexes 创建查询用到的索引, 所有查询都限定了单位并按创建时间倒序
+func (m *MongoMapper) ensureIndexes() {
+	keys := []bson.D{
+		{{Key: consts.UnitId, Value: 1}, {Key: consts.CreateTime, Value: -1}},
+		{{Key: consts.UnitId, Value: 1}, {Key: consts.Class, Value: 1}, {Key: consts.CreateTime, Value: -1}},
+		{{Key: consts.UnitId, Value: 1}, {Key: consts.Level, Value: 1}, {Key: consts.CreateTime, Value: -1}},
+		{{Key: consts.UnitId, Value: 1}, {Key: consts.Handled, Value: 1}, {Key: consts.CreateTime, Value: -1}},
+	}
+	models := make([]mongo.IndexModel, 0, len(keys))
+	for _, k := range keys {
+		models = append(models, mongo.IndexModel{Keys: k})
+	}
+	if _, err := m.conn.Indexes().CreateMany(context.Background(), models); err != nil {
+		log.Error("create risk indexes err:", err)
+	}
+}
+
+func GetMongoMapper() *MongoMapper {
+	once.Do(func() {
+		Mapper = NewMongoMapper(config.GetConfig())
+	})
+	return Mapper
+}
+
+func (m *MongoMapper) Insert(ctx context.Context, event *RiskEvent) error {
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	if event.Notes == nil {
+		event.Notes = []*Note{}
+	}
+	event.UpdateTime = time.Now()
+	_, err := m.conn.InsertOneNoCache(ctx, event)
+	return err
+}
+
+func (m *MongoMapper) FindOne(ctx context.Context, id string) (*RiskEvent, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, consts.ErrInvalidId
+	}
+	var event RiskEvent
+	err = m.conn.FindOneNoCache(ctx, &event, bson.M{consts.ID: oid})
+	switch {
+	case err == nil:
+		return &event, nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return nil, consts.ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+func (m *MongoMapper) FindMany(ctx context.Context, fopts *FilterOptions, p *cmd.Paging) (data []*RiskEvent, total int64, err error) {
+	skip, limit := util.ParsePaging(p)
+	filter := makeFilter(fopts)
+	data = make([]*RiskEvent, 0, limit)
+	err = m.conn.Find(ctx, &data, filter, &options.FindOptions{
+		Skip:  &skip,
+		Limit: &limit,
+		Sort:  bson.M{consts.CreateTime: -1},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err = m.conn.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, total, nil
+}
+
+// Ack 标记事件已处理, 记录处理人和处理时间
+func (m *MongoMapper) Ack(ctx context.Context, id, handlerId string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return consts.ErrInvalidId
+	}
+	now := time.Now()
+	res, err := m.conn.UpdateOneNoCache(ctx, bson.M{consts.ID: oid}, bson.M{"$set": bson.M{
+		consts.Handled:    true,
+		consts.HandlerId:  handlerId,
+		consts.HandleTime: now,
+		consts.UpdateTime: now,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return consts.ErrNotFound
+	}
+	return nil
+}
+
+// AddNote 追加一条跟进记录
+func (m *MongoMapper) AddNote(ctx context.Context, id string, note *Note) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return consts.ErrInvalidId
+	}
+	res, err := m.conn.UpdateOneNoCache(ctx, bson.M{consts.ID: oid}, bson.M{
+		"$push": bson.M{consts.Notes: note},
+		"$set":  bson.M{consts.UpdateTime: time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return consts.ErrNotFound
+	}
+	return nil
+}
+
+func makeFilter(fopts *FilterOptions) bson.M {
+	filter := bson.M{}
+	if fopts == nil {
+		return filter
+	}
+	if fopts.UnitId != nil {
+		filter[consts.UnitId] = *fopts.UnitId
+	}
+	if fopts.Classes != nil {
+		filter[consts.Class] = bson.M{"$in": fopts.Classes}
+	}
+	if fopts.Level != nil {
+		filter[consts.Level] = *fopts.Level
+	}
+	if fopts.Handled != nil {
+		filter[consts.Handled] = *fopts.Handled
+	}
+	return filter
+}
@@ -0,0 +1,38 @@
+package risk
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RiskEvent 风险事件记录, 同时记录老师的处理情况, 作为审计依据
+type RiskEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SessionId string             `bson:"session_id" json:"session_id"`
+	UserId    string             `bson:"user_id" json:"user_id"`
+	UnitId    string             `bson:"unit_id" json:"unit_id"`
+	StudentId string             `bson:"student_id" json:"student_id"`
+	Name      string             `bson:"name" json:"name"`
+	Class     string             `bson:"class" json:"class"`
+	Level     string             `bson:"level" json:"level"`
+	Source    string             `bson:"source" json:"source"`
+	Detector  string             `bson:"detector" json:"detector"`
+	Evidence  []string           `bson:"evidence" json:"evidence"`
+	Text      string             `bson:"text" json:"text"`
+	// Handled 是否已有老师确认处理
+	Handled    bool      `bson:"handled" json:"handled"`
+	HandlerId  string    `bson:"handler_id,omitempty" json:"handler_id,omitempty"`
+	HandleTime time.Time `bson:"handle_time,omitempty" json:"handle_time,omitempty"`
+	// Notes 后续跟进记录
+	Notes      []*Note   `bson:"notes" json:"notes"`
+	CreateTime time.Time `bson:"create_time" json:"create_time"`
+	UpdateTime time.Time `bson:"update_time" json:"update_time"`
+}
+
+// Note 一条跟进记录
+type Note struct {
+	UserId     string    `bson:"user_id" json:"user_id"`
+	Content    string    `bson:"content" json:"content"`
+	CreateTime time.Time `bson:"create_time" json:"create_time"`
+}
@@ -145,6 +145,7 @@ func (c *HistoryConsumer) process(ctx context.Context, msg amqp.Delivery) error
 		return err
 	}
 	his := &history.History{
+		UnitId:    unitId,
 		Name:      res.User.Name,
 		Class:     form["class"].(string),
 		StudentId: studentId,
@@ -2,6 +2,7 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
@@ -35,7 +36,12 @@ func GetHttpClient() *HttpClient {
 
 // Req 发送 HTTP 请求
 func (c *HttpClient) Req(method, url string, headers http.Header, body interface{}) (map[string]interface{}, error) {
-	resp, err := c.do(method, url, headers, body)
+	return c.ReqCtx(context.Background(), method, url, headers, body)
+}
+
+// ReqCtx 发送 HTTP 请求, ctx取消或超时时请求立即返回
+func (c *HttpClient) ReqCtx(ctx context.Context, method, url string, headers http.Header, body interface{}) (map[string]interface{}, error) {
+	resp, err := c.do(ctx, method, url, headers, body)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -67,10 +73,30 @@ func (c *HttpClient) Req(method, url string, headers http.Header, body interface
 	return respMap, nil
 }
 
+// SendCtx 发送 HTTP 请求, 只检查状态码, 不解析响应体
+func (c *HttpClient) SendCtx(ctx context.Context, method, url string, headers http.Header, body interface{}) error {
+	resp, err := c.do(ctx, method, url, headers, body)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			log.Printf("关闭请求失败: %v", closeErr)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_resp, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code: %d, response body: %s", resp.StatusCode, _resp)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
 // StreamReq 流式响应的请求
 func (c *HttpClient) StreamReq(method, url string, headers http.Header, body interface{}) (*StreamReader, error) {
 
-	resp, err := c.do(method, url, headers, body)
+	resp, err := c.do(context.Background(), method, url, headers, body)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
@@ -92,7 +118,7 @@ func (c *HttpClient) StreamReq(method, url string, headers http.Header, body int
 }
 
 // do 实际执行请求
-func (c *HttpClient) do(method, url string, headers http.Header, body interface{}) (*http.Response, error) {
+func (c *HttpClient) do(ctx context.Context, method, url string, headers http.Header, body interface{}) (*http.Response, error) {
 	// 将 body 序列化为 JSON
 	bodyBytes, err := json.Marshal(body)
 	if err != nil {
@@ -100,7 +126,7 @@ func (c *HttpClient) do(method, url string, headers http.Header, body interface{
 	}
 
 	// 创建新的请求
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -1,10 +1,22 @@
 package util
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
+)
 
 func TestEmail(t *testing.T) {
-	err := AlertEMail()
+	c := config.GetConfig()
+	if c == nil || len(c.Alert.Fallback) == 0 {
+		t.Skip("smtp not configured")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := SendMail(ctx, c.Alert.Fallback, "预警信息", "text/plain", "检测到心理空间出现一位高风险学生，请立即前往处理")
 	if err != nil {
-		t.Errorf("AlertEMail() error: %v", err)
+		t.Errorf("SendMail() error: %v", err)
 	}
 }
@@ -3,14 +3,19 @@ package util
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
 	"github.com/xh-polaris/psych-digital/biz/adaptor/cmd"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
 	"io"
 	"log"
+	"mime"
+	"net"
 	"net/smtp"
 	"strconv"
+	"strings"
 )
 
 // GzipCompress 按照gzip的方式压缩
@@ -76,15 +81,61 @@ func ParsePaging(p *cmd.Paging) (skip, limit int64) {
 	return skip, limit
 }
 
-// AlertEMail 发送邮件shallwii@126.com
-func AlertEMail() (err error) {
+// SendMail 使用配置的SMTP账号发送邮件, contentType 为 text/plain 或 text/html
+// 连接和整个会话都受ctx的截止时间限制, 避免邮件服务器无响应时一直阻塞
+func SendMail(ctx context.Context, to []string, subject, contentType, body string) (err error) {
 	c := config.GetConfig().SMTP
-	auth := smtp.PlainAuth("", c.Username, c.Password, c.Host)
-	err = smtp.SendMail(c.Host+":"+strconv.Itoa(c.Port), auth, c.Username, []string{c.Alert}, []byte(fmt.Sprintf(
+	addr := c.Host + ":" + strconv.Itoa(c.Port)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	// 与smtp.SendMail相同, 服务器支持时使用STARTTLS
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: c.Host}); err != nil {
+			return err
+		}
+	}
+	if err = client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+		return err
+	}
+	if err = client.Mail(c.Username); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err = client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	// 主题可能包含中文, 需要按RFC 2047编码
+	if _, err = fmt.Fprintf(w,
 		"To: %s\r\n"+
 			"From: xh-polaris\r\n"+
-			"Content-Type: text/plain"+"; charset=UTF-8\r\n"+
-			"Subject: 预警信息\r\n\r\n"+
-			"检测到心理空间出现一位高风险学生，请立即前往处理\r\n", c.Alert)))
-	return err
+			"Content-Type: %s; charset=UTF-8\r\n"+
+			"Subject: %s\r\n\r\n"+
+			"%s", strings.Join(to, ","), contentType, mime.BEncoding.Encode("UTF-8", subject), body); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
 }
@@ -0,0 +1,259 @@
+// Package client 是长对话和语音对话接口的Go客户端, 使用v1协议
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// MsgAudio 合成语音的消息类型, 对应二进制音频帧
+const MsgAudio = "audio"
+
+// ErrLegacyServer 服务端不支持v1协议
+var ErrLegacyServer = errors.New("server does not support protocol v1")
+
+// Error 服务端返回的错误
+type Error struct {
+	Code int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("[code=%d] %s", e.Code, e.Msg)
+}
+
+// Message 服务端的一条消息, 只有Type对应的字段不为空
+type Message struct {
+	Type string
+	// Seq 消息序号, 开始响应为0
+	Seq      uint64
+	Start    *dto.ChatStartResp
+	Text     *dto.ChatData
+	Event    *dto.ChatEvent
+	Subtitle *dto.ChatSubtitle
+	Viseme   *dto.ChatViseme
+	Asr      *dto.AsrResp
+	End      *dto.ChatEndResp
+	Error    *dto.Response
+	// Audio 合成语音, SegmentId为所属句子, 与字幕相同
+	Audio     []byte
+	SegmentId uint32
+}
+
+// Client 一次对话的连接, 连接断开后可以调用Resume恢复对话
+// Next只能在一个协程中调用, 发送消息的方法可以并发调用
+type Client struct {
+	url    string
+	dialer *websocket.Dialer
+	// studentId 重连时校验的学号
+	studentId string
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	session *dto.ChatStartResp
+	// micSeq 上传音频的序号
+	micSeq uint64
+
+	// received 收到的最大消息序号, 重连后从这之后重放
+	received atomic.Uint64
+}
+
+// Dial 连接对话接口并开始对话, url如 ws://host/chat 或 ws://host/voice_chat
+// 鉴权失败等服务端错误返回*Error
+func Dial(ctx context.Context, url string, req *dto.ChatStartReq) (*Client, error) {
+	c := &Client{url: url, dialer: websocket.DefaultDialer, studentId: req.StudentId}
+	start := *req
+	if err := c.open(ctx, &start); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Session 开始响应, 重连后为重连的响应
+func (c *Client) Session() *dto.ChatStartResp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+// Received 收到的最大消息序号
+func (c *Client) Received() uint64 {
+	return c.received.Load()
+}
+
+// Resume 连接断开后重新连接, 服务端重放断开期间的消息, 之后继续调用Next获取
+// 对话已结束或重连超时返回*Error
+func (c *Client) Resume(ctx context.Context) error {
+	c.mu.Lock()
+	old, token := c.conn, c.session.ResumeToken
+	c.mu.Unlock()
+	_ = old.Close()
+
+	return c.open(ctx, &dto.ChatStartReq{
+		StudentId:   c.studentId,
+		ResumeToken: token,
+		Received:    c.received.Load(),
+	})
+}
+
+// open 建立连接并发送开始请求, 等待开始响应
+func (c *Client) open(ctx context.Context, req *dto.ChatStartReq) error {
+	conn, _, err := c.dialer.DialContext(ctx, c.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Version = consts.ProtocolLatest
+	data, err := dto.MarshalEnvelope(consts.ProtocolLatest, consts.MsgStart, 0, req)
+	if err == nil {
+		err = conn.WriteMessage(websocket.TextMessage, data)
+	}
+	var msg *Message
+	if err == nil {
+		msg, err = read(conn)
+	}
+	if err == nil {
+		switch {
+		case msg.Type == consts.MsgError:
+			err = &Error{Code: msg.Error.Code, Msg: msg.Error.Msg}
+		case msg.Type != consts.MsgStart || msg.Start.Version < consts.ProtocolV1:
+			err = ErrLegacyServer
+		}
+	}
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn, c.session = conn, msg.Start
+	return nil
+}
+
+// Next 获取服务端的下一条消息, 连接断开时返回错误, 可以调用Resume重连
+func (c *Client) Next() (*Message, error) {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	msg, err := read(conn)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Seq > c.received.Load() {
+		c.received.Store(msg.Seq)
+	}
+	return msg, nil
+}
+
+// Send 发送一句话
+func (c *Client) Send(text string) error {
+	return c.command(consts.MsgInput, &dto.ChatReq{Msg: text})
+}
+
+// Ping 发送心跳, 服务端回复pong
+func (c *Client) Ping() error {
+	return c.command(consts.MsgPing, nil)
+}
+
+// Interrupt 打断AI的输出
+func (c *Client) Interrupt() error {
+	return c.command(consts.MsgInterrupt, nil)
+}
+
+// End 结束对话, 服务端回复end后关闭连接
+func (c *Client) End() error {
+	return c.command(consts.MsgEnd, nil)
+}
+
+// SendAudio 语音对话中上传一段麦克风音频, 格式为开始请求中声明的格式
+func (c *Client) SendAudio(audio []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.micSeq++
+	h := &dto.AudioHeader{Version: consts.ProtocolV1, Kind: consts.AudioMic, Seq: c.micSeq}
+	return c.conn.WriteMessage(websocket.BinaryMessage, h.Append(audio))
+}
+
+// Close 关闭连接, 不会结束对话, 结束对话需要先调用End
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.Close()
+}
+
+// command 发送一条命令
+func (c *Client) command(typ string, data any) error {
+	msg, err := dto.MarshalEnvelope(consts.ProtocolLatest, typ, 0, data)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// read 读取并解析一条消息, 不认识的类型只返回类型和序号
+func read(conn *websocket.Conn) (*Message, error) {
+	mt, data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	if mt == websocket.BinaryMessage {
+		h, audio, err := dto.UnmarshalAudio(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Message{Type: MsgAudio, Seq: h.Seq, Audio: audio, SegmentId: h.SegmentId}, nil
+	}
+
+	var env dto.Envelope
+	if err = json.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+	if env.Type == "" {
+		return nil, ErrLegacyServer
+	}
+	msg := &Message{Type: env.Type, Seq: env.Seq}
+	var v any
+	switch env.Type {
+	case consts.MsgStart:
+		msg.Start = &dto.ChatStartResp{}
+		v = msg.Start
+	case consts.MsgText:
+		msg.Text = &dto.ChatData{}
+		v = msg.Text
+	case consts.MsgEvent:
+		msg.Event = &dto.ChatEvent{}
+		v = msg.Event
+	case consts.MsgSubtitle:
+		msg.Subtitle = &dto.ChatSubtitle{}
+		v = msg.Subtitle
+	case consts.MsgViseme:
+		msg.Viseme = &dto.ChatViseme{}
+		v = msg.Viseme
+	case consts.MsgAsr:
+		msg.Asr = &dto.AsrResp{}
+		v = msg.Asr
+	case consts.MsgEnd:
+		msg.End = &dto.ChatEndResp{}
+		v = msg.End
+	case consts.MsgError:
+		msg.Error = &dto.Response{}
+		v = msg.Error
+	}
+	if v != nil && env.Data != nil {
+		if err = json.Unmarshal(env.Data, v); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	hws "github.com/hertz-contrib/websocket"
+	"github.com/xh-polaris/psych-digital/biz/application/dto"
+	"github.com/xh-polaris/psych-digital/biz/domain"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/consts"
+)
+
+// echoServer 使用WsHelper实现的简化对话服务
+// 收到一句话后回复文字和一段合成语音, 收到音频后以识别结果回复音频长度; 连接断开后写入一条消息并等待重连
+type echoServer struct {
+	ws       *domain.WsHelper
+	detached chan struct{}
+}
+
+// newEchoServer 启动服务, 返回ws地址
+func newEchoServer(t *testing.T) (string, *echoServer) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	s := &echoServer{detached: make(chan struct{}, 1)}
+	h := server.New(server.WithHostPorts(addr), server.WithExitWaitTime(0))
+	upgrader := hws.HertzUpgrader{CheckOrigin: func(*app.RequestContext) bool { return true }}
+	h.GET("/chat", func(ctx context.Context, rc *app.RequestContext) {
+		_ = upgrader.Upgrade(rc, s.serve)
+	})
+	go func() { _ = h.Run() }()
+	t.Cleanup(func() { _ = h.Shutdown(context.Background()) })
+
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			_ = conn.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return "ws://" + addr + "/chat", s
+}
+
+func (s *echoServer) serve(conn *hws.Conn) {
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return
+	}
+	var req dto.ChatStartReq
+	if err = dto.UnmarshalStart(data, &req); err != nil {
+		return
+	}
+	resp := &dto.ChatStartResp{SessionId: "s", ResumeToken: "token", Version: consts.ProtocolV1}
+	if req.ResumeToken != "" {
+		resp.Resumed = true
+		if s.ws == nil || req.ResumeToken != resp.ResumeToken || s.ws.Attach(conn, req.Received, resp) != nil {
+			ws := domain.NewWsHelper(conn)
+			ws.Protocol(consts.ProtocolV1)
+			_ = ws.Error(consts.ErrResumeExpired)
+			return
+		}
+	} else {
+		s.ws = domain.NewWsHelper(conn)
+		s.ws.Protocol(consts.ProtocolV1)
+		if err = s.ws.Send(consts.MsgStart, resp); err != nil {
+			return
+		}
+		s.ws.Journal(1 << 20)
+	}
+
+	ws := s.ws
+	for {
+		mt, data, err := ws.Read()
+		if err != nil {
+			ws.Detach()
+			_ = ws.Send(consts.MsgText, &dto.ChatData{Content: "offline"})
+			s.detached <- struct{}{}
+			return
+		}
+		if mt == hws.BinaryMessage {
+			_, audio, err := dto.UnmarshalAudio(data)
+			if err != nil {
+				return
+			}
+			_ = ws.Send(consts.MsgAsr, &dto.AsrResp{Text: string(rune('0' + len(audio)))})
+			continue
+		}
+		var env dto.Envelope
+		if err = json.Unmarshal(data, &env); err != nil {
+			return
+		}
+		switch env.Type {
+		case consts.MsgInput:
+			var in dto.ChatReq
+			_ = json.Unmarshal(env.Data, &in)
+			_ = ws.Send(consts.MsgText, &dto.ChatData{Content: in.Msg})
+			_ = ws.WriteAudio(7, []byte{1, 2, 3})
+		case consts.MsgPing:
+			_ = ws.Pong()
+		case consts.MsgEnd:
+			_ = ws.Send(consts.MsgEnd, &dto.ChatEndResp{Msg: "对话结束"})
+			return
+		}
+	}
+}
+
+// next 获取下一条消息
+func next(t *testing.T, c *Client, typ string) *Message {
+	t.Helper()
+	msg, err := c.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != typ {
+		t.Fatalf("type = %s, want %s", msg.Type, typ)
+	}
+	return msg
+}
+
+func TestClient(t *testing.T) {
+	url, s := newEchoServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := Dial(ctx, url, &dto.ChatStartReq{StudentId: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Session(); got.ResumeToken != "token" || got.Version != consts.ProtocolV1 {
+		t.Fatalf("session = %+v", got)
+	}
+
+	if err = c.Send("你好"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := next(t, c, consts.MsgText); msg.Seq != 1 || msg.Text.Content != "你好" {
+		t.Fatalf("text = %+v", msg)
+	}
+	if msg := next(t, c, MsgAudio); msg.Seq != 2 || msg.SegmentId != 7 || len(msg.Audio) != 3 {
+		t.Fatalf("audio = %+v", msg)
+	}
+	if err = c.SendAudio(make([]byte, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if msg := next(t, c, consts.MsgAsr); msg.Asr.Text != "4" {
+		t.Fatalf("asr = %+v", msg.Asr)
+	}
+	if err = c.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	next(t, c, consts.MsgPong)
+
+	// 断线期间的消息在重连后重放, 序号连续
+	_ = c.Close()
+	<-s.detached
+	if err = c.Resume(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Session(); !got.Resumed || got.Replay != 1 {
+		t.Fatalf("resume = %+v", got)
+	}
+	if msg := next(t, c, consts.MsgText); msg.Seq != 5 || msg.Text.Content != "offline" {
+		t.Fatalf("replay = %+v", msg)
+	}
+
+	if err = c.End(); err != nil {
+		t.Fatal(err)
+	}
+	next(t, c, consts.MsgEnd)
+	_ = c.Close()
+}
+
+func TestClient_ResumeExpired(t *testing.T) {
+	url, _ := newEchoServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := Dial(ctx, url, &dto.ChatStartReq{ResumeToken: "unknown"})
+	var e *Error
+	if !errors.As(err, &e) || e.Code != consts.ErrResumeExpired.Code() {
+		t.Fatalf("err = %v, client = %v", err, c)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/xh-polaris/psych-digital/biz/application/service"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/history"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/risk"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/rpc/psych_user"
 )
 
 var provider *Provider
@@ -21,21 +23,26 @@ func Init() {
 type Provider struct {
 	Config         *config.Config
 	HistoryService service.HistoryService
+	RiskService    service.RiskService
 }
 
 func Get() *Provider {
 	return provider
 }
 
-var RpcSet = wire.NewSet()
+var RpcSet = wire.NewSet(
+	psych_user.PsychUserSet,
+)
 
 var ApplicationSet = wire.NewSet(
 	service.HistoryServiceSet,
+	service.RiskServiceSet,
 )
 
 var InfrastructureSet = wire.NewSet(
 	config.NewConfig,
 	history.NewMongoMapper,
+	risk.NewMongoMapper,
 	RpcSet,
 )
 
@@ -10,6 +10,8 @@ import (
 	"github.com/xh-polaris/psych-digital/biz/application/service"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/config"
 	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/history"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/mapper/risk"
+	"github.com/xh-polaris/psych-digital/biz/infrastructure/rpc/psych_user"
 )
 
 // Injectors from wire.go:
@@ -20,12 +22,22 @@ func NewProvider() (*Provider, error) {
 		return nil, err
 	}
 	mongoMapper := history.NewMongoMapper(configConfig)
+	client := psych_user.NewPsychUser(configConfig)
+	psychUser := &psych_user.PsychUser{
+		Client: client,
+	}
 	historyService := service.HistoryService{
 		HistoryMapper: mongoMapper,
+		PsychUser:     psychUser,
+	}
+	riskMongoMapper := risk.NewMongoMapper(configConfig)
+	riskService := service.RiskService{
+		RiskMapper: riskMongoMapper,
 	}
 	providerProvider := &Provider{
 		Config:         configConfig,
 		HistoryService: historyService,
+		RiskService:    riskService,
 	}
 	return providerProvider, nil
 }